@@ -35,8 +35,8 @@ func (a *Accounts) BasicAuth(next HandlerFunc) HandlerFunc {
 			a.unAuthHandler(ctx)
 			return
 		}
-		// 将用户名存储到上下文中，以便后续的处理中使用
-		ctx.Set("user", username)
+		// 将用户名存储到上下文中，以便后续的处理中使用，并添加到请求日志的字段中
+		ctx.SetUser(username)
 		next(ctx)
 	}
 }
@@ -5,6 +5,7 @@ import (
 	newlogger "frame/log"
 	"github.com/BurntSushi/toml"
 	"os"
+	"strings"
 )
 
 // Conf 是一个全局变量，指向 FrameConfig 结构体实例，用于存储项目的配置信息。
@@ -13,16 +14,25 @@ var Conf = &FrameConfig{
 	logger: newlogger.Default(),
 }
 
+// confFile 是命令行参数 "-conf"，用于指定配置文件路径，默认值为 "conf/app.toml"。
+// 参数注册在 flag.CommandLine 中，应用调用 flag.Parse 时可以识别 -conf。
+var confFile = flag.String("conf", "conf/app.toml", "app config file")
+
+// path 是启动时加载的配置文件路径。
+var path string
+
 // FrameConfig 定义了项目的配置结构，包含以下字段：
 // - logger：日志记录器实例，用于记录日志信息。
 // - Log：日志相关的配置项，存储为键值对形式。
 // - Pool：连接池相关的配置项，存储为键值对形式。
 // - Template：模板相关的配置项，存储为键值对形式。
+// - Database：数据库连接池相关的配置项，存储为键值对形式，供 orm.LoadDbConfig 读取。
 type FrameConfig struct {
 	logger   *newlogger.Logger
 	Log      map[string]any
 	Pool     map[string]any
 	Template map[string]any
+	Database map[string]any
 }
 
 // init 函数在程序启动时自动调用，用于初始化全局配置 Conf。
@@ -37,20 +47,57 @@ func init() {
 // 返回值：
 // - 无返回值，但会在加载失败时记录日志并终止加载流程。
 func loadToml() {
-	// 定义命令行参数 "-conf"，用于指定配置文件路径，默认值为 "conf/app.toml"。
-	configFile := flag.String("conf", "conf/app.toml", "app config file")
-	flag.Parse()
+	// 这里不调用 flag.Parse：init 执行时应用自己的命令行参数（以及 go test 的 -test.* 参数）
+	// 还没有定义，解析会因为未知参数而退出程序，因此只从命令行中查找 -conf 参数。
+	path = *confFile
+	if file, ok := lookupFlag(os.Args[1:], "conf"); ok {
+		path = file
+	}
 
 	// 检查配置文件是否存在，如果不存在则记录日志并退出函数。
-	if _, err := os.Stat(*configFile); err != nil {
+	if _, err := os.Stat(path); err != nil {
 		Conf.logger.Info("conf/app.toml file not load，because not exist")
 		return
 	}
 
 	// 解析配置文件内容到 Conf 全局变量中，如果解析失败则记录日志并退出函数。
-	_, err := toml.DecodeFile(*configFile, Conf)
+	_, err := toml.DecodeFile(path, Conf)
 	if err != nil {
 		Conf.logger.Info("conf/app.toml decode fail check format")
 		return
 	}
 }
+
+// Read 重新读取配置文件并返回新的配置，不修改 Conf。应用调用过 flag.Parse 时读取 -conf 指定的文件，
+// 否则读取启动时加载的文件。用于在收到 SIGHUP 等信号时读取可以在运行时修改的配置项，例如 [log] 中的 level。
+func Read() (*FrameConfig, error) {
+	file := path
+	if flag.Parsed() {
+		file = *confFile
+	}
+	conf := &FrameConfig{logger: Conf.logger}
+	if _, err := toml.DecodeFile(file, conf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// lookupFlag 在命令行参数中查找指定名称的参数值，支持 -name value、-name=value 以及两个短横线的写法。
+func lookupFlag(args []string, name string) (string, bool) {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		key := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if key == arg {
+			continue
+		}
+		if key == name && i+1 < len(args) {
+			return args[i+1], true
+		}
+		if value, ok := strings.CutPrefix(key, name+"="); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
@@ -35,6 +35,20 @@ type Context struct {
 	Logger                *newlogger.Logger   // logger用于记录日志。
 	Keys                  map[string]any      // Keys是一个用于存储键值对的映射，用于在请求处理过程中传递请求特定数据。
 	mu                    sync.RWMutex        // 同步读写锁
+	requestID             string              // 请求 ID
+}
+
+// reset 在 Context 从对象池中取出时重置上一个请求的数据。
+func (c *Context) reset(w http.ResponseWriter, r *http.Request) {
+	c.W = w
+	c.R = r
+	c.StatusCode = 0
+	c.queryCache = nil
+	c.formCache = nil
+	c.requestID = ""
+	c.mu.Lock()
+	c.Keys = nil
+	c.mu.Unlock()
 }
 
 // Render函数用于向客户端发送响应，并设置响应的状态码。
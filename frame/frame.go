@@ -1,18 +1,28 @@
 package frame
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"frame/config"
 	newlogger "frame/log"
 	"frame/render"
 	"html/template"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
+	"time"
 )
 
 const ANY = "ANY"
 
+// ShutdownTimeout 是收到退出信号后等待正在处理的请求完成的最长时间。
+var ShutdownTimeout = 10 * time.Second
+
 // HandlerFunc 定义了处理器函数的类型，它接收一个 http.ResponseWriter 和一个 *http.Request 作为参数
 // type HandlerFunc func(w http.ResponseWriter, r *http.Request)
 
@@ -56,6 +66,8 @@ type Engine struct {
 	errorHandler ErrorHandler      // 错误处理函数
 	Logger       *newlogger.Logger // 日志记录器
 	middles      []MiddlewareFunc  // 中间件函数列表
+	serverMu     sync.Mutex        // 保护 server
+	server       *http.Server      // Run、RunTLS 启动的 HTTP 服务器
 }
 
 // New 函数用于创建并返回一个新的 Engine 实例
@@ -81,8 +93,24 @@ func Default() *Engine {
 	//engine.Logger = newlogger.Default()
 	logPath, ok := config.Conf.Log["path"]
 	if ok {
+		engine.Logger.Rotate = rotateOptions(config.Conf.Log)
 		engine.Logger.SetLogPath(logPath.(string))
 	}
+	// [[log.sinks]] 配置额外的日志输出：syslog、tcp、udp、http
+	engine.configureLogSinks(config.Conf.Log)
+	// 配置了 level 时设置日志级别，收到 SIGHUP 信号时重新读取
+	if level, ok := config.Conf.Log["level"].(string); ok {
+		engine.setLogLevel(level)
+	}
+	// caller、stack 分别开启调用位置和 ERROR 及以上级别日志的调用栈
+	engine.Logger.ReportCaller, _ = config.Conf.Log["caller"].(bool)
+	engine.Logger.StackTrace, _ = config.Conf.Log["stack"].(bool)
+	// 配置了 async = true 时日志异步写入，缓冲区大小和溢出策略由 buffer、overflow 配置
+	if async, ok := config.Conf.Log["async"].(bool); ok && async {
+		engine.Logger.SetAsync(asyncOptions(config.Conf.Log))
+	}
+	// [log.sampling]、[log.rate_limit] 配置日志采样和限流，drop_report 配置报告丢弃条数的周期
+	engine.configureLogFilter(config.Conf.Log)
 	// 使用Recovery和Logging中间件，将框架的错误处理函数设置为默认的ErrorHandler。
 	engine.Use(Recovery, Logging)
 	// 将框架的错误处理函数设置为默认的ErrorHandler。
@@ -278,22 +306,21 @@ func (r *routerGroup) Head(name string, handlerFunc HandlerFunc, middlewareFunc
 func (e *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// 从对象池中获取一个Context实例
 	ctx := e.pool.Get().(*Context)
-	ctx.W = w
-	ctx.R = r
+	ctx.reset(w, r)
 	ctx.Logger = e.Logger
 	e.httpRequestHandle(ctx, w, r)
 	e.pool.Put(ctx)
 }
 
 // Run 启动 HTTP 服务器，监听指定的端口。
+// 收到 SIGINT、SIGTERM 信号时优雅退出：等待正在处理的请求完成（最长 ShutdownTimeout），再写出并关闭日志。
 func (e *Engine) Run(addr string) {
 	// 将 Engine 实例注册为 HTTP 服务器的处理程序
 	http.Handle("/", e)
-	// 监听 8111 端口并启动服务器
-	err := http.ListenAndServe(addr, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
+	// 监听指定端口并启动服务器
+	e.serve(&http.Server{Addr: addr}, func(srv *http.Server) error {
+		return srv.ListenAndServe()
+	})
 }
 
 // httpRequestHandle 处理HTTP请求，根据路由匹配规则进行路由处理。
@@ -307,6 +334,8 @@ func (e *Engine) httpRequestHandle(ctx *Context, w http.ResponseWriter, r *http.
 		// 在路由树中查找匹配的节点
 		node := group.treeNode.Get(routerName)
 		if node != nil && node.isEnd {
+			// 创建带有请求 ID、路由以及客户端 IP 字段的请求日志记录器
+			e.beginRequest(ctx, "/"+group.groupName+node.routerName)
 			// 优先尝试匹配ANY方法处理器
 			handle, ok := group.handleFuncMap[node.routerName][ANY]
 			if ok {
@@ -331,15 +360,330 @@ func (e *Engine) httpRequestHandle(ctx *Context, w http.ResponseWriter, r *http.
 	}
 
 	// 所有路由组匹配失败时返回404
+	e.beginRequest(ctx, "")
 	w.WriteHeader(http.StatusNotFound)
 	fmt.Fprintf(w, "%s  not found \n", r.URL.Path)
 }
 
 // RunTLS 启动 HTTPS 服务器，监听指定的端口。（若希望可以支持https进行访问，那么必须要配置相关的证书）
+// 退出的处理与 Run 相同。
 func (e *Engine) RunTLS(addr, certFile, keyFile string) {
-	err := http.ListenAndServeTLS(addr, certFile, keyFile, e.Handler())
+	e.serve(&http.Server{Addr: addr, Handler: e.Handler()}, func(srv *http.Server) error {
+		return srv.ListenAndServeTLS(certFile, keyFile)
+	})
+}
+
+// serve 使用 listen 启动服务器，并在收到退出信号时调用 Shutdown。
+// 服务器启动失败时写出日志后退出程序；通过其它协程调用 Shutdown 时直接返回，由调用 Shutdown 的协程等待退出完成。
+func (e *Engine) serve(srv *http.Server, listen func(srv *http.Server) error) {
+	e.serverMu.Lock()
+	e.server = srv
+	e.serverMu.Unlock()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(quit)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- listen(srv)
+	}()
+	for {
+		select {
+		case err := <-errCh:
+			if errors.Is(err, http.ErrServerClosed) {
+				return
+			}
+			// log.Fatal 不会执行 defer，先关闭日志保证缓冲的日志写出
+			e.Logger.Close()
+			log.Fatal(err)
+		case sig := <-quit:
+			// SIGHUP 重新读取配置文件中的日志级别，不退出
+			if sig == syscall.SIGHUP {
+				e.reloadLogLevel()
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+			err := e.Shutdown(ctx)
+			cancel()
+			if err != nil {
+				log.Println(err)
+			}
+			return
+		}
+	}
+}
+
+// reloadLogLevel 重新读取配置文件 [log] 中的 level 并设置日志级别。
+func (e *Engine) reloadLogLevel() {
+	conf, err := config.Read()
+	if err != nil {
+		e.Logger.Errorf("reload config: %v", err)
+		return
+	}
+	if level, ok := conf.Log["level"].(string); ok {
+		e.setLogLevel(level)
+	}
+}
+
+// setLogLevel 按照级别名称设置日志级别，名称无效时记录错误。
+func (e *Engine) setLogLevel(name string) {
+	level, err := newlogger.ParseLevel(name)
 	if err != nil {
-		log.Fatal(err)
+		e.Logger.Error(err)
+		return
+	}
+	e.Logger.SetLevel(level)
+}
+
+// LogLevelHandler 返回在运行时查看、修改日志级别的处理函数（参见 log.Logger.LevelHandler），例如：
+//
+//	admin := engine.Group("admin")
+//	admin.Use(accounts.BasicAuth)
+//	admin.Any("/log/level", engine.LogLevelHandler())
+func (e *Engine) LogLevelHandler() HandlerFunc {
+	h := e.Logger.LevelHandler()
+	return func(ctx *Context) {
+		h.ServeHTTP(ctx.W, ctx.R)
+	}
+}
+
+// Shutdown 优雅地关闭 Run、RunTLS 启动的服务器：停止接收新的连接，等待正在处理的请求完成或 ctx 结束，
+// 然后写出并关闭日志的所有输出目标。没有启动服务器时只关闭日志。
+func (e *Engine) Shutdown(ctx context.Context) error {
+	e.serverMu.Lock()
+	srv := e.server
+	e.serverMu.Unlock()
+	var err error
+	if srv != nil {
+		err = srv.Shutdown(ctx)
+	}
+	if cerr := e.Logger.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// rotateOptions 从 [log] 配置中读取日志文件的切割配置：max_size 为单个文件的最大大小（MB），
+// rotate 为按时间切割的周期，可选 hourly、daily，max_backups 为保留的历史文件数量，
+// max_age 为历史文件的保留天数，compress 为是否压缩历史文件。
+func rotateOptions(conf map[string]any) newlogger.RotateOptions {
+	var opts newlogger.RotateOptions
+	if size, ok := conf["max_size"].(int64); ok {
+		opts.MaxSize = size << 20
+	}
+	switch conf["rotate"] {
+	case "hourly":
+		opts.Interval = newlogger.RotateHourly
+	case "daily":
+		opts.Interval = newlogger.RotateDaily
+	}
+	if backups, ok := conf["max_backups"].(int64); ok {
+		opts.MaxBackups = int(backups)
+	}
+	if days, ok := conf["max_age"].(int64); ok {
+		opts.MaxAge = time.Duration(days) * 24 * time.Hour
+	}
+	opts.Compress, _ = conf["compress"].(bool)
+	return opts
+}
+
+// configureLogFilter 从 [log] 配置中读取日志采样和限流的配置，例如：
+//
+//	[log]
+//	drop_report = "1m"   # 每分钟报告一次被丢弃的日志条数
+//	[log.sampling]
+//	interval = "1s"      # 采样周期
+//	first = 100          # 每个周期内同一消息记录前 100 条
+//	thereafter = 100     # 之后每 100 条记录一条
+//	[log.rate_limit]
+//	error = 50           # ERROR 级别每秒最多 50 条，突发量相同
+//
+// 配置无效时记录错误并忽略该项。
+func (e *Engine) configureLogFilter(conf map[string]any) {
+	if sampling, ok := conf["sampling"].(map[string]any); ok {
+		e.configureLogSampling(sampling)
+	}
+	if limits, ok := conf["rate_limit"].(map[string]any); ok {
+		for name, value := range limits {
+			level, err := newlogger.ParseLevel(name)
+			if err != nil || level >= newlogger.LevelPanic {
+				e.Logger.Errorf("log rate limit: invalid level %q", name)
+				continue
+			}
+			var rate float64
+			switch v := value.(type) {
+			case int64:
+				rate = float64(v)
+			case float64:
+				rate = v
+			default:
+				e.Logger.Errorf("log rate limit %s: invalid rate %v", name, value)
+				continue
+			}
+			e.Logger.SetRateLimit(level, rate, int(rate))
+		}
+	}
+	if report, ok := conf["drop_report"].(string); ok {
+		d, err := time.ParseDuration(report)
+		if err != nil || d <= 0 {
+			e.Logger.Errorf("log drop report: invalid interval %q", report)
+			return
+		}
+		e.Logger.ReportDropped(d)
+	}
+}
+
+// configureLogSampling 按照 [log.sampling] 配置开启日志采样，interval 无效时记录错误并且不开启采样。
+func (e *Engine) configureLogSampling(sampling map[string]any) {
+	opts := newlogger.SamplingOptions{}
+	if first, ok := sampling["first"].(int64); ok {
+		opts.First = int(first)
+	}
+	if thereafter, ok := sampling["thereafter"].(int64); ok {
+		opts.Thereafter = int(thereafter)
+	}
+	if interval, ok := sampling["interval"].(string); ok {
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			e.Logger.Errorf("log sampling interval: %v", err)
+			return
+		}
+		opts.Interval = d
+	}
+	e.Logger.SetSampling(opts)
+}
+
+// asyncOptions 从 [log] 配置中读取异步写入的配置：buffer 为缓冲区大小，
+// overflow 为溢出策略，可选 block（默认）、drop_oldest、drop_newest。
+func asyncOptions(conf map[string]any) newlogger.AsyncOptions {
+	var opts newlogger.AsyncOptions
+	if size, ok := conf["buffer"].(int64); ok {
+		opts.BufferSize = int(size)
+	}
+	switch conf["overflow"] {
+	case "drop_oldest":
+		opts.Overflow = newlogger.OverflowDropOldest
+	case "drop_newest":
+		opts.Overflow = newlogger.OverflowDropNewest
+	}
+	return opts
+}
+
+// configureLogSinks 从 [[log.sinks]] 配置中创建额外的日志输出，每个输出接收所有级别的日志，例如：
+//
+//	[[log.sinks]]
+//	type = "syslog"        # 本地 syslog，配置 network、addr 时发送到远程 syslog 服务
+//	app = "mall"           # 应用名称，默认为程序文件名
+//	facility = "local0"    # 设施，可选 user（默认）、daemon、local0 ~ local7
+//	[[log.sinks]]
+//	type = "tcp"           # 行协议，可选 tcp、udp
+//	addr = "127.0.0.1:5170"
+//	[[log.sinks]]
+//	type = "http"          # 以 NDJSON 批量 POST 日志
+//	url = "http://127.0.0.1:9880/logs"
+//	batch = 100            # 每批的最大条数
+//	flush = "1s"           # 不满一批时的发送间隔
+//	retries = 3            # 失败时的重试次数，默认为 3，0 表示不重试
+//	spool = "logs/spool"   # 重试后仍然失败时保存日志的目录
+//	[log.sinks.headers]
+//	Authorization = "Bearer xxx"
+//
+// 配置无效时记录错误并忽略该项。
+func (e *Engine) configureLogSinks(conf map[string]any) {
+	var sinks []map[string]any
+	switch v := conf["sinks"].(type) {
+	case []map[string]any:
+		sinks = v
+	case []any:
+		for _, item := range v {
+			if sink, ok := item.(map[string]any); ok {
+				sinks = append(sinks, sink)
+			}
+		}
+	}
+	for _, sink := range sinks {
+		w, err := openLogSink(sink)
+		if err != nil {
+			e.Logger.Errorf("log sink %v: %v", sink["type"], err)
+			continue
+		}
+		e.Logger.AddOut(&newlogger.LoggerWriter{Level: -1, Out: w})
+	}
+}
+
+// syslogFacilities 是 [[log.sinks]] 中 facility 可选的值。
+var syslogFacilities = map[string]newlogger.Facility{
+	"user":   newlogger.FacilityUser,
+	"daemon": newlogger.FacilityDaemon,
+	"auth":   newlogger.FacilityAuth,
+	"local0": newlogger.FacilityLocal0,
+	"local1": newlogger.FacilityLocal1,
+	"local2": newlogger.FacilityLocal2,
+	"local3": newlogger.FacilityLocal3,
+	"local4": newlogger.FacilityLocal4,
+	"local5": newlogger.FacilityLocal5,
+	"local6": newlogger.FacilityLocal6,
+	"local7": newlogger.FacilityLocal7,
+}
+
+// openLogSink 根据一项 [[log.sinks]] 配置创建日志输出。
+func openLogSink(conf map[string]any) (io.Writer, error) {
+	network, _ := conf["network"].(string)
+	addr, _ := conf["addr"].(string)
+	switch typ, _ := conf["type"].(string); typ {
+	case "syslog":
+		opts := newlogger.SyslogOptions{}
+		opts.AppName, _ = conf["app"].(string)
+		if name, ok := conf["facility"].(string); ok {
+			facility, ok := syslogFacilities[name]
+			if !ok {
+				return nil, fmt.Errorf("invalid facility %q", name)
+			}
+			opts.Facility = facility
+		}
+		if addr != "" && network == "" {
+			network = "udp"
+		}
+		return newlogger.NewSyslogWriter(network, addr, opts), nil
+	case "tcp", "udp":
+		if addr == "" {
+			return nil, errors.New("addr is required")
+		}
+		return newlogger.NewNetWriter(typ, addr), nil
+	case "http":
+		url, _ := conf["url"].(string)
+		if url == "" {
+			return nil, errors.New("url is required")
+		}
+		opts := newlogger.HTTPOptions{}
+		if batch, ok := conf["batch"].(int64); ok {
+			opts.BatchSize = int(batch)
+		}
+		if flush, ok := conf["flush"].(string); ok {
+			d, err := time.ParseDuration(flush)
+			if err != nil {
+				return nil, err
+			}
+			opts.FlushInterval = d
+		}
+		if retries, ok := conf["retries"].(int64); ok {
+			// HTTPOptions 中 MaxRetries 为 0 表示使用默认值，配置的 0 表示不重试
+			opts.MaxRetries = int(retries)
+			if retries == 0 {
+				opts.MaxRetries = -1
+			}
+		}
+		opts.SpoolDir, _ = conf["spool"].(string)
+		if headers, ok := conf["headers"].(map[string]any); ok {
+			opts.Headers = make(http.Header)
+			for k, v := range headers {
+				opts.Headers.Set(k, fmt.Sprint(v))
+			}
+		}
+		return newlogger.NewHTTPWriter(url, opts)
+	default:
+		return nil, fmt.Errorf("unknown type %q", typ)
 	}
 }
 
@@ -0,0 +1,233 @@
+package frame
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	newlogger "frame/log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// closeBuffer 是记录是否被关闭的输出流。
+type closeBuffer struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (b *closeBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *closeBuffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	return nil
+}
+
+func (b *closeBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestShutdownClosesLogger(t *testing.T) {
+	e := New()
+	out := &closeBuffer{}
+	e.Logger = newlogger.New()
+	e.Logger.Formatter = &newlogger.TextFormatter{}
+	e.Logger.AddOut(&newlogger.LoggerWriter{Level: -1, Out: out})
+	e.Logger.SetAsync(newlogger.AsyncOptions{})
+	e.Logger.Info("shutting down")
+
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "shutting down") || !out.closed {
+		t.Fatalf("logger not flushed and closed: %q, closed %v", out.String(), out.closed)
+	}
+}
+
+// newTestContext 创建使用 JSON 格式记录日志的 Engine 以及请求 r 的 Context，out 收集日志。
+func newTestContext(r *http.Request) (*Engine, *Context, *closeBuffer) {
+	e := New()
+	out := &closeBuffer{}
+	e.Logger = newlogger.New()
+	e.Logger.Formatter = &newlogger.JsonFormatter{}
+	e.Logger.AddOut(&newlogger.LoggerWriter{Level: -1, Out: out})
+	ctx := e.allocateContext().(*Context)
+	ctx.reset(httptest.NewRecorder(), r)
+	return e, ctx, out
+}
+
+// lastFields 返回最后一条 JSON 日志中的字段。
+func lastFields(t *testing.T, out *closeBuffer) map[string]any {
+	t.Helper()
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &entry); err != nil {
+		t.Fatalf("invalid log line %q: %v", lines[len(lines)-1], err)
+	}
+	return entry
+}
+
+func TestBeginRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/user/1", nil)
+	r.RemoteAddr = "10.0.0.1:5000"
+	r.Header.Set(RequestIDHeader, "req-1.A_b")
+	e, ctx, out := newTestContext(r)
+	e.beginRequest(ctx, "/user/:id")
+
+	if ctx.RequestID() != "req-1.A_b" || ctx.W.Header().Get(RequestIDHeader) != "req-1.A_b" {
+		t.Fatalf("request id %q, header %q", ctx.RequestID(), ctx.W.Header().Get(RequestIDHeader))
+	}
+	ctx.Logger.Info("handled")
+	fields := lastFields(t, out)
+	if fields[LogFieldRequestID] != "req-1.A_b" || fields[LogFieldRoute] != "/user/:id" || fields[LogFieldClientIP] != "10.0.0.1" {
+		t.Fatalf("unexpected log fields %v", fields)
+	}
+
+	// 不合法或者过长的请求 ID 被替换为生成的随机 ID
+	for _, id := range []string{"", "bad id", "inject\nline", "a/b", strings.Repeat("a", maxRequestIDLength+1)} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(RequestIDHeader, id)
+		e, ctx, _ := newTestContext(r)
+		e.beginRequest(ctx, "")
+		if got := ctx.RequestID(); got == id || len(got) != 32 || ctx.W.Header().Get(RequestIDHeader) != got {
+			t.Fatalf("request id %q should be replaced, got %q", id, got)
+		}
+	}
+	r.Header.Set(RequestIDHeader, strings.Repeat("a", maxRequestIDLength))
+	e.beginRequest(ctx, "")
+	if len(ctx.RequestID()) != maxRequestIDLength {
+		t.Fatalf("request id of the maximum length should be kept, got %q", ctx.RequestID())
+	}
+}
+
+func TestSetUser(t *testing.T) {
+	e, ctx, out := newTestContext(httptest.NewRequest(http.MethodGet, "/", nil))
+	e.beginRequest(ctx, "/")
+	ctx.SetUser("alice")
+	if user, ok := ctx.Get("user"); !ok || user != "alice" {
+		t.Fatalf("user key %v %v", user, ok)
+	}
+	ctx.Logger.Info("handled")
+	if fields := lastFields(t, out); fields[LogFieldUser] != "alice" || fields[LogFieldRequestID] != ctx.RequestID() {
+		t.Fatalf("unexpected log fields %v", fields)
+	}
+	// 其它请求的日志不受影响
+	e.Logger.Info("engine")
+	if _, ok := lastFields(t, out)[LogFieldUser]; ok {
+		t.Fatal("SetUser should not change the engine logger")
+	}
+
+	// 没有 Logger 时只记录到 Keys 中
+	bare := &Context{}
+	bare.SetUser("bob")
+	if user, _ := bare.Get("user"); user != "bob" || bare.Logger != nil {
+		t.Fatalf("unexpected context %v %v", user, bare.Logger)
+	}
+}
+
+func TestContextReset(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/?a=1", strings.NewReader(""))
+	e, ctx, _ := newTestContext(r)
+	e.beginRequest(ctx, "/")
+	ctx.GetQuery("a")
+	ctx.SetUser("alice")
+	ctx.StatusCode = http.StatusTeapot
+
+	next := httptest.NewRequest(http.MethodGet, "/?a=2", nil)
+	w := httptest.NewRecorder()
+	ctx.reset(w, next)
+	if ctx.W != w || ctx.R != next || ctx.StatusCode != 0 || ctx.requestID != "" || ctx.Keys != nil {
+		t.Fatalf("context not reset: %+v", ctx)
+	}
+	if ctx.queryCache != nil || ctx.formCache != nil {
+		t.Fatal("query and form caches should be cleared")
+	}
+	if v := ctx.GetQuery("a"); v != "2" {
+		t.Fatalf("query read from the previous request: %q", v)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	for _, tt := range []struct {
+		forwarded, realIP, remote, want string
+	}{
+		{"203.0.113.1, 10.0.0.2", "10.0.0.3", "10.0.0.4:80", "203.0.113.1"},
+		{" 203.0.113.1 ", "", "10.0.0.4:80", "203.0.113.1"},
+		{", 10.0.0.2", "10.0.0.3", "10.0.0.4:80", "10.0.0.3"},
+		{"", "10.0.0.3", "10.0.0.4:80", "10.0.0.3"},
+		{"", "", "10.0.0.4:80", "10.0.0.4"},
+		{"", "", "[::1]:80", "::1"},
+		{"", "", "unix-socket", "unix-socket"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if tt.realIP != "" {
+			r.Header.Set("X-Real-IP", tt.realIP)
+		}
+		ctx := &Context{R: r}
+		if got := ctx.ClientIP(); got != tt.want {
+			t.Fatalf("ClientIP(%q, %q, %q) = %q, want %q", tt.forwarded, tt.realIP, tt.remote, got, tt.want)
+		}
+	}
+}
+
+func TestConfigureLogSampling(t *testing.T) {
+	e := New()
+	e.Logger = newlogger.New()
+	e.Logger.Formatter = &newlogger.TextFormatter{}
+	e.Logger.AddOut(&newlogger.LoggerWriter{Level: -1, Out: &closeBuffer{}})
+
+	// interval 无效时不开启采样
+	e.configureLogFilter(map[string]any{"sampling": map[string]any{"interval": "soon", "first": int64(1)}})
+	e.Logger.Info("repeated")
+	e.Logger.Info("repeated")
+	if sampled, _ := e.Logger.Dropped(newlogger.LevelInfo); sampled != 0 {
+		t.Fatalf("sampling should stay off with an invalid interval, %d entries sampled", sampled)
+	}
+
+	e.configureLogFilter(map[string]any{"sampling": map[string]any{"interval": "1h", "first": int64(1)}})
+	e.Logger.Info("repeated")
+	e.Logger.Info("repeated")
+	if sampled, _ := e.Logger.Dropped(newlogger.LevelInfo); sampled != 1 {
+		t.Fatalf("got %d sampled entries, want 1", sampled)
+	}
+}
+
+func TestOpenLogSinkRetries(t *testing.T) {
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	// retries = 0 表示不重试，而不是使用默认的重试次数
+	out, err := openLogSink(map[string]any{"type": "http", "url": srv.URL, "retries": int64(0), "flush": "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := out.(*newlogger.HTTPWriter)
+	defer w.Close()
+	w.WriteLevel(newlogger.LevelError, []byte("lost\n"))
+	if err := w.Sync(); err == nil {
+		t.Fatal("expected a send error")
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("got %d requests, want 1", n)
+	}
+}
@@ -0,0 +1,286 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrWriterClosed 表示向已经关闭的 AsyncWriter 写入日志。
+var ErrWriterClosed = errors.New("log: writer closed")
+
+// OverflowPolicy 定义缓冲区写满时的处理策略。
+type OverflowPolicy int
+
+const (
+	// OverflowBlock 阻塞写入方，直到后台协程写出日志、缓冲区有空位，不丢失日志。
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest 丢弃缓冲区中最早的一条日志，写入方不会被阻塞。
+	OverflowDropOldest
+	// OverflowDropNewest 丢弃正在写入的日志，写入方不会被阻塞。
+	OverflowDropNewest
+)
+
+// 异步写入的默认配置
+const (
+	DefaultBufferSize = 1024 // 缓冲区可以保存的日志条数
+	DefaultBatchSize  = 64   // 每次写入输出流的最大日志条数
+)
+
+// AsyncOptions 是 AsyncWriter 的配置。
+type AsyncOptions struct {
+	BufferSize int            // 缓冲区可以保存的日志条数，小于等于 0 时使用 DefaultBufferSize
+	BatchSize  int            // 每次写入输出流的最大日志条数，小于等于 0 时使用 DefaultBatchSize
+	Overflow   OverflowPolicy // 缓冲区写满时的处理策略
+}
+
+// AsyncWriter 是异步的日志输出流：Write 只把日志放入有界的环形缓冲区，
+// 由后台协程把缓冲区中的日志合并后批量写入底层的输出流，请求处理的路径上不再有磁盘 IO。
+// 底层的输出流是 LevelWriter 时不合并，按条调用 WriteLevel 并保留日志级别。
+// 程序退出前需要调用 Sync 或 Close，否则缓冲区中的日志会丢失。
+type AsyncWriter struct {
+	out     io.Writer
+	batch   int
+	policy  OverflowPolicy
+	mu      sync.Mutex
+	cond    *sync.Cond   // 缓冲区、写入状态发生变化时广播
+	buf     []asyncEntry // 环形缓冲区
+	head    int          // 最早一条日志的位置
+	size    int          // 缓冲区中的日志条数
+	writing bool         // 后台协程是否正在写入
+	closed  bool
+	err     error // 后台写入时发生的第一个错误，由 Sync、Close 返回
+	dropped atomic.Uint64
+	done    chan struct{}
+}
+
+// asyncEntry 是缓冲区中的一条日志，level 为 -1 时表示通过 Write 写入、没有日志级别。
+type asyncEntry struct {
+	level LoggerLevel
+	p     []byte
+}
+
+// NewAsyncWriter 创建一个包装 out 的 AsyncWriter 并启动后台写入协程。
+func NewAsyncWriter(out io.Writer, opts AsyncOptions) *AsyncWriter {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultBufferSize
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	w := &AsyncWriter{
+		out:    out,
+		batch:  opts.BatchSize,
+		policy: opts.Overflow,
+		buf:    make([]asyncEntry, opts.BufferSize),
+		done:   make(chan struct{}),
+	}
+	w.cond = sync.NewCond(&w.mu)
+	go w.run()
+	return w
+}
+
+// Write 把一条日志放入缓冲区。缓冲区写满时按照 OverflowPolicy 处理，被丢弃的日志计入 Dropped。
+// p 会被复制，调用方可以在返回后复用。
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(-1, p)
+}
+
+// WriteLevel 把一条带有日志级别的日志放入缓冲区，规则与 Write 相同。
+func (w *AsyncWriter) WriteLevel(level LoggerLevel, p []byte) (int, error) {
+	entry := asyncEntry{level: level, p: append([]byte(nil), p...)}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+	if w.size == len(w.buf) {
+		switch w.policy {
+		case OverflowDropNewest:
+			w.dropped.Add(1)
+			return len(p), nil
+		case OverflowDropOldest:
+			w.buf[w.head] = asyncEntry{}
+			w.head = (w.head + 1) % len(w.buf)
+			w.size--
+			w.dropped.Add(1)
+		default:
+			for w.size == len(w.buf) && !w.closed {
+				w.cond.Wait()
+			}
+			if w.closed {
+				return 0, ErrWriterClosed
+			}
+		}
+	}
+	w.buf[(w.head+w.size)%len(w.buf)] = entry
+	w.size++
+	w.cond.Broadcast()
+	return len(p), nil
+}
+
+// run 是后台写入协程，每次取出最多 BatchSize 条日志合并为一次写入，关闭并写完所有日志后退出。
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+	var batch bytes.Buffer
+	var entries []asyncEntry
+	lw, leveled := w.out.(LevelWriter)
+	for {
+		w.mu.Lock()
+		for w.size == 0 && !w.closed {
+			w.cond.Wait()
+		}
+		if w.size == 0 {
+			w.mu.Unlock()
+			return
+		}
+		for n := 0; n < w.batch && w.size > 0; n++ {
+			entries = append(entries, w.buf[w.head])
+			w.buf[w.head] = asyncEntry{}
+			w.head = (w.head + 1) % len(w.buf)
+			w.size--
+		}
+		w.writing = true
+		// 通知被阻塞的写入方缓冲区已有空位
+		w.cond.Broadcast()
+		w.mu.Unlock()
+
+		var err error
+		if leveled {
+			// 按条写入，保留日志级别
+			for _, e := range entries {
+				if _, werr := writeLevel(lw, e.level, e.p); werr != nil && err == nil {
+					err = werr
+				}
+			}
+		} else {
+			for _, e := range entries {
+				batch.Write(e.p)
+			}
+			_, err = w.out.Write(batch.Bytes())
+			batch.Reset()
+		}
+		for i := range entries {
+			entries[i] = asyncEntry{}
+		}
+		entries = entries[:0]
+
+		w.mu.Lock()
+		if err != nil && w.err == nil {
+			w.err = err
+		}
+		w.writing = false
+		w.cond.Broadcast()
+		w.mu.Unlock()
+	}
+}
+
+// Flush 等待缓冲区中的日志全部写入底层的输出流，返回后台写入时发生的错误。
+func (w *AsyncWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for w.size > 0 || w.writing {
+		w.cond.Wait()
+	}
+	err := w.err
+	w.err = nil
+	return err
+}
+
+// Sync 写出缓冲区中的日志，并在底层输出流支持时（例如 *os.File）将其同步到磁盘。
+func (w *AsyncWriter) Sync() error {
+	err := w.Flush()
+	if s, ok := w.out.(syncer); ok && !isStd(w.out) {
+		if serr := s.Sync(); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// Close 停止接收日志，等待缓冲区中的日志全部写出后关闭底层的输出流（标准输出、标准错误除外）。
+// 重复调用 Close 返回 nil。
+func (w *AsyncWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.cond.Broadcast()
+	w.mu.Unlock()
+	<-w.done
+
+	w.mu.Lock()
+	err := w.err
+	w.err = nil
+	w.mu.Unlock()
+	if cerr := closeWriter(w.out); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Dropped 返回缓冲区写满时被丢弃的日志条数。
+func (w *AsyncWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// LevelWriter 是按条写入日志的输出流，例如 SyslogWriter、NetWriter、HTTPWriter：
+// Logger 写入时调用 WriteLevel 并传入日志级别，每次调用是一条完整的日志（以换行结尾）。
+type LevelWriter interface {
+	io.Writer
+	WriteLevel(level LoggerLevel, p []byte) (int, error)
+}
+
+// writeLevel 写入一条日志，level 为 -1（没有日志级别）时调用 Write。
+func writeLevel(w LevelWriter, level LoggerLevel, p []byte) (int, error) {
+	if level < 0 {
+		return w.Write(p)
+	}
+	return w.WriteLevel(level, p)
+}
+
+// syncer 是支持同步到磁盘的输出流，例如 *os.File。
+type syncer interface {
+	Sync() error
+}
+
+// isStd 判断输出流是否为标准输出或标准错误，这两个输出流不会被同步和关闭。
+func isStd(w io.Writer) bool {
+	return w == os.Stdout || w == os.Stderr
+}
+
+// underlying 返回 AsyncWriter 包装的输出流，其它输出流原样返回。
+func underlying(w io.Writer) io.Writer {
+	if a, ok := w.(*AsyncWriter); ok {
+		return a.out
+	}
+	return w
+}
+
+// syncWriter 写出输出流中缓冲的日志并同步到磁盘，标准输出、标准错误只写出缓冲的日志。
+func syncWriter(w io.Writer) error {
+	if a, ok := w.(*AsyncWriter); ok {
+		return a.Sync()
+	}
+	if s, ok := w.(syncer); ok && !isStd(w) {
+		return s.Sync()
+	}
+	return nil
+}
+
+// closeWriter 关闭输出流，标准输出、标准错误以及已经关闭的文件不会报错。
+func closeWriter(w io.Writer) error {
+	c, ok := w.(io.Closer)
+	if !ok || isStd(w) {
+		return nil
+	}
+	if err := c.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gateWriter 记录写入的数据，release 之前每次 Write 都会阻塞，用于模拟缓慢的输出流。
+type gateWriter struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	closed  bool
+	started chan struct{} // 进入 Write 时发送，channel 已满时不再发送
+	gate    chan struct{} // 关闭后 Write 不再阻塞
+}
+
+func newGateWriter() *gateWriter {
+	return &gateWriter{started: make(chan struct{}, 64), gate: make(chan struct{})}
+}
+
+func (w *gateWriter) Write(p []byte) (int, error) {
+	select {
+	case w.started <- struct{}{}:
+	default:
+	}
+	<-w.gate
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *gateWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	return nil
+}
+
+func (w *gateWriter) release() {
+	close(w.gate)
+}
+
+func (w *gateWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+// waitWrite 等待后台协程进入输出流的 Write，此时取出的日志已经离开缓冲区。
+func waitWrite(t *testing.T, w *gateWriter) {
+	t.Helper()
+	select {
+	case <-w.started:
+	case <-time.After(time.Second):
+		t.Fatal("background writer did not start writing")
+	}
+}
+
+// returns 在 fn 执行完成时关闭返回的 channel。
+func returns(fn func()) chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	return done
+}
+
+func TestAsyncWriterBlock(t *testing.T) {
+	out := newGateWriter()
+	w := NewAsyncWriter(out, AsyncOptions{BufferSize: 1, BatchSize: 1})
+	w.Write([]byte("a"))
+	waitWrite(t, out)
+	w.Write([]byte("b"))
+
+	// 缓冲区已满，写入方阻塞直到后台协程取走日志
+	done := returns(func() { w.Write([]byte("c")) })
+	select {
+	case <-done:
+		t.Fatal("write should block while the buffer is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	out.release()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("write should return once the consumer drains the buffer")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "abc" || w.Dropped() != 0 {
+		t.Fatalf("got %q, dropped %d", got, w.Dropped())
+	}
+}
+
+func TestAsyncWriterDrop(t *testing.T) {
+	for _, tt := range []struct {
+		policy OverflowPolicy
+		want   string
+	}{
+		{OverflowDropOldest, "acd"},
+		{OverflowDropNewest, "abc"},
+	} {
+		out := newGateWriter()
+		w := NewAsyncWriter(out, AsyncOptions{BufferSize: 2, BatchSize: 1, Overflow: tt.policy})
+		w.Write([]byte("a"))
+		waitWrite(t, out)
+		// b、c 写满缓冲区，d 触发溢出策略
+		for _, s := range []string{"b", "c", "d"} {
+			if n, err := w.Write([]byte(s)); n != 1 || err != nil {
+				t.Fatalf("policy %d: write %q returned %d, %v", tt.policy, s, n, err)
+			}
+		}
+		if w.Dropped() != 1 {
+			t.Fatalf("policy %d: dropped %d, want 1", tt.policy, w.Dropped())
+		}
+		out.release()
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != tt.want {
+			t.Fatalf("policy %d: got %q, want %q", tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestAsyncWriterFlush(t *testing.T) {
+	out := newGateWriter()
+	w := NewAsyncWriter(out, AsyncOptions{})
+	w.Write([]byte("a\n"))
+	waitWrite(t, out)
+
+	// 日志已经离开缓冲区但还没有写完，Flush 需要等待正在写入的批次
+	done := returns(func() {
+		if err := w.Flush(); err != nil {
+			t.Error(err)
+		}
+	})
+	select {
+	case <-done:
+		t.Fatal("flush returned before the batch was written")
+	case <-time.After(50 * time.Millisecond):
+	}
+	out.release()
+	<-done
+	if got := out.String(); got != "a\n" {
+		t.Fatalf("got %q after flush", got)
+	}
+
+	// 输出流不再阻塞后 Flush 返回时所有日志都已写出
+	for i := 0; i < 3; i++ {
+		w.Write([]byte("b\n"))
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "a\nb\nb\nb\n" {
+		t.Fatalf("got %q after flush", got)
+	}
+	w.Close()
+}
+
+func TestAsyncWriterClose(t *testing.T) {
+	out := newGateWriter()
+	out.release()
+	w := NewAsyncWriter(out, AsyncOptions{BufferSize: 16})
+	for i := 0; i < 10; i++ {
+		w.Write([]byte("x"))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "xxxxxxxxxx" {
+		t.Fatalf("buffer not drained on close: %q", got)
+	}
+	if !out.closed {
+		t.Fatal("underlying writer should be closed")
+	}
+	if _, err := w.Write([]byte("y")); !errors.Is(err, ErrWriterClosed) {
+		t.Fatalf("expected ErrWriterClosed, got %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("second close returned %v", err)
+	}
+}
+
+func TestAsyncWriterCloseUnblocksWriters(t *testing.T) {
+	out := newGateWriter()
+	w := NewAsyncWriter(out, AsyncOptions{BufferSize: 1, BatchSize: 1})
+	w.Write([]byte("a"))
+	waitWrite(t, out)
+	w.Write([]byte("b"))
+	blocked := returns(func() {
+		if _, err := w.Write([]byte("c")); !errors.Is(err, ErrWriterClosed) {
+			t.Errorf("blocked writer: expected ErrWriterClosed, got %v", err)
+		}
+	})
+	closed := returns(func() { w.Close() })
+	<-blocked
+	out.release()
+	<-closed
+	if got := out.String(); got != "ab" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestLoggerAsync(t *testing.T) {
+	out := newGateWriter()
+	out.release()
+	l := New()
+	l.Formatter = &TextFormatter{}
+	l.AddOut(&LoggerWriter{Level: -1, Out: out})
+	l.SetAsync(AsyncOptions{})
+	if _, ok := l.Outs()[0].Out.(*AsyncWriter); !ok {
+		t.Fatal("SetAsync should wrap existing outputs")
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				l.Info("message")
+			}
+		}()
+	}
+	wg.Wait()
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count([]byte(out.String()), []byte("message")); n != 400 || !out.closed {
+		t.Fatalf("got %d messages, closed %v", n, out.closed)
+	}
+}
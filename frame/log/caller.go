@@ -0,0 +1,57 @@
+package log
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// maxStackDepth 是记录调用栈的最大深度。
+const maxStackDepth = 32
+
+// packagePrefix 是日志包中函数名的前缀，获取调用位置时跳过这些函数。
+var packagePrefix = reflect.TypeOf(Logger{}).PkgPath() + "."
+
+// callerFrames 返回从调用日志方法的位置开始的调用栈，跳过日志包内部的函数，最多 depth 层。
+func callerFrames(depth int) []runtime.Frame {
+	pcs := make([]uintptr, depth+8)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var result []runtime.Frame
+	for {
+		f, more := frames.Next()
+		if len(result) > 0 || !strings.HasPrefix(f.Function, packagePrefix) {
+			result = append(result, f)
+		}
+		if !more || len(result) == depth {
+			return result
+		}
+	}
+}
+
+// caller 返回调用日志方法的位置，格式为 目录/文件:行号，以及所在的函数。
+func caller() (string, string) {
+	frames := callerFrames(1)
+	if len(frames) == 0 {
+		return "", ""
+	}
+	return shortFile(frames[0].File, frames[0].Line), frames[0].Function
+}
+
+// stack 返回从调用日志方法的位置开始的调用栈，格式与 runtime/debug.Stack 相同：
+// 每一层为函数名，以及缩进的 文件:行号。
+func stack() string {
+	var sb strings.Builder
+	for _, f := range callerFrames(maxStackDepth) {
+		fmt.Fprintf(&sb, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// shortFile 返回文件所在的目录名、文件名以及行号，例如 frame/context.go:35。
+func shortFile(file string, line int) string {
+	dir, name := filepath.Split(file)
+	return fmt.Sprintf("%s/%s:%d", filepath.Base(dir), name, line)
+}
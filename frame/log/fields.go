@@ -0,0 +1,137 @@
+package log
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 时间格式：除 Go 的时间布局（例如 time.RFC3339Nano）外，还可以使用以下格式
+const (
+	// TimeEpochMillis 以 Unix 毫秒时间戳（整数）记录时间。
+	TimeEpochMillis = "epoch_millis"
+)
+
+// RedactedValue 是被脱敏的字段的值。
+const RedactedValue = "[REDACTED]"
+
+// DefaultRedactKeys 是常见的敏感字段名，可以直接赋值给 JsonFormatter、LogfmtFormatter 的 Redact。
+var DefaultRedactKeys = []string{"password", "passwd", "secret", "token", "access_token", "api_key", "authorization", "cookie", "set-cookie"}
+
+// fieldClashPrefix 是与日志的固定字段（时间、级别、消息、调用位置、调用栈）同名的日志字段的前缀，
+// 例如日志字段 caller 记录为 fields.caller，不会覆盖固定字段，也不会在 logfmt 中出现重复的字段名。
+const fieldClashPrefix = "fields."
+
+// formatTime 按 layout 格式化时间，layout 为 TimeEpochMillis 时返回毫秒时间戳。
+func formatTime(t time.Time, layout string) any {
+	if layout == TimeEpochMillis {
+		return t.UnixMilli()
+	}
+	return t.Format(layout)
+}
+
+// redactor 按字段名（不区分大小写）脱敏，为 nil 时不脱敏。
+type redactor map[string]struct{}
+
+// newRedactor 创建脱敏 keys 中字段的 redactor，keys 为空时返回 nil。
+func newRedactor(keys []string) redactor {
+	if len(keys) == 0 {
+		return nil
+	}
+	r := make(redactor, len(keys))
+	for _, k := range keys {
+		r[strings.ToLower(k)] = struct{}{}
+	}
+	return r
+}
+
+// match 判断字段名是否需要脱敏。
+func (r redactor) match(key string) bool {
+	if r == nil {
+		return false
+	}
+	_, ok := r[strings.ToLower(key)]
+	return ok
+}
+
+// stringMap 将键为字符串的 map（例如 Fields、http.Header）转换为 map[string]any，其它值返回 false。
+func stringMap(v any) (map[string]any, bool) {
+	switch m := v.(type) {
+	case Fields:
+		return m, true
+	case map[string]any:
+		return m, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	m := make(map[string]any, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		m[iter.Key().String()] = iter.Value().Interface()
+	}
+	return m, true
+}
+
+// cleanFields 返回脱敏后的字段副本，嵌套的 map 同样脱敏；flatten 为 true 时将嵌套的 map 展开为 "父字段.子字段"。
+// 日志字段被多条日志共享，不会被修改。
+func cleanFields(fields Fields, flatten bool, r redactor) Fields {
+	out := make(Fields, len(fields)+6)
+	cleanInto(out, "", fields, flatten, r)
+	return out
+}
+
+// cleanInto 将 fields 脱敏后写入 out，prefix 为展开时的字段名前缀。
+func cleanInto(out Fields, prefix string, fields map[string]any, flatten bool, r redactor) {
+	for k, v := range fields {
+		key := prefix + k
+		if r.match(k) {
+			out[key] = RedactedValue
+			continue
+		}
+		nested, ok := stringMap(v)
+		switch {
+		case !ok:
+			out[key] = v
+		case flatten:
+			cleanInto(out, key+".", nested, flatten, r)
+		default:
+			sub := make(Fields, len(nested))
+			cleanInto(sub, "", nested, flatten, r)
+			out[key] = sub
+		}
+	}
+}
+
+// renameClashes 将 fields 中与 reserved 同名的字段重命名为 fieldClashPrefix 加字段名。
+func renameClashes(fields Fields, reserved []string) {
+	for _, k := range reserved {
+		if v, ok := fields[k]; ok {
+			delete(fields, k)
+			fields[fieldClashPrefix+k] = v
+		}
+	}
+}
+
+// reservedKeys 返回一条日志中固定字段的字段名，调用位置和调用栈只在记录时保留。
+func reservedKeys(param *LoggingFormatParam, keys ...string) []string {
+	if param.Caller != "" {
+		keys = append(keys, "caller", "func")
+	}
+	if param.Stack != "" {
+		keys = append(keys, "stack")
+	}
+	return keys
+}
+
+// sortedKeys 返回按字典序排列的字段名。
+func sortedKeys(fields Fields) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,219 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decodeJSON 解析 JsonFormatter 输出的一条日志。
+func decodeJSON(t *testing.T, s string) map[string]any {
+	t.Helper()
+	var m map[string]any
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		t.Fatalf("invalid JSON %q: %v", s, err)
+	}
+	return m
+}
+
+// logfmtPairs 将 LogfmtFormatter 输出的一条日志拆分为 key=value，值中可能带有引号。
+func logfmtPairs(s string) []string {
+	var pairs []string
+	var sb strings.Builder
+	quoted, escaped := false, false
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quoted:
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case r == ' ' && !quoted:
+			pairs = append(pairs, sb.String())
+			sb.Reset()
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return append(pairs, sb.String())
+}
+
+func TestWriteLogfmt(t *testing.T) {
+	for _, tt := range []struct {
+		key   string
+		value any
+		want  string
+	}{
+		{"k", "plain", `k=plain`},
+		{"k", "two words", `k="two words"`},
+		{"k", `say "hi"`, `k="say \"hi\""`},
+		{"k", "a=b", `k="a=b"`},
+		{"k", `C:\tmp`, `k="C:\\tmp"`},
+		{"k", "line\nbreak\ttab", `k="line\nbreak\ttab"`},
+		{"k", "\x00", `k="\x00"`},
+		{"k", "", `k=""`},
+		{"k", nil, `k=`},
+		{"k", "héllo", `k=héllo`},
+		{"k", 42, `k=42`},
+		{"k", errors.New("not found"), `k="not found"`},
+		{"bad key=\"x\"", 1, `bad_key__x_=1`},
+	} {
+		var sb strings.Builder
+		writeLogfmt(&sb, tt.key, tt.value)
+		if got := sb.String(); got != tt.want {
+			t.Fatalf("writeLogfmt(%q, %#v) = %s, want %s", tt.key, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestLogfmtFormatter(t *testing.T) {
+	f := &LogfmtFormatter{Redact: DefaultRedactKeys}
+	fields := Fields{
+		"request_id": "abc",
+		"user":       Fields{"id": 1, "name": "alice smith", "Password": "p"},
+		"token":      "t",
+	}
+	got := f.Format(&LoggingFormatParam{Level: LevelWarn, Msg: "user login", Caller: "frame/ctx.go:12", Function: "frame.Login", LoggerFields: fields})
+	pairs := logfmtPairs(got)
+	if !strings.HasPrefix(pairs[0], "ts=") {
+		t.Fatalf("time should come first: %s", got)
+	}
+	want := []string{`level=warn`, `msg="user login"`, `caller=frame/ctx.go:12`, `func=frame.Login`, `request_id=abc`,
+		`token=[REDACTED]`, `user.Password=[REDACTED]`, `user.id=1`, `user.name="alice smith"`}
+	if !reflect.DeepEqual(pairs[1:], want) {
+		t.Fatalf("got %q, want %q", pairs[1:], want)
+	}
+	if fields["token"] != "t" || fields["user"].(Fields)["Password"] != "p" {
+		t.Fatal("formatting should not modify the logger fields")
+	}
+
+	// 自定义字段名以及毫秒时间戳
+	f = &LogfmtFormatter{TimeKey: "time", LevelKey: "severity", MessageKey: "message", TimeFormat: TimeEpochMillis}
+	before := time.Now().UnixMilli()
+	pairs = logfmtPairs(f.Format(&LoggingFormatParam{Level: LevelError, Msg: errors.New("boom")}))
+	var ts int64
+	if !strings.HasPrefix(pairs[0], "time=") || json.Unmarshal([]byte(strings.TrimPrefix(pairs[0], "time=")), &ts) != nil || ts < before || ts > time.Now().UnixMilli() {
+		t.Fatalf("unexpected timestamp %q", pairs[0])
+	}
+	if !reflect.DeepEqual(pairs[1:], []string{"severity=error", "message=boom"}) {
+		t.Fatalf("unexpected pairs %q", pairs)
+	}
+}
+
+func TestJsonFormatter(t *testing.T) {
+	f := &JsonFormatter{Redact: DefaultRedactKeys}
+	header := http.Header{"Authorization": {"Bearer x"}, "Accept": {"*/*"}}
+	fields := Fields{
+		"user":    Fields{"id": 1, "profile": map[string]any{"secret": "s", "city": "Paris"}},
+		"headers": header,
+		"Cookie":  "c",
+	}
+	m := decodeJSON(t, f.Format(&LoggingFormatParam{Level: LevelInfo, Msg: errors.New("boom"), LoggerFields: fields}))
+	if m[DefaultJsonMessageKey] != "boom" || m[DefaultJsonLevelKey] != "INFO" || m["Cookie"] != RedactedValue {
+		t.Fatalf("unexpected entry %v", m)
+	}
+	if _, ok := m[DefaultJsonTimeKey]; ok {
+		t.Fatal("time should only be recorded with TimeDisplay")
+	}
+	// 嵌套的字段同样脱敏
+	user := m["user"].(map[string]any)
+	profile := user["profile"].(map[string]any)
+	headers := m["headers"].(map[string]any)
+	if profile["secret"] != RedactedValue || profile["city"] != "Paris" || user["id"] != float64(1) {
+		t.Fatalf("unexpected user %v", user)
+	}
+	if headers["Authorization"] != RedactedValue || headers["Accept"].([]any)[0] != "*/*" {
+		t.Fatalf("unexpected headers %v", headers)
+	}
+	if header.Get("Authorization") != "Bearer x" || fields["Cookie"] != "c" {
+		t.Fatal("formatting should not modify the logger fields")
+	}
+
+	// 展开嵌套的字段
+	f = &JsonFormatter{Flatten: true, Redact: []string{"SECRET"}}
+	m = decodeJSON(t, f.Format(&LoggingFormatParam{Level: LevelInfo, Msg: "m", LoggerFields: fields}))
+	if m["user.id"] != float64(1) || m["user.profile.city"] != "Paris" || m["user.profile.secret"] != RedactedValue || m["Cookie"] != "c" {
+		t.Fatalf("unexpected flattened entry %v", m)
+	}
+	if _, ok := m["user"]; ok {
+		t.Fatal("nested fields should be flattened")
+	}
+
+	// 自定义字段名以及毫秒时间戳
+	f = &JsonFormatter{TimeDisplay: true, TimeKey: "ts", LevelKey: "severity", MessageKey: "message", TimeFormat: TimeEpochMillis}
+	before := time.Now().UnixMilli()
+	m = decodeJSON(t, f.Format(&LoggingFormatParam{Level: LevelDebug, Msg: "m"}))
+	ts, ok := m["ts"].(float64)
+	if !ok || int64(ts) < before || int64(ts) > time.Now().UnixMilli() || m["severity"] != "DEBUG" || m["message"] != "m" || len(m) != 3 {
+		t.Fatalf("unexpected entry %v", m)
+	}
+	f.TimeFormat = time.RFC3339
+	m = decodeJSON(t, f.Format(&LoggingFormatParam{Level: LevelDebug, Msg: "m"}))
+	if _, err := time.Parse(time.RFC3339, m["ts"].(string)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFieldClashes(t *testing.T) {
+	fields := Fields{"caller": "field caller", "func": "field func", "stack": "field stack", "msg": "field msg", "level": "field level"}
+	param := &LoggingFormatParam{Level: LevelError, Msg: "m", Caller: "a.go:1", Function: "pkg.F", Stack: "trace", LoggerFields: fields}
+
+	m := decodeJSON(t, (&JsonFormatter{}).Format(param))
+	for key, want := range map[string]any{
+		"caller": "a.go:1", "func": "pkg.F", "stack": "trace", "msg": "m",
+		"fields.caller": "field caller", "fields.func": "field func", "fields.stack": "field stack", "fields.msg": "field msg",
+		// level 不是 JsonFormatter 的固定字段名
+		"level": "field level",
+	} {
+		if m[key] != want {
+			t.Fatalf("%s: got %v, want %v", key, m[key], want)
+		}
+	}
+
+	pairs := logfmtPairs((&LogfmtFormatter{}).Format(param))
+	seen := make(map[string]string)
+	for _, p := range pairs {
+		k, v, _ := strings.Cut(p, "=")
+		if _, ok := seen[k]; ok {
+			t.Fatalf("duplicate key %s in %q", k, pairs)
+		}
+		seen[k] = v
+	}
+	if seen["caller"] != "a.go:1" || seen["fields.caller"] != `"field caller"` || seen["level"] != "error" || seen["fields.level"] != `"field level"` {
+		t.Fatalf("unexpected pairs %q", pairs)
+	}
+
+	// 没有记录调用位置时同名的字段保持原样
+	m = decodeJSON(t, (&JsonFormatter{}).Format(&LoggingFormatParam{Level: LevelInfo, Msg: "m", LoggerFields: fields}))
+	if m["caller"] != "field caller" || m["stack"] != "field stack" {
+		t.Fatalf("unexpected entry %v", m)
+	}
+}
+
+func TestJsonFormatterUnsupportedValues(t *testing.T) {
+	ch := make(chan int)
+	fields := Fields{"ch": ch, "nan": math.NaN(), "nested": Fields{"fn": func() {}}, "ok": 1}
+	var got string
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Fatalf("Format panicked: %v", r)
+			}
+		}()
+		got = (&JsonFormatter{}).Format(&LoggingFormatParam{Level: LevelInfo, Msg: "m", LoggerFields: fields})
+	}()
+	m := decodeJSON(t, got)
+	ptr, _ := m["ch"].(string)
+	if !strings.HasPrefix(ptr, "0x") || m["nan"] != "NaN" || m["ok"] != float64(1) || m["msg"] != "m" {
+		t.Fatalf("unexpected entry %v", m)
+	}
+	if _, ok := m["nested"].(string); !ok {
+		t.Fatalf("nested value should fall back to %%v, got %v", m["nested"])
+	}
+}
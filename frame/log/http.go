@@ -0,0 +1,410 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HTTPWriter 的默认配置
+const (
+	DefaultHTTPBatchSize     = 100
+	DefaultHTTPFlushInterval = time.Second
+	DefaultHTTPMaxRetries    = 3
+	DefaultHTTPMaxPending    = 10000
+	DefaultSpoolSize         = 100 << 20
+)
+
+// spoolSuffix 是落盘文件的扩展名。
+const spoolSuffix = ".ndjson"
+
+// HTTPOptions 是 HTTPWriter 的配置。
+type HTTPOptions struct {
+	BatchSize     int           // 每次发送的最大日志条数，默认 DefaultHTTPBatchSize
+	FlushInterval time.Duration // 不满一批时发送的间隔，默认 DefaultHTTPFlushInterval
+	MaxRetries    int           // 发送失败时的重试次数，默认 DefaultHTTPMaxRetries，小于 0 时不重试
+	MinBackoff    time.Duration // 第一次重试前的等待时间，之后每次翻倍，默认 100ms
+	MaxBackoff    time.Duration // 重试前等待时间的上限，默认 5s
+	MaxPending    int           // 内存中等待发送的最大条数，超过时丢弃新的日志，默认 DefaultHTTPMaxPending
+	SpoolDir      string        // 重试后仍然发送失败时保存日志的目录，为空时丢弃
+	MaxSpoolSize  int64         // 落盘文件的总大小上限，超过时删除最早的文件，默认 DefaultSpoolSize
+	Headers       http.Header   // 附加的请求头，例如 Authorization
+	Client        *http.Client  // 发送请求的客户端，默认为超时时间 10 秒的客户端
+}
+
+// HTTPWriter 以 NDJSON（每行一个 JSON 对象）批量 POST 日志到 HTTP 服务，例如 Loki、Elasticsearch 的采集网关。
+//
+// 日志先放入内存，由后台协程每满 BatchSize 条或者每隔 FlushInterval 发送一次；发送失败时按指数退避重试，
+// 重试后仍然失败时写入 SpoolDir，服务恢复后按时间顺序重新发送，程序重启后也会发送上次遗留的文件。
+// JSON 格式的日志原样发送，其它格式的日志包装为 {"level": "...", "message": "..."}。
+// 服务返回 4xx（429 除外）时认为日志本身有问题，不重试也不落盘，计入 Dropped。
+type HTTPWriter struct {
+	url     string
+	opts    HTTPOptions
+	mu      sync.Mutex
+	pending [][]byte // 等待发送的日志，每条以换行结尾
+	closed  bool
+	kick    chan struct{}   // 满一批时通知后台协程发送
+	flush   chan chan error // Sync 请求立即发送
+	stop    chan struct{}
+	done    chan struct{}
+	err     error         // 最近一次发送的错误，只由后台协程修改，Close 在协程退出后返回
+	spooled atomic.Bool   // SpoolDir 中是否可能有等待发送的文件
+	seq     atomic.Uint64 // 落盘文件的序号
+	dropped atomic.Uint64
+}
+
+// NewHTTPWriter 创建向 url 发送日志的 HTTPWriter 并启动后台发送协程。
+func NewHTTPWriter(url string, opts HTTPOptions) (*HTTPWriter, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultHTTPBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultHTTPFlushInterval
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = DefaultHTTPMaxRetries
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = 100 * time.Millisecond
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 5 * time.Second
+	}
+	if opts.MaxPending <= 0 {
+		opts.MaxPending = DefaultHTTPMaxPending
+	}
+	if opts.MaxSpoolSize <= 0 {
+		opts.MaxSpoolSize = DefaultSpoolSize
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	w := &HTTPWriter{
+		url:   url,
+		opts:  opts,
+		kick:  make(chan struct{}, 1),
+		flush: make(chan chan error),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if opts.SpoolDir != "" {
+		if err := os.MkdirAll(opts.SpoolDir, 0755); err != nil {
+			return nil, err
+		}
+		// 发送上次运行遗留的文件
+		w.spooled.Store(true)
+	}
+	go w.run()
+	return w, nil
+}
+
+// Write 放入日志，每一行为一条日志，没有日志级别。
+func (w *HTTPWriter) Write(p []byte) (int, error) {
+	var records [][]byte
+	for _, line := range bytes.Split(p, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			records = append(records, record(-1, line))
+		}
+	}
+	return len(p), w.push(records...)
+}
+
+// WriteLevel 放入一条日志。
+func (w *HTTPWriter) WriteLevel(level LoggerLevel, p []byte) (int, error) {
+	return len(p), w.push(record(level, bytes.TrimRight(p, "\n")))
+}
+
+// record 将一条日志转换为 NDJSON 的一行：JSON 对象原样使用，其它内容包装为 JSON 对象。
+func record(level LoggerLevel, line []byte) []byte {
+	trimmed := bytes.TrimSpace(line)
+	if len(trimmed) > 0 && trimmed[0] == '{' && json.Valid(trimmed) {
+		return append(append([]byte(nil), trimmed...), '\n')
+	}
+	obj := map[string]string{"message": string(line)}
+	if level >= 0 {
+		obj["level"] = level.Level()
+	}
+	b, _ := json.Marshal(obj)
+	return append(b, '\n')
+}
+
+// push 将日志放入内存，满一批时通知后台协程发送。
+func (w *HTTPWriter) push(records ...[]byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrWriterClosed
+	}
+	for _, r := range records {
+		if len(w.pending) >= w.opts.MaxPending {
+			w.dropped.Add(1)
+			continue
+		}
+		w.pending = append(w.pending, r)
+	}
+	if len(w.pending) >= w.opts.BatchSize {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// run 是后台发送协程。
+func (w *HTTPWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.kick:
+			w.sendPending()
+		case <-ticker.C:
+			w.sendPending()
+		case ch := <-w.flush:
+			ch <- w.sendPending()
+		case <-w.stop:
+			w.sendPending()
+			return
+		}
+	}
+}
+
+// sendPending 发送等待发送的日志并记录发送的结果。没有需要发送的日志时返回 nil，保留上一次发送的结果。
+func (w *HTTPWriter) sendPending() error {
+	w.mu.Lock()
+	idle := len(w.pending) == 0
+	w.mu.Unlock()
+	if idle && !w.spooled.Load() {
+		return nil
+	}
+	w.err = w.send()
+	return w.err
+}
+
+// send 发送所有等待发送的日志。发送成功后重新发送落盘的文件；发送失败时剩余的日志直接落盘，不再逐批重试。
+func (w *HTTPWriter) send() error {
+	var failed error
+	for {
+		w.mu.Lock()
+		n := len(w.pending)
+		if n > w.opts.BatchSize {
+			n = w.opts.BatchSize
+		}
+		batch := w.pending[:n]
+		w.pending = w.pending[n:]
+		if len(w.pending) == 0 {
+			w.pending = nil
+		}
+		w.mu.Unlock()
+		if len(batch) == 0 {
+			break
+		}
+		body := bytes.Join(batch, nil)
+		if failed == nil {
+			failed = w.post(body)
+			if failed == nil {
+				continue
+			}
+		}
+		w.spool(body, len(batch))
+	}
+	if failed == nil {
+		failed = w.resend()
+	}
+	return failed
+}
+
+// permanentError 是服务拒绝日志本身的错误，不重试也不落盘。
+type permanentError struct {
+	status string
+}
+
+func (e *permanentError) Error() string {
+	return "log: http sink rejected batch: " + e.status
+}
+
+// post 发送一批日志，失败时按指数退避重试。
+func (w *HTTPWriter) post(body []byte) error {
+	backoff := w.opts.MinBackoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = w.postOnce(body)
+		if err == nil {
+			return nil
+		}
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			// 被拒绝的日志直接丢弃，不影响后续日志的发送
+			w.dropped.Add(uint64(bytes.Count(body, []byte("\n"))))
+			fmt.Fprintln(os.Stderr, err)
+			return nil
+		}
+		if attempt >= w.opts.MaxRetries {
+			return err
+		}
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > w.opts.MaxBackoff {
+			backoff = w.opts.MaxBackoff
+		}
+	}
+}
+
+// postOnce 发送一次请求，状态码不是 2xx 时返回错误。
+func (w *HTTPWriter) postOnce(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{status: err.Error()}
+	}
+	for k, v := range w.opts.Headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp, err := w.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests:
+		return &permanentError{status: resp.Status}
+	}
+	return fmt.Errorf("log: http sink: %s", resp.Status)
+}
+
+// spool 将发送失败的一批日志写入 SpoolDir，没有配置 SpoolDir 时丢弃。
+func (w *HTTPWriter) spool(body []byte, count int) {
+	if w.opts.SpoolDir == "" {
+		w.dropped.Add(uint64(count))
+		return
+	}
+	// 文件名以纳秒时间戳开头，按文件名排序即为写入顺序
+	name := filepath.Join(w.opts.SpoolDir, fmt.Sprintf("%019d-%06d%s", time.Now().UnixNano(), w.seq.Add(1)%1000000, spoolSuffix))
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, body, 0644); err != nil {
+		w.dropped.Add(uint64(count))
+		fmt.Fprintf(os.Stderr, "log: spool http sink: %v\n", err)
+		return
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		os.Remove(tmp)
+		w.dropped.Add(uint64(count))
+		return
+	}
+	w.spooled.Store(true)
+	w.trimSpool()
+}
+
+// spoolFiles 返回 SpoolDir 中等待发送的文件，按写入顺序排列。
+func (w *HTTPWriter) spoolFiles() ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(w.opts.SpoolDir)
+	if err != nil {
+		return nil, err
+	}
+	files := entries[:0]
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.HasSuffix(e.Name(), spoolSuffix) {
+			files = append(files, e)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+	return files, nil
+}
+
+// trimSpool 删除最早的落盘文件，直到总大小不超过 MaxSpoolSize。
+func (w *HTTPWriter) trimSpool() {
+	files, err := w.spoolFiles()
+	if err != nil {
+		return
+	}
+	sizes := make([]int64, len(files))
+	var total int64
+	for i, f := range files {
+		if info, err := f.Info(); err == nil {
+			sizes[i] = info.Size()
+			total += sizes[i]
+		}
+	}
+	for i := 0; i < len(files) && total > w.opts.MaxSpoolSize; i++ {
+		path := filepath.Join(w.opts.SpoolDir, files[i].Name())
+		if data, err := os.ReadFile(path); err == nil {
+			w.dropped.Add(uint64(bytes.Count(data, []byte("\n"))))
+		}
+		os.Remove(path)
+		total -= sizes[i]
+	}
+}
+
+// resend 按写入顺序重新发送落盘的文件，遇到发送失败时停止，剩余的文件等待下一次发送。
+func (w *HTTPWriter) resend() error {
+	if !w.spooled.Load() {
+		return nil
+	}
+	files, err := w.spoolFiles()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		path := filepath.Join(w.opts.SpoolDir, f.Name())
+		body, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if err := w.postOnce(body); err != nil {
+			var perm *permanentError
+			if !errors.As(err, &perm) {
+				return err
+			}
+			w.dropped.Add(uint64(bytes.Count(body, []byte("\n"))))
+		}
+		os.Remove(path)
+	}
+	w.spooled.Store(false)
+	return nil
+}
+
+// Sync 立即发送内存中的日志，返回发送时遇到的错误（日志已经落盘时同样返回错误）。
+func (w *HTTPWriter) Sync() error {
+	ch := make(chan error, 1)
+	select {
+	case w.flush <- ch:
+		return <-ch
+	case <-w.done:
+		return nil
+	}
+}
+
+// Close 停止接收日志，发送内存中的日志（失败时落盘）后停止后台协程，返回最近一次发送的错误。
+// 重复调用 Close 返回 nil。
+func (w *HTTPWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+	close(w.stop)
+	<-w.done
+	return w.err
+}
+
+// Dropped 返回被丢弃的日志条数：内存已满、服务拒绝、没有配置 SpoolDir 时发送失败以及落盘文件超过大小上限。
+func (w *HTTPWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
@@ -0,0 +1,280 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sinkServer 是记录收到的请求、按照 status 返回状态码的 HTTP 日志服务。
+type sinkServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	status []int // 依次返回的状态码，用完后重复最后一个
+	bodies []string
+	times  []time.Time
+}
+
+func newSinkServer(t *testing.T, status ...int) *sinkServer {
+	s := &sinkServer{status: status}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		code := s.status[0]
+		if len(s.status) > 1 {
+			s.status = s.status[1:]
+		}
+		s.bodies = append(s.bodies, string(body))
+		s.times = append(s.times, time.Now())
+		s.mu.Unlock()
+		if r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("unexpected content type %q", r.Header.Get("Content-Type"))
+		}
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// setStatus 替换之后返回的状态码。
+func (s *sinkServer) setStatus(status ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+// requests 返回收到的请求体以及收到请求的时间。
+func (s *sinkServer) requests() ([]string, []time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.bodies...), append([]time.Time(nil), s.times...)
+}
+
+// messages 返回 NDJSON 请求体中每条日志的 message 字段。
+func messages(t *testing.T, body string) []string {
+	t.Helper()
+	var result []string
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+		var obj map[string]string
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			t.Fatalf("invalid NDJSON line %q: %v", line, err)
+		}
+		result = append(result, obj["message"])
+	}
+	return result
+}
+
+func spoolCount(t *testing.T, dir string) int {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), spoolSuffix) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestHTTPWriterRetry(t *testing.T) {
+	srv := newSinkServer(t, http.StatusInternalServerError, http.StatusOK)
+	w, err := NewHTTPWriter(srv.URL, HTTPOptions{MinBackoff: 20 * time.Millisecond, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.WriteLevel(LevelInfo, []byte("hello\n"))
+	w.Write([]byte(`{"message":"raw","k":1}` + "\n"))
+	if err := w.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	bodies, times := srv.requests()
+	if len(bodies) != 2 || bodies[0] != bodies[1] {
+		t.Fatalf("expected the batch to be retried once, got %q", bodies)
+	}
+	if gap := times[1].Sub(times[0]); gap < 20*time.Millisecond {
+		t.Fatalf("retry sent after %v, want at least the backoff", gap)
+	}
+	// 非 JSON 的日志包装为 JSON 对象，JSON 格式的日志原样发送
+	if want := "{\"level\":\"INFO\",\"message\":\"hello\"}\n{\"message\":\"raw\",\"k\":1}\n"; bodies[1] != want {
+		t.Fatalf("got body %q, want %q", bodies[1], want)
+	}
+	if w.Dropped() != 0 {
+		t.Fatalf("dropped %d", w.Dropped())
+	}
+}
+
+func TestHTTPWriterBackoff(t *testing.T) {
+	srv := newSinkServer(t, http.StatusServiceUnavailable)
+	w, err := NewHTTPWriter(srv.URL, HTTPOptions{
+		MaxRetries:    3,
+		MinBackoff:    20 * time.Millisecond,
+		MaxBackoff:    30 * time.Millisecond,
+		FlushInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.WriteLevel(LevelError, []byte("lost\n"))
+	if err := w.Sync(); err == nil {
+		t.Fatal("expected an error after all retries failed")
+	}
+
+	// 每次重试前的等待时间翻倍，不超过 MaxBackoff
+	_, times := srv.requests()
+	if len(times) != 4 {
+		t.Fatalf("got %d requests, want 4", len(times))
+	}
+	for i, min := range []time.Duration{20 * time.Millisecond, 30 * time.Millisecond, 30 * time.Millisecond} {
+		if gap := times[i+1].Sub(times[i]); gap < min {
+			t.Fatalf("retry %d sent after %v, want at least %v", i+1, gap, min)
+		}
+	}
+	// 没有配置 SpoolDir 时发送失败的日志被丢弃
+	if w.Dropped() != 1 {
+		t.Fatalf("dropped %d, want 1", w.Dropped())
+	}
+}
+
+func TestHTTPWriterSpool(t *testing.T) {
+	srv := newSinkServer(t, http.StatusBadGateway)
+	dir := t.TempDir()
+	w, err := NewHTTPWriter(srv.URL, HTTPOptions{MaxRetries: -1, SpoolDir: dir, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// 服务不可用时每批日志落盘
+	for _, msg := range []string{"a", "b"} {
+		w.WriteLevel(LevelInfo, []byte(msg))
+		if err := w.Sync(); err == nil {
+			t.Fatal("expected an error while the sink is down")
+		}
+	}
+	if n := spoolCount(t, dir); n != 2 {
+		t.Fatalf("got %d spool files, want 2", n)
+	}
+
+	// 服务恢复后先发送新的日志，再按写入顺序重新发送落盘的日志
+	srv.setStatus(http.StatusOK)
+	w.WriteLevel(LevelInfo, []byte("c"))
+	if err := w.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	bodies, _ := srv.requests()
+	var got []string
+	for _, body := range bodies[2:] {
+		got = append(got, messages(t, body)...)
+	}
+	if strings.Join(got, ",") != "c,a,b" {
+		t.Fatalf("got messages %v after recovery, want c,a,b", got)
+	}
+	if n := spoolCount(t, dir); n != 0 || w.Dropped() != 0 {
+		t.Fatalf("%d spool files left, dropped %d", n, w.Dropped())
+	}
+}
+
+func TestHTTPWriterResendOnStart(t *testing.T) {
+	dir := t.TempDir()
+	down := newSinkServer(t, http.StatusInternalServerError)
+	w, err := NewHTTPWriter(down.URL, HTTPOptions{MaxRetries: -1, SpoolDir: dir, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteLevel(LevelInfo, []byte("left over"))
+	// Close 时发送失败的日志落盘，并返回发送的错误
+	if err := w.Close(); err == nil {
+		t.Fatal("Close should return the send error")
+	}
+	if n := spoolCount(t, dir); n != 1 {
+		t.Fatalf("got %d spool files, want 1", n)
+	}
+
+	// 重新启动后发送上次遗留的文件
+	up := newSinkServer(t, http.StatusOK)
+	w, err = NewHTTPWriter(up.URL, HTTPOptions{SpoolDir: dir, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	bodies, _ := up.requests()
+	if len(bodies) != 1 || messages(t, bodies[0])[0] != "left over" || spoolCount(t, dir) != 0 {
+		t.Fatalf("left over logs not resent: %q", bodies)
+	}
+}
+
+func TestHTTPWriterRejected(t *testing.T) {
+	srv := newSinkServer(t, http.StatusBadRequest, http.StatusOK)
+	dir := t.TempDir()
+	w, err := NewHTTPWriter(srv.URL, HTTPOptions{SpoolDir: dir, MinBackoff: time.Millisecond, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// 4xx 表示日志本身有问题：不重试、不落盘，计入 Dropped，后续的日志正常发送
+	w.Write([]byte("bad 1\nbad 2\n"))
+	if err := w.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if bodies, _ := srv.requests(); len(bodies) != 1 {
+		t.Fatalf("rejected batch should not be retried, got %d requests", len(bodies))
+	}
+	if w.Dropped() != 2 || spoolCount(t, dir) != 0 {
+		t.Fatalf("dropped %d, %d spool files", w.Dropped(), spoolCount(t, dir))
+	}
+	w.Write([]byte("good\n"))
+	if err := w.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if bodies, _ := srv.requests(); len(bodies) != 2 || messages(t, bodies[1])[0] != "good" {
+		t.Fatalf("unexpected requests %q", bodies)
+	}
+}
+
+func TestHTTPWriterClosed(t *testing.T) {
+	srv := newSinkServer(t, http.StatusOK)
+	w, err := NewHTTPWriter(srv.URL, HTTPOptions{BatchSize: 2, MaxPending: 3, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 超过 MaxPending 的日志被丢弃
+	w.Write([]byte("1\n2\n3\n4\n5\n"))
+	if w.Dropped() != 2 {
+		t.Fatalf("dropped %d, want 2", w.Dropped())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Close 发送内存中的日志
+	var got []string
+	bodies, _ := srv.requests()
+	for _, body := range bodies {
+		got = append(got, messages(t, body)...)
+	}
+	if strings.Join(got, ",") != "1,2,3" {
+		t.Fatalf("got %v after close", got)
+	}
+	if _, err := w.Write([]byte("3\n")); !errors.Is(err, ErrWriterClosed) {
+		t.Fatalf("expected ErrWriterClosed, got %v", err)
+	}
+	if err := w.Close(); err != nil || w.Sync() != nil {
+		t.Fatal("Close and Sync after close should return nil")
+	}
+}
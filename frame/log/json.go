@@ -6,31 +6,84 @@ import (
 	"time"
 )
 
-// JsonFormatter json格式化输出
+// JsonFormatter 的默认字段名和时间格式
+const (
+	DefaultJsonTimeKey    = "log_time"
+	DefaultJsonLevelKey   = "log_level"
+	DefaultJsonMessageKey = "msg"
+	DefaultTimeFormat     = "2006/01/02 - 15:04:05"
+)
+
+// JsonFormatter json格式化输出，与时间、级别、消息、调用位置等固定字段同名的日志字段记录为 "fields.字段名"，
+// 无法序列化为 JSON 的字段值记录为 %v 格式化的字符串。
 type JsonFormatter struct {
 	TimeDisplay bool
+	TimeKey     string   // 时间的字段名，默认 DefaultJsonTimeKey
+	LevelKey    string   // 日志级别的字段名，默认 DefaultJsonLevelKey
+	MessageKey  string   // 日志消息的字段名，默认 DefaultJsonMessageKey
+	TimeFormat  string   // 时间格式，Go 的时间布局（例如 time.RFC3339Nano）或 TimeEpochMillis，默认 DefaultTimeFormat
+	Flatten     bool     // 将嵌套的字段（例如 Fields{"user": Fields{"id": 1}}）展开为 {"user.id": 1}
+	Redact      []string // 需要脱敏的字段名，不区分大小写，嵌套的字段同样生效，例如 DefaultRedactKeys
 }
 
 func (f *JsonFormatter) Format(param *LoggingFormatParam) string {
-	// 判断日志字段是否为空
-	if param.LoggerFields == nil {
-		// 创建一个空的字段
-		param.LoggerFields = make(Fields)
+	// 复制日志字段并脱敏，LoggerFields 被多条日志共享，不能直接修改
+	fields := cleanFields(param.LoggerFields, f.Flatten, newRedactor(f.Redact))
+	timeKey := orDefault(f.TimeKey, DefaultJsonTimeKey)
+	messageKey := orDefault(f.MessageKey, DefaultJsonMessageKey)
+	levelKey := orDefault(f.LevelKey, DefaultJsonLevelKey)
+	reserved := reservedKeys(param, messageKey, levelKey)
+	if f.TimeDisplay {
+		reserved = append(reserved, timeKey)
 	}
+	renameClashes(fields, reserved)
 
 	now := time.Now()
 	// 判断是否需要显示时间
 	if f.TimeDisplay {
-		param.LoggerFields["log_time"] = now.Format("2006/01/02 - 15:04:05")
+		fields[timeKey] = formatTime(now, orDefault(f.TimeFormat, DefaultTimeFormat))
 	}
-	// 添加日志级别和消息
-	param.LoggerFields["msg"] = param.Msg
+	// 添加日志消息，error 类型的消息记录错误信息
+	msg := param.Msg
+	if err, ok := msg.(error); ok {
+		msg = err.Error()
+	}
+	fields[messageKey] = msg
 	// 添加日志级别
-	param.LoggerFields["log_level"] = param.Level.Level()
+	fields[levelKey] = param.Level.Level()
+	// 添加调用位置和调用栈
+	if param.Caller != "" {
+		fields["caller"] = param.Caller
+		fields["func"] = param.Function
+	}
+	if param.Stack != "" {
+		fields["stack"] = param.Stack
+	}
 	// 将字段转换为 JSON 字符串
-	marshal, err := json.Marshal(param.LoggerFields)
+	marshal, err := json.Marshal(fields)
 	if err != nil {
-		panic(err)
+		// 无法序列化的值（例如 chan、func、NaN）记录为 %v 的形式，不影响其它字段
+		marshal, _ = json.Marshal(printableFields(fields))
+	}
+	return string(marshal)
+}
+
+// printableFields 返回 fields 的副本，其中无法序列化为 JSON 的值替换为 %v 格式化的字符串。
+func printableFields(fields Fields) Fields {
+	out := make(Fields, len(fields))
+	for k, v := range fields {
+		if _, err := json.Marshal(v); err != nil {
+			v = fmt.Sprintf("%v", v)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// orDefault 在 s 为空时返回 def。
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
 	}
-	return fmt.Sprintf("%s", string(marshal))
+	return s
 }
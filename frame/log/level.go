@@ -0,0 +1,60 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ParseLevel 将日志级别的名称（不区分大小写，例如 info、WARN）转换为 LoggerLevel。
+func ParseLevel(name string) (LoggerLevel, error) {
+	for level := LevelTrace; level <= LevelFatal; level++ {
+		if strings.EqualFold(name, level.Level()) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("log: unknown level %q", name)
+}
+
+// levelBody 是 LevelHandler 的请求体和响应体。
+type levelBody struct {
+	Level string `json:"level"`
+}
+
+// LevelHandler 返回在运行时查看、修改日志级别的 http.Handler：
+//
+//	GET  返回当前的日志级别，例如 {"level":"INFO"}
+//	PUT、POST 通过 level 参数（查询参数或表单）或者 JSON 请求体 {"level":"debug"} 修改日志级别
+//
+// 该接口可以改变线上服务的日志量，挂载时需要配合 BasicAuth 等中间件限制访问。
+func (l *Logger) LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			name := r.FormValue("level")
+			if name == "" {
+				var body levelBody
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				name = body.Level
+			}
+			level, err := ParseLevel(name)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			l.SetLevel(level)
+			l.Print(LevelInfo, "log level changed to "+level.Level())
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(levelBody{Level: l.Level().Level()})
+	})
+}
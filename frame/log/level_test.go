@@ -0,0 +1,133 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// syncBuffer 是可以被多个协程同时写入的 bytes.Buffer。
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestLoggerConcurrentSettings(t *testing.T) {
+	l := New()
+	l.Formatter = &TextFormatter{}
+	first := &syncBuffer{}
+	l.AddOut(&LoggerWriter{Level: -1, Out: first})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		out := &syncBuffer{}
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				l.SetLevel(LoggerLevel(j % 3))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			l.AddOut(&LoggerWriter{Level: -1, Out: out})
+		}()
+		go func(i int) {
+			defer wg.Done()
+			child := l.WithFields(Fields{"worker": i})
+			for j := 0; j < 100; j++ {
+				child.Error("failed")
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if len(l.Outs()) == 0 {
+					t.Error("outputs should never be empty")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := len(l.Outs()); n != 5 {
+		t.Fatalf("got %d outputs, want 5", n)
+	}
+	// WithFields 创建的 Logger 共享日志级别
+	l.SetLevel(LevelError)
+	child := l.WithFields(Fields{"k": "v"})
+	child.Warn("filtered")
+	if child.Level() != LevelError || strings.Contains(first.String(), "filtered") {
+		t.Fatal("child logger should share the level")
+	}
+	if n := strings.Count(first.String(), "failed"); n != 400 {
+		t.Fatalf("got %d messages, want 400", n)
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	l := New()
+	l.SetLevel(LevelInfo)
+	h := l.LevelHandler()
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if strings.HasPrefix(body, "level=") {
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	level := func(w *httptest.ResponseRecorder) string {
+		var body levelBody
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+		}
+		return body.Level
+	}
+
+	if w := do(http.MethodGet, "/", ""); w.Code != http.StatusOK || level(w) != "INFO" {
+		t.Fatalf("GET: %d %q", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPut, "/?level=debug", ""); w.Code != http.StatusOK || level(w) != "DEBUG" || l.Level() != LevelDebug {
+		t.Fatalf("PUT query: %d %q", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, "/", "level=warn"); w.Code != http.StatusOK || l.Level() != LevelWarn {
+		t.Fatalf("POST form: %d %q", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPut, "/", `{"level":"error"}`); w.Code != http.StatusOK || level(w) != "ERROR" || l.Level() != LevelError {
+		t.Fatalf("PUT json: %d %q", w.Code, w.Body.String())
+	}
+
+	for _, body := range []string{`{"level":"loud"}`, `not json`} {
+		if w := do(http.MethodPut, "/", body); w.Code != http.StatusBadRequest {
+			t.Fatalf("PUT %q: got %d, want 400", body, w.Code)
+		}
+	}
+	if w := do(http.MethodPost, "/?level=loud", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("POST invalid level: got %d, want 400", w.Code)
+	}
+	if w := do(http.MethodDelete, "/", ""); w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, PUT, POST" {
+		t.Fatalf("DELETE: got %d, Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+	if l.Level() != LevelError {
+		t.Fatalf("rejected requests should not change the level, got %s", l.Level().Level())
+	}
+}
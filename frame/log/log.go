@@ -2,12 +2,12 @@ package log
 
 import (
 	"fmt"
-	"frame/internal/string_func"
 	"io"
 	"log"
 	"os"
 	"path"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -36,36 +36,62 @@ type LoggerLevel int
 // Level 返回日志级别的字符串表示形式
 func (l LoggerLevel) Level() string {
 	switch l {
+	case LevelTrace:
+		return "TRACE"
 	case LevelDebug:
 		return "DEBUG"
 	case LevelInfo:
 		return "INFO"
+	case LevelWarn:
+		return "WARN"
 	case LevelError:
 		return "ERROR"
+	case LevelPanic:
+		return "PANIC"
+	case LevelFatal:
+		return "FATAL"
 	default:
 		return ""
 	}
 }
 
-// 日志级别常量
+// 日志级别常量，级别越高越严重
 const (
 	// 使用 LoggerLevel 定义日志级别
-	LevelDebug LoggerLevel = iota
+	LevelTrace LoggerLevel = iota
+	LevelDebug
 	LevelInfo
+	LevelWarn
 	LevelError
+	LevelPanic
+	LevelFatal
 )
 
 // Fields 是一个键值对集合，用于存储日志字段
 type Fields map[string]any
 
-// Logger 是日志记录器结构体
+// Logger 是日志记录器结构体，可以被多个协程同时使用。
+// Formatter、LogFileSize、Rotate、ReportCaller、StackTrace 需要在使用前设置；日志级别和输出目标保存在 WithFields 创建的 Logger 共享的状态中，
+// 通过 SetLevel、AddOut 等方法修改，记录日志时只读取它们的快照，不需要加锁。
 type Logger struct {
 	Formatter    LoggingFormatter // 日志格式化接口
-	Level        LoggerLevel      // 日志级别
-	Outs         []*LoggerWriter  // 输出目标列表
-	LoggerFields Fields           // 日志字段
-	logPath      string           // 日志文件路径
-	LogFileSize  int64            // 单个日志文件的最大大小
+	LoggerFields Fields           // 日志字段，记录日志时只读
+	LogFileSize  int64            // 单个日志文件的最大大小，Rotate.MaxSize 为 0 时使用
+	Rotate       RotateOptions    // SetLogPath 创建的日志文件的切割配置
+	ReportCaller bool             // 是否记录调用日志方法的文件、行号和函数
+	StackTrace   bool             // 是否为 ERROR 及以上级别的日志记录调用栈
+	core         *loggerCore      // 与 WithFields 创建的 Logger 共享的状态
+}
+
+// loggerCore 是 Logger 与 WithFields 创建的 Logger 共享的状态。
+// 日志级别和输出目标使用原子操作读取，修改输出目标时复制整个列表后替换（copy-on-write），由 mu 串行化。
+type loggerCore struct {
+	level   atomic.Int32                    // 日志级别
+	outs    atomic.Pointer[[]*LoggerWriter] // 输出目标列表，替换后不再修改
+	mu      sync.Mutex                      // 串行化对输出目标的修改
+	logPath string                          // 日志文件路径
+	async   *AsyncOptions                   // 异步写入的配置，为 nil 时同步写入
+	filter  filter                          // 采样和限流
 }
 
 // LoggerWriter 表示日志输出目标
@@ -83,8 +109,11 @@ type LoggingFormatter interface {
 type LoggingFormatParam struct {
 	Level        LoggerLevel // 日志级别
 	IsColor      bool        // 是否使用颜色
-	LoggerFields Fields      // 日志字段
+	LoggerFields Fields      // 日志字段，多条日志共享，格式化时不能修改
 	Msg          any         // 日志消息
+	Caller       string      // 调用日志方法的位置，格式为 目录/文件:行号，没有开启 ReportCaller 时为空
+	Function     string      // 调用日志方法的函数，没有开启 ReportCaller 时为空
+	Stack        string      // 调用栈，只在开启 StackTrace 时 ERROR 及以上级别的日志中记录
 }
 
 // LoggerFormatter 实现了 LoggingFormatter 接口
@@ -94,28 +123,61 @@ type LoggerFormatter struct {
 	LoggerFields Fields      // 日志字段
 }
 
-// New 创建一个新的 Logger 实例
+// New 创建一个新的 Logger 实例，日志级别为 LevelTrace，没有输出目标
 func New() *Logger {
-	return &Logger{}
+	core := &loggerCore{}
+	core.outs.Store(&[]*LoggerWriter{})
+	return &Logger{core: core}
 }
 
 // Default 创建并返回一个带有默认配置的 Logger 实例
 func Default() *Logger {
 	logger := New()
-	logger.Level = LevelDebug
-	w := &LoggerWriter{
+	logger.SetLevel(LevelDebug)
+	logger.AddOut(&LoggerWriter{
 		Level: LevelDebug,
 		Out:   os.Stdout,
-	}
-	logger.Outs = append(logger.Outs, w)
+	})
 	logger.Formatter = &TextFormatter{} // 假设 TextFormatter 是一个实现了 LoggingFormatter 的结构体
 	return logger
 }
 
-// Info 记录一条 INFO 级别的日志
-func (l *Logger) Info(msg any) {
-	// 调用 Print 方法记录一条 INFO 级别的日志
-	l.Print(LevelInfo, msg)
+// Level 返回当前的日志级别
+func (l *Logger) Level() LoggerLevel {
+	return LoggerLevel(l.core.level.Load())
+}
+
+// SetLevel 设置日志级别，低于该级别的日志不会被记录。可以在运行时调用，对 WithFields 创建的 Logger 同样生效。
+func (l *Logger) SetLevel(level LoggerLevel) {
+	l.core.level.Store(int32(level))
+}
+
+// Outs 返回输出目标列表的快照
+func (l *Logger) Outs() []*LoggerWriter {
+	return append([]*LoggerWriter(nil), *l.core.outs.Load()...)
+}
+
+// AddOut 添加输出目标，调用了 SetAsync 时输出目标同样异步写入。可以在运行时调用。
+func (l *Logger) AddOut(outs ...*LoggerWriter) {
+	l.core.mu.Lock()
+	defer l.core.mu.Unlock()
+	old := *l.core.outs.Load()
+	updated := make([]*LoggerWriter, len(old), len(old)+len(outs))
+	copy(updated, old)
+	for _, out := range outs {
+		updated = append(updated, &LoggerWriter{Level: out.Level, Out: l.writer(out.Out)})
+	}
+	l.core.outs.Store(&updated)
+}
+
+// Trace 记录一条 TRACE 级别的日志
+func (l *Logger) Trace(msg any) {
+	l.Print(LevelTrace, msg)
+}
+
+// Tracef 按照 format 格式化后记录一条 TRACE 级别的日志
+func (l *Logger) Tracef(format string, args ...any) {
+	l.printf(LevelTrace, format, args...)
 }
 
 // Debug 记录一条 DEBUG 级别的日志
@@ -124,119 +186,275 @@ func (l *Logger) Debug(msg any) {
 	l.Print(LevelDebug, msg)
 }
 
+// Debugf 按照 format 格式化后记录一条 DEBUG 级别的日志
+func (l *Logger) Debugf(format string, args ...any) {
+	l.printf(LevelDebug, format, args...)
+}
+
+// Info 记录一条 INFO 级别的日志
+func (l *Logger) Info(msg any) {
+	// 调用 Print 方法记录一条 INFO 级别的日志
+	l.Print(LevelInfo, msg)
+}
+
+// Infof 按照 format 格式化后记录一条 INFO 级别的日志
+func (l *Logger) Infof(format string, args ...any) {
+	l.printf(LevelInfo, format, args...)
+}
+
+// Warn 记录一条 WARN 级别的日志
+func (l *Logger) Warn(msg any) {
+	l.Print(LevelWarn, msg)
+}
+
+// Warnf 按照 format 格式化后记录一条 WARN 级别的日志
+func (l *Logger) Warnf(format string, args ...any) {
+	l.printf(LevelWarn, format, args...)
+}
+
 // Error 记录一条 ERROR 级别的日志
 func (l *Logger) Error(msg any) {
 	// 调用 Print 方法记录一条 ERROR 级别的日志
 	l.Print(LevelError, msg)
 }
 
+// Errorf 按照 format 格式化后记录一条 ERROR 级别的日志
+func (l *Logger) Errorf(format string, args ...any) {
+	l.printf(LevelError, format, args...)
+}
+
+// Panic 记录一条 PANIC 级别的日志，然后以 msg 抛出 panic
+func (l *Logger) Panic(msg any) {
+	l.Print(LevelPanic, msg)
+	panic(msg)
+}
+
+// Panicf 按照 format 格式化后记录一条 PANIC 级别的日志，然后以格式化后的消息抛出 panic
+func (l *Logger) Panicf(format string, args ...any) {
+	l.Panic(fmt.Sprintf(format, args...))
+}
+
+// Fatal 记录一条 FATAL 级别的日志，写出并关闭所有输出目标后以状态码 1 退出程序
+func (l *Logger) Fatal(msg any) {
+	l.Print(LevelFatal, msg)
+	l.Close()
+	os.Exit(1)
+}
+
+// Fatalf 按照 format 格式化后记录一条 FATAL 级别的日志，然后退出程序
+func (l *Logger) Fatalf(format string, args ...any) {
+	l.Fatal(fmt.Sprintf(format, args...))
+}
+
+// printf 在日志被记录时才格式化消息，避免被过滤的日志产生格式化的开销。采样时使用 format 作为消息键。
+func (l *Logger) printf(level LoggerLevel, format string, args ...any) {
+	if !l.allow(level, nil, format) {
+		return
+	}
+	l.write(level, fmt.Sprintf(format, args...))
+}
+
 // Print 根据指定的日志级别和消息打印日志
 func (l *Logger) Print(level LoggerLevel, msg any) {
+	// 检查日志级别、采样和限流，被过滤的日志不打印
+	if !l.allow(level, msg, "") {
+		return
+	}
+	l.write(level, msg)
+}
+
+// allow 判断一条日志是否被记录：级别不低于当前的日志级别，并且没有被采样或者限流丢弃
+func (l *Logger) allow(level LoggerLevel, msg any, key string) bool {
 	// 检查当前日志级别是否高于输入级别
-	if l.Level > level {
-		// 如果当前日志级别高于输入级别，则不打印日志
+	if l.Level() > level {
+		return false
+	}
+	return l.core.filter.allow(level, msg, key)
+}
+
+// write 格式化日志并写入所有输出目标，不检查日志级别
+func (l *Logger) write(level LoggerLevel, msg any) {
+	outs := *l.core.outs.Load()
+	if len(outs) == 0 {
 		return
 	}
-	// 创建 LoggingFormatParam
 	param := &LoggingFormatParam{
 		Level:        level,
 		LoggerFields: l.LoggerFields,
 		Msg:          msg,
 	}
-	// 使用 Formatter 格式化日志信息
-	str := l.Formatter.Format(param)
-	// 遍历所有输出目的地
-	for _, out := range l.Outs {
-		// 如果输出目的地是标准输出，则启用颜色模式
-		if out.Out == os.Stdout {
-			param.IsColor = true
-			// 重新格式化日志信息以应用颜色模式
-			str = l.Formatter.Format(param)
-			fmt.Fprintln(out.Out, str)
+	// 记录调用位置和调用栈
+	if l.ReportCaller {
+		param.Caller, param.Function = caller()
+	}
+	if l.StackTrace && level >= LevelError {
+		param.Stack = stack()
+	}
+	// 分别缓存带颜色和不带颜色的格式化结果，只在需要时格式化
+	var plain, colored string
+	// 遍历所有输出目的地的快照，期间添加的输出目标不影响本次输出
+	for _, out := range outs {
+		// 如果输出目的地是标准输出，则使用颜色模式输出所有级别的日志
+		if underlying(out.Out) == os.Stdout {
+			if colored == "" {
+				colored = l.format(*param, true)
+			}
+			writeEntry(out.Out, level, colored)
+			continue
 		}
 		// 如果输出级别的设置为 -1 或与当前日志级别相同，则打印日志
 		if out.Level == -1 || level == out.Level {
-			fmt.Fprintln(out.Out, str)
-			// 检查并可能处理日志文件的大小
-			l.CheckFileSize(out)
+			if plain == "" {
+				plain = l.format(*param, false)
+			}
+			writeEntry(out.Out, level, plain)
 		}
 	}
+}
 
+// writeEntry 将一条日志以换行结尾写入输出流，输出流是 LevelWriter 时同时传入日志级别
+func writeEntry(w io.Writer, level LoggerLevel, entry string) {
+	if lw, ok := w.(LevelWriter); ok {
+		lw.WriteLevel(level, []byte(entry+"\n"))
+		return
+	}
+	fmt.Fprintln(w, entry)
 }
 
-// WithFields 返回一个新的 Logger 实例，并添加额外的日志字段
+// format 使用 Formatter 格式化一条日志，每次格式化使用参数的副本，Formatter 可以修改参数
+func (l *Logger) format(param LoggingFormatParam, color bool) string {
+	param.IsColor = color
+	return l.Formatter.Format(&param)
+}
+
+// WithFields 返回一个新的 Logger 实例，日志字段为当前的字段加上 fields（同名时使用 fields 中的值）。
+// 新的 Logger 复制日志字段，与当前 Logger 共享日志级别和输出目标。
 func (l *Logger) WithFields(fields Fields) *Logger {
+	merged := make(Fields, len(l.LoggerFields)+len(fields))
+	for k, v := range l.LoggerFields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
 	return &Logger{
 		Formatter:    l.Formatter,
-		Outs:         l.Outs,
-		Level:        l.Level,
-		LoggerFields: fields,
+		LoggerFields: merged,
+		LogFileSize:  l.LogFileSize,
+		Rotate:       l.Rotate,
+		ReportCaller: l.ReportCaller,
+		StackTrace:   l.StackTrace,
+		core:         l.core,
+	}
+}
+
+// SetAsync 将所有输出目标切换为异步写入（参见 AsyncWriter），之后添加的输出目标同样异步写入。
+// 异步写入时程序退出前需要调用 Close，Engine 的 Run、Shutdown 会自动调用。
+func (l *Logger) SetAsync(opts AsyncOptions) {
+	l.core.mu.Lock()
+	defer l.core.mu.Unlock()
+	l.core.async = &opts
+	old := *l.core.outs.Load()
+	updated := make([]*LoggerWriter, len(old))
+	for i, out := range old {
+		updated[i] = &LoggerWriter{Level: out.Level, Out: l.writer(out.Out)}
+	}
+	l.core.outs.Store(&updated)
+}
+
+// writer 根据是否异步写入包装输出流，调用方需要持有 core.mu。
+func (l *Logger) writer(w io.Writer) io.Writer {
+	if _, ok := w.(*AsyncWriter); ok || l.core.async == nil {
+		return w
 	}
+	return NewAsyncWriter(w, *l.core.async)
 }
 
-// SetLogPath 设置日志文件路径，并初始化多个日志文件输出
+// Sync 将所有输出目标中缓冲的日志写出并同步到磁盘，返回遇到的第一个错误。
+func (l *Logger) Sync() error {
+	var err error
+	for _, out := range *l.core.outs.Load() {
+		if serr := syncWriter(out.Out); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// Close 写出所有输出目标中缓冲的日志并关闭输出目标（标准输出、标准错误除外），返回遇到的第一个错误。
+// 开启了 ReportDropped 时先报告最后一个周期内被丢弃的日志条数。
+// 通过 WithFields 创建的 Logger 与原 Logger 共享输出目标，只需要关闭其中一个。
+func (l *Logger) Close() error {
+	l.stopReport()
+	var err error
+	for _, out := range *l.core.outs.Load() {
+		if cerr := closeWriter(out.Out); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// SetLogPath 设置日志文件路径，并初始化多个日志文件输出。
+// 日志文件按照 Rotate 配置自动切割（参见 RotateWriter），需要在调用 SetLogPath 之前设置 Rotate。
 func (l *Logger) SetLogPath(logPath string) {
 	// 设置日志路径并初始化不同级别的日志输出
 	// logPath 是日志文件的目录路径
-	l.logPath = logPath
-
-	// 添加记录所有级别日志的输出
-	l.Outs = append(l.Outs, &LoggerWriter{
-		Level: -1, // -1 表示记录所有级别的日志
-		Out:   FileWriter(path.Join(logPath, "all.log")),
-	})
-
-	// 添加记录调试级别日志的输出
-	l.Outs = append(l.Outs, &LoggerWriter{
-		Level: LevelDebug, // 仅记录调试级别的日志
-		Out:   FileWriter(path.Join(logPath, "debug.log")),
-	})
+	l.core.mu.Lock()
+	l.core.logPath = logPath
+	l.core.mu.Unlock()
 
-	// 添加记录信息级别日志的输出
-	l.Outs = append(l.Outs, &LoggerWriter{
-		Level: LevelInfo, // 仅记录信息级别的日志
-		Out:   FileWriter(path.Join(logPath, "info.log")),
-	})
+	l.AddOut(
+		// 添加记录所有级别日志的输出
+		&LoggerWriter{
+			Level: -1, // -1 表示记录所有级别的日志
+			Out:   l.rotateWriter(path.Join(logPath, "all.log")),
+		},
+		// 添加记录调试级别日志的输出
+		&LoggerWriter{
+			Level: LevelDebug, // 仅记录调试级别的日志
+			Out:   l.rotateWriter(path.Join(logPath, "debug.log")),
+		},
+		// 添加记录信息级别日志的输出
+		&LoggerWriter{
+			Level: LevelInfo, // 仅记录信息级别的日志
+			Out:   l.rotateWriter(path.Join(logPath, "info.log")),
+		},
+		// 添加记录错误级别日志的输出
+		&LoggerWriter{
+			Level: LevelError, // 仅记录错误级别的日志
+			Out:   l.rotateWriter(path.Join(logPath, "error.log")),
+		},
+	)
+}
 
-	// 添加记录错误级别日志的输出
-	l.Outs = append(l.Outs, &LoggerWriter{
-		Level: LevelError, // 仅记录错误级别的日志
-		Out:   FileWriter(path.Join(logPath, "error.log")),
-	})
+// rotateWriter 按照 Rotate 配置创建切割日志文件的输出流，MaxSize 为 0 时使用 LogFileSize，
+// 两者都没有设置时为 DefaultMaxSize，小于 0 时不按大小切割。创建失败时抛出 panic，与 FileWriter 一致。
+func (l *Logger) rotateWriter(name string) io.Writer {
+	opts := l.Rotate
+	if opts.MaxSize == 0 {
+		opts.MaxSize = l.LogFileSize
+	}
+	if opts.MaxSize == 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	w, err := NewRotateWriter(name, opts)
+	if err != nil {
+		panic(err)
+	}
+	return w
 }
 
-// CheckFileSize 检查日志文件大小，如果超过限制则创建新的日志文件
+// CheckFileSize 检查日志文件大小，如果超过限制则创建新的日志文件。
+//
+// Deprecated: SetLogPath 创建的日志文件由 RotateWriter 在写入时自动切割，不需要再调用 CheckFileSize。
+// 输出目标是 RotateWriter 时立即切割，其它输出目标不做处理。
 func (l *Logger) CheckFileSize(w *LoggerWriter) {
-	// 获取日志文件对象
-	logFile := w.Out.(*os.File)
-	// 检查日志文件是否有效
-	if logFile != nil {
-		// 获取日志文件的信息
-		stat, err := logFile.Stat()
-		if err != nil {
-			// 如果获取文件信息失败，则记录错误并退出
+	if r, ok := underlying(w.Out).(*RotateWriter); ok {
+		if err := r.Rotate(); err != nil {
 			log.Println(err)
-			return
-		}
-		// 获取日志文件的大小
-		size := stat.Size()
-		// 设置默认的最大日志文件大小为 100MB，如果未设置的话
-		if l.LogFileSize <= 0 {
-			l.LogFileSize = 100 << 20 // 默认最大日志文件大小为 100MB
-		}
-		// 检查当前日志文件大小是否超过了最大限制
-		if size >= l.LogFileSize {
-			// 获取当前日志文件的名称
-			_, name := path.Split(stat.Name())
-			// 提取文件名，去除扩展名
-			fileName := name[0:strings.Index(name, ".")]
-			// 创建一个新的日志文件写入对象，文件名基于原始文件名和当前时间戳
-			writer := FileWriter(path.Join(l.logPath, string_func.JoinStrings(fileName, ".", time.Now().UnixMilli(), ".log")))
-			// 更新输出流为新的日志文件
-			w.Out = writer
 		}
 	}
-
 }
 
 // format 格式化日志消息
@@ -257,26 +475,38 @@ func (f *LoggerFormatter) format(msg any) string {
 
 // LevelColor 根据日志级别返回相应的颜色代码
 func (f *LoggerFormatter) LevelColor() string {
-	switch f.Level {
+	return levelColor(f.Level)
+}
+
+// MsgColor 根据日志级别返回消息的颜色代码
+func (f *LoggerFormatter) MsgColor() string {
+	return msgColor(f.Level)
+}
+
+// levelColor 根据日志级别返回相应的颜色代码
+func levelColor(level LoggerLevel) string {
+	switch level {
 	case LevelDebug:
 		return blue
 	case LevelInfo:
 		return green
+	case LevelWarn:
+		return yellow
 	case LevelError:
 		return red
+	case LevelPanic, LevelFatal:
+		return magenta
 	default:
 		return cyan
 	}
 }
 
-// MsgColor 根据日志级别返回消息的颜色代码
-func (f *LoggerFormatter) MsgColor() string {
-	switch f.Level {
-	case LevelError:
+// msgColor 根据日志级别返回消息的颜色代码，ERROR 及以上级别的消息为红色
+func msgColor(level LoggerLevel) string {
+	if level >= LevelError {
 		return red
-	default:
-		return ""
 	}
+	return ""
 }
 
 // FileWriter 打开或创建一个日志文件，并返回 io.Writer
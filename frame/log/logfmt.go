@@ -0,0 +1,98 @@
+package log
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// LogfmtFormatter 的默认字段名
+const (
+	DefaultLogfmtTimeKey    = "ts"
+	DefaultLogfmtLevelKey   = "level"
+	DefaultLogfmtMessageKey = "msg"
+)
+
+// LogfmtFormatter 以 logfmt 格式（key=value，以空格分隔）输出日志，例如：
+//
+//	ts=2024-01-02T15:04:05.123+08:00 level=info msg="user login" caller=frame/ctx.go:12 request_id=abc user.id=1
+//
+// 时间、级别、消息、调用位置在前，其余字段按字段名排序；嵌套的字段展开为 "父字段.子字段"，
+// 与时间、级别等固定字段同名的字段记录为 "fields.字段名"。
+// 包含空格、等号、引号或控制字符的值使用双引号并转义，日志总是单行的，适合 NetWriter 等行协议的输出流。
+type LogfmtFormatter struct {
+	TimeKey    string   // 时间的字段名，默认 DefaultLogfmtTimeKey
+	LevelKey   string   // 日志级别的字段名，默认 DefaultLogfmtLevelKey
+	MessageKey string   // 日志消息的字段名，默认 DefaultLogfmtMessageKey
+	TimeFormat string   // 时间格式，Go 的时间布局或 TimeEpochMillis，默认 RFC3339 毫秒精度
+	Redact     []string // 需要脱敏的字段名，不区分大小写，例如 DefaultRedactKeys
+}
+
+// Format 格式化日志
+func (f *LogfmtFormatter) Format(param *LoggingFormatParam) string {
+	var sb strings.Builder
+	timeKey := orDefault(f.TimeKey, DefaultLogfmtTimeKey)
+	levelKey := orDefault(f.LevelKey, DefaultLogfmtLevelKey)
+	messageKey := orDefault(f.MessageKey, DefaultLogfmtMessageKey)
+	writeLogfmt(&sb, timeKey, formatTime(time.Now(), orDefault(f.TimeFormat, "2006-01-02T15:04:05.000Z07:00")))
+	writeLogfmt(&sb, levelKey, strings.ToLower(param.Level.Level()))
+	writeLogfmt(&sb, messageKey, param.Msg)
+	if param.Caller != "" {
+		writeLogfmt(&sb, "caller", param.Caller)
+		writeLogfmt(&sb, "func", param.Function)
+	}
+	fields := cleanFields(param.LoggerFields, true, newRedactor(f.Redact))
+	renameClashes(fields, reservedKeys(param, timeKey, levelKey, messageKey))
+	for _, k := range sortedKeys(fields) {
+		writeLogfmt(&sb, k, fields[k])
+	}
+	if param.Stack != "" {
+		writeLogfmt(&sb, "stack", param.Stack)
+	}
+	return sb.String()
+}
+
+// writeLogfmt 写入一个 key=value，key 中的空格、等号、引号替换为下划线，value 需要时加引号。
+func writeLogfmt(sb *strings.Builder, key string, value any) {
+	if sb.Len() > 0 {
+		sb.WriteByte(' ')
+	}
+	sb.WriteString(strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError {
+			return '_'
+		}
+		return r
+	}, key))
+	sb.WriteByte('=')
+	var s string
+	switch v := value.(type) {
+	case nil:
+		return
+	case string:
+		s = v
+	case error:
+		s = v.Error()
+	default:
+		s = fmt.Sprint(v)
+	}
+	if needsQuote(s) {
+		s = strconv.Quote(s)
+	}
+	sb.WriteString(s)
+}
+
+// needsQuote 判断 logfmt 的值是否需要加引号。
+func needsQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
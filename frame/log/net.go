@@ -0,0 +1,255 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDialTimeout 是网络输出流建立连接的默认超时时间。
+const DefaultDialTimeout = 5 * time.Second
+
+// netConn 是按需建立、出错后自动重连的网络连接。
+type netConn struct {
+	network string
+	addrs   []string // 依次尝试的地址，本地 syslog 有多个可能的套接字路径
+	timeout time.Duration
+	mu      sync.Mutex
+	conn    net.Conn
+	closed  bool
+}
+
+// dial 依次尝试所有地址建立连接，调用方需要持有 mu。
+func (c *netConn) dial() error {
+	var err error
+	for _, addr := range c.addrs {
+		var conn net.Conn
+		if conn, err = net.DialTimeout(c.network, addr, c.timeout); err == nil {
+			c.conn = conn
+			return nil
+		}
+	}
+	return err
+}
+
+// write 发送 b，连接不存在时建立连接；发送失败时关闭连接并重连一次后重试。
+func (c *netConn) write(b []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrWriterClosed
+	}
+	for attempt := 0; ; attempt++ {
+		if c.conn == nil {
+			if err := c.dial(); err != nil {
+				return err
+			}
+		}
+		c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+		_, err := c.conn.Write(b)
+		if err == nil {
+			return nil
+		}
+		c.conn.Close()
+		c.conn = nil
+		if attempt > 0 {
+			return err
+		}
+	}
+}
+
+// close 关闭连接，之后的写入返回 ErrWriterClosed。
+func (c *netConn) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// stream 判断连接是否为字节流（TCP、Unix 流套接字），字节流需要自行划分消息的边界。
+func (c *netConn) stream() bool {
+	switch c.network {
+	case "tcp", "tcp4", "tcp6", "unix":
+		return true
+	}
+	return false
+}
+
+// NetWriter 是以 TCP 或 UDP 发送日志的输出流，每条日志为一行，适用于 Logstash、Vector、Fluent Bit
+// 等接收行协议的服务。连接按需建立，发送失败时重连一次后重试，仍然失败时返回错误。
+// 日志中的换行会破坏行协议的边界，建议配合 JsonFormatter 等单行的格式使用。
+type NetWriter struct {
+	conn *netConn
+}
+
+// NewNetWriter 创建向 addr 发送日志的 NetWriter，network 为 tcp、udp 等 net.Dial 支持的网络。
+// 连接在第一次写入时建立。
+func NewNetWriter(network, addr string) *NetWriter {
+	return &NetWriter{conn: &netConn{network: network, addrs: []string{addr}, timeout: DefaultDialTimeout}}
+}
+
+// Write 发送日志。TCP 上 p 原样发送；UDP 上每一行为一个数据报。
+func (w *NetWriter) Write(p []byte) (int, error) {
+	if w.conn.stream() {
+		if err := w.conn.write(p); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if err := w.conn.write(line); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// WriteLevel 发送一条日志，UDP 上整条日志为一个数据报。
+func (w *NetWriter) WriteLevel(level LoggerLevel, p []byte) (int, error) {
+	if err := w.conn.write(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close 关闭连接。
+func (w *NetWriter) Close() error {
+	return w.conn.close()
+}
+
+// Facility 是 syslog 的设施（facility），见 RFC 5424 6.2.1。
+type Facility int
+
+// 常用的 syslog 设施
+const (
+	FacilityKern   Facility = 0
+	FacilityUser   Facility = 1
+	FacilityDaemon Facility = 3
+	FacilityAuth   Facility = 4
+	FacilityLocal0 Facility = 16
+	FacilityLocal1 Facility = 17
+	FacilityLocal2 Facility = 18
+	FacilityLocal3 Facility = 19
+	FacilityLocal4 Facility = 20
+	FacilityLocal5 Facility = 21
+	FacilityLocal6 Facility = 22
+	FacilityLocal7 Facility = 23
+)
+
+// SyslogOptions 是 SyslogWriter 的配置。
+type SyslogOptions struct {
+	Facility Facility // 设施，为 0（FacilityKern，应用程序不应使用）时使用 FacilityUser
+	AppName  string   // 应用名称，默认为程序文件名
+	Hostname string   // 主机名，默认为 os.Hostname
+	MsgID    string   // 消息类型，默认为 "-"
+}
+
+// syslogSockets 是本地 syslog 服务可能监听的 Unix 数据报套接字。
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// SyslogWriter 以 RFC 5424 格式向 syslog 服务发送日志，日志级别映射为 syslog 的严重程度。
+// TCP 连接上使用 RFC 6587 的长度前缀划分消息，UDP、Unix 套接字上每条日志为一个数据报。
+type SyslogWriter struct {
+	conn   *netConn
+	header string // 时间戳之后的固定头部：主机名、应用名称、进程号、消息类型以及空的结构化数据
+	opts   SyslogOptions
+}
+
+// NewSyslogWriter 创建向 syslog 服务发送日志的 SyslogWriter。
+// network 和 addr 为空时连接本地的 syslog 服务（/dev/log 等 Unix 套接字），否则为 udp、tcp 等网络和地址。
+// 连接在第一次写入时建立。
+func NewSyslogWriter(network, addr string, opts SyslogOptions) *SyslogWriter {
+	if opts.Facility == FacilityKern {
+		opts.Facility = FacilityUser
+	}
+	if opts.AppName == "" {
+		opts.AppName = filepath.Base(os.Args[0])
+	}
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+	if opts.MsgID == "" {
+		opts.MsgID = "-"
+	}
+	conn := &netConn{network: network, addrs: []string{addr}, timeout: DefaultDialTimeout}
+	if network == "" && addr == "" {
+		conn.network, conn.addrs = "unixgram", syslogSockets
+	}
+	header := fmt.Sprintf("%s %s %d %s -", syslogField(opts.Hostname, 255), syslogField(opts.AppName, 48), os.Getpid(), syslogField(opts.MsgID, 32))
+	return &SyslogWriter{conn: conn, header: header, opts: opts}
+}
+
+// syslogField 将 RFC 5424 头部字段限制为可打印的 ASCII 字符和最大长度，空值为 "-"。
+func syslogField(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, s)
+	if s == "" {
+		return "-"
+	}
+	if len(s) > max {
+		s = s[:max]
+	}
+	return s
+}
+
+// severity 返回日志级别对应的 syslog 严重程度，见 RFC 5424 6.2.1。
+func severity(level LoggerLevel) int {
+	switch level {
+	case LevelTrace, LevelDebug:
+		return 7 // debug
+	case LevelInfo:
+		return 6 // informational
+	case LevelWarn:
+		return 4 // warning
+	case LevelError:
+		return 3 // error
+	default:
+		return 2 // critical
+	}
+}
+
+// Write 以 INFO 级别发送一条日志。
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(LevelInfo, p)
+}
+
+// WriteLevel 以 level 对应的严重程度发送一条日志，日志末尾的换行会被去掉。
+func (w *SyslogWriter) WriteLevel(level LoggerLevel, p []byte) (int, error) {
+	msg := bytes.TrimRight(p, "\n")
+	pri := int(w.opts.Facility)*8 + severity(level)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<%d>1 %s %s ", pri, time.Now().Format("2006-01-02T15:04:05.000000Z07:00"), w.header)
+	b.Write(msg)
+	frame := b.Bytes()
+	if w.conn.stream() {
+		// RFC 6587 长度前缀：消息长度 空格 消息
+		frame = append([]byte(strconv.Itoa(b.Len())+" "), frame...)
+	}
+	if err := w.conn.write(frame); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close 关闭连接。
+func (w *SyslogWriter) Close() error {
+	return w.conn.close()
+}
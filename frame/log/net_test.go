@@ -0,0 +1,190 @@
+package log
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// syslogPattern 匹配 RFC 5424 的消息：PRI、版本、时间戳、主机名、应用名称、进程号、消息类型、结构化数据以及消息。
+var syslogPattern = regexp.MustCompile(`^<(\d+)>1 (\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}(?:Z|[+-]\d\d:\d\d)) (\S+) (\S+) (\d+) (\S+) - (.*)$`)
+
+// acceptOne 接受一个 TCP 连接并返回读取端。
+func acceptOne(t *testing.T, ln net.Listener) *bufio.Reader {
+	t.Helper()
+	ln.(*net.TCPListener).SetDeadline(time.Now().Add(5 * time.Second))
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return bufio.NewReader(conn)
+}
+
+// readDatagram 读取一个数据报。
+func readDatagram(t *testing.T, pc net.PacketConn) string {
+	t.Helper()
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 64<<10)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestNetWriterTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	w := NewNetWriter("tcp", ln.Addr().String())
+	defer w.Close()
+
+	if _, err := w.Write([]byte("{\"msg\":\"a\"}\n{\"msg\":\"b\"}\n")); err != nil {
+		t.Fatal(err)
+	}
+	r := acceptOne(t, ln)
+	if _, err := w.WriteLevel(LevelInfo, []byte("{\"msg\":\"c\"}\n")); err != nil {
+		t.Fatal(err)
+	}
+	// 字节流上按行划分日志
+	for _, want := range []string{`{"msg":"a"}`, `{"msg":"b"}`, `{"msg":"c"}`} {
+		line, err := r.ReadString('\n')
+		if err != nil || strings.TrimSuffix(line, "\n") != want {
+			t.Fatalf("got line %q, %v, want %q", line, err, want)
+		}
+	}
+
+	w.Close()
+	if _, err := w.Write([]byte("x\n")); !errors.Is(err, ErrWriterClosed) {
+		t.Fatalf("expected ErrWriterClosed, got %v", err)
+	}
+}
+
+func TestNetWriterUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	w := NewNetWriter("udp", pc.LocalAddr().String())
+	defer w.Close()
+
+	// Write 的每一行为一个数据报，WriteLevel 的整条日志为一个数据报
+	w.Write([]byte("a\nb\n"))
+	w.WriteLevel(LevelError, []byte("c\nd\n"))
+	for _, want := range []string{"a\n", "b\n", "c\nd\n"} {
+		if got := readDatagram(t, pc); got != want {
+			t.Fatalf("got datagram %q, want %q", got, want)
+		}
+	}
+}
+
+func TestSyslogWriterTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	w := NewSyslogWriter("tcp", ln.Addr().String(), SyslogOptions{Facility: FacilityLocal0, AppName: "app", Hostname: "host"})
+	defer w.Close()
+
+	w.WriteLevel(LevelInfo, []byte("first\n"))
+	w.WriteLevel(LevelWarn, []byte("second line\n"))
+	r := acceptOne(t, ln)
+	for _, want := range []struct {
+		pri int
+		msg string
+	}{{16*8 + 6, "first"}, {16*8 + 4, "second line"}} {
+		// RFC 6587 长度前缀：消息长度 空格 消息
+		prefix, err := r.ReadString(' ')
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(prefix, " "))
+		if err != nil {
+			t.Fatalf("invalid length prefix %q", prefix)
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			t.Fatal(err)
+		}
+		m := syslogPattern.FindStringSubmatch(string(buf))
+		if m == nil || m[1] != strconv.Itoa(want.pri) || m[3] != "host" || m[4] != "app" || m[6] != "-" || m[7] != want.msg {
+			t.Fatalf("unexpected message %q", buf)
+		}
+	}
+}
+
+func TestSyslogWriterUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	w := NewSyslogWriter("udp", pc.LocalAddr().String(), SyslogOptions{AppName: "app", Hostname: "host"})
+	defer w.Close()
+
+	// 数据报上没有长度前缀，默认设施为 user
+	w.Write([]byte("hello\n"))
+	got := readDatagram(t, pc)
+	if m := syslogPattern.FindStringSubmatch(got); m == nil || m[1] != strconv.Itoa(int(FacilityUser)*8+6) || m[7] != "hello" {
+		t.Fatalf("unexpected datagram %q", got)
+	}
+}
+
+func TestSyslogWriterUnixgram(t *testing.T) {
+	// Unix 套接字的路径长度有限制，不使用 t.TempDir 的长路径
+	dir, err := os.MkdirTemp("", "syslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log.sock")
+	pc, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Skipf("unixgram not supported: %v", err)
+	}
+	defer pc.Close()
+
+	w := NewSyslogWriter("unixgram", path, SyslogOptions{Facility: FacilityDaemon, AppName: "my app", Hostname: "host", MsgID: "ID47"})
+	defer w.Close()
+	for _, tt := range []struct {
+		level    LoggerLevel
+		severity int
+	}{
+		{LevelTrace, 7}, {LevelDebug, 7}, {LevelInfo, 6}, {LevelWarn, 4}, {LevelError, 3}, {LevelPanic, 2}, {LevelFatal, 2},
+	} {
+		if _, err := w.WriteLevel(tt.level, []byte("boom\n")); err != nil {
+			t.Fatal(err)
+		}
+		got := readDatagram(t, pc)
+		m := syslogPattern.FindStringSubmatch(got)
+		if m == nil {
+			t.Fatalf("not an RFC 5424 message: %q", got)
+		}
+		// 头部字段中的空格等不可打印字符被去掉
+		header := fmt.Sprintf("%s %s %s %s", m[3], m[4], m[5], m[6])
+		if want := fmt.Sprintf("host myapp %d ID47", os.Getpid()); header != want {
+			t.Fatalf("got header %q, want %q", header, want)
+		}
+		if m[1] != strconv.Itoa(int(FacilityDaemon)*8+tt.severity) || m[7] != "boom" {
+			t.Fatalf("level %s: got PRI %s, message %q", tt.level.Level(), m[1], m[7])
+		}
+		if _, err := time.Parse("2006-01-02T15:04:05.000000Z07:00", m[2]); err != nil {
+			t.Fatalf("invalid timestamp %q: %v", m[2], err)
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package log
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotateInterval 定义按时间切割日志文件的周期。
+type RotateInterval int
+
+const (
+	RotateNone   RotateInterval = iota // 不按时间切割
+	RotateHourly                       // 每小时切割
+	RotateDaily                        // 每天零点切割
+)
+
+// DefaultMaxSize 是日志文件的默认最大大小。
+const DefaultMaxSize = 100 << 20
+
+// rotateTimeFormat 是日志文件名中的时间格式，精确到毫秒以免同一秒内切割时文件名重复。
+const rotateTimeFormat = "20060102-150405.000"
+
+// compressSuffix 是压缩后的日志文件的后缀。
+const compressSuffix = ".gz"
+
+// currentTime 返回当前时间，测试时可以替换。
+var currentTime = time.Now
+
+// RotateOptions 是 RotateWriter 的配置。
+type RotateOptions struct {
+	MaxSize    int64          // 单个日志文件的最大字节数，超过后切割，小于等于 0 时不按大小切割
+	Interval   RotateInterval // 按时间切割的周期
+	MaxBackups int            // 保留的历史日志文件数量，小于等于 0 时不限制
+	MaxAge     time.Duration  // 历史日志文件的保留时间，小于等于 0 时不限制
+	Compress   bool           // 是否使用 gzip 压缩历史日志文件
+}
+
+// RotateWriter 是自动切割的日志文件输出流，可以被多个协程同时使用。
+//
+// 对于文件名 logs/info.log，日志实际写入 logs/info.<创建时间>.log，例如 logs/info.20240102-150405.000.log，
+// logs/info.log 是指向当前日志文件的符号链接（不支持符号链接的系统上不创建）。
+// 日志文件超过 MaxSize 或者进入新的周期时创建新的日志文件，之后在后台压缩历史日志文件，
+// 并删除超过 MaxBackups 数量或者 MaxAge 时间的历史日志文件。
+type RotateWriter struct {
+	filename string // 符号链接的路径
+	opts     RotateOptions
+	mu       sync.Mutex
+	file     *os.File
+	current  string    // 当前日志文件的路径
+	size     int64     // 当前日志文件的大小
+	next     time.Time // 下一次按时间切割的时间
+	millMu   sync.Mutex
+	mills    sync.WaitGroup // 正在执行的压缩、清理
+}
+
+// NewRotateWriter 创建写入 filename 的 RotateWriter，目录不存在时自动创建。
+// 上一次运行的日志文件仍在当前周期内并且没有超过 MaxSize 时继续追加写入。
+func NewRotateWriter(filename string, opts RotateOptions) (*RotateWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, err
+	}
+	w := &RotateWriter{filename: filename, opts: opts}
+	if err := w.openExisting(); err != nil {
+		return nil, err
+	}
+	if w.file == nil {
+		if err := w.openNew(); err != nil {
+			return nil, err
+		}
+	}
+	w.mill()
+	return w, nil
+}
+
+// Write 写入一条日志，写入前检查是否需要切割。
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return 0, ErrWriterClosed
+	}
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate 立即切割日志文件，例如在收到外部日志切割工具的信号时调用。
+func (w *RotateWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return ErrWriterClosed
+	}
+	return w.rotate()
+}
+
+// Sync 将当前日志文件同步到磁盘。
+func (w *RotateWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// Close 关闭当前日志文件，并等待后台的压缩、清理完成。重复调用 Close 返回 nil。
+func (w *RotateWriter) Close() error {
+	w.mu.Lock()
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.mu.Unlock()
+	w.mills.Wait()
+	return err
+}
+
+// shouldRotate 判断写入 n 个字节前是否需要切割，空文件不会因为大小切割。
+func (w *RotateWriter) shouldRotate(n int64) bool {
+	if w.opts.MaxSize > 0 && w.size > 0 && w.size+n > w.opts.MaxSize {
+		return true
+	}
+	return !w.next.IsZero() && !currentTime().Before(w.next)
+}
+
+// rotate 关闭当前日志文件，创建新的日志文件，然后在后台压缩、清理历史日志文件。
+func (w *RotateWriter) rotate() error {
+	if err := w.file.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+		return err
+	}
+	w.file = nil
+	if err := w.openNew(); err != nil {
+		return err
+	}
+	w.mill()
+	return nil
+}
+
+// openNew 创建以当前时间命名的日志文件，并将符号链接指向该文件。
+func (w *RotateWriter) openNew() error {
+	now := currentTime()
+	name := w.backupName(now, 0)
+	// 同一毫秒内多次切割时在时间后追加序号
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(name); os.IsNotExist(err) {
+			break
+		}
+		name = w.backupName(now, i)
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w.file, w.current, w.size = f, name, 0
+	w.next = w.nextRotation(now)
+	w.link()
+	return nil
+}
+
+// openExisting 打开符号链接指向的上一次运行的日志文件，文件不满足继续写入的条件时不打开。
+// filename 是普通文件时（例如旧版本直接写入的日志文件），将其重命名为历史日志文件。
+func (w *RotateWriter) openExisting() error {
+	info, err := os.Lstat(w.filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		if info.IsDir() {
+			return fmt.Errorf("log file %s is a directory", w.filename)
+		}
+		return os.Rename(w.filename, w.backupName(info.ModTime(), 0))
+	}
+	target, err := os.Readlink(w.filename)
+	if err != nil {
+		return nil
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(w.filename), target)
+	}
+	created, ok := w.backupTime(filepath.Base(target))
+	if !ok {
+		return nil
+	}
+	stat, err := os.Stat(target)
+	if err != nil {
+		return nil
+	}
+	now := currentTime()
+	next := w.nextRotation(created)
+	if (w.opts.MaxSize > 0 && stat.Size() >= w.opts.MaxSize) || (!next.IsZero() && !now.Before(next)) {
+		return nil
+	}
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil
+	}
+	w.file, w.current, w.size, w.next = f, target, stat.Size(), next
+	return nil
+}
+
+// link 将符号链接指向当前日志文件，先创建临时链接再重命名，保证读取方不会看到链接不存在的状态。
+// 创建失败（例如系统不支持符号链接）时忽略。
+func (w *RotateWriter) link() {
+	tmp := w.filename + ".tmp"
+	os.Remove(tmp)
+	if err := os.Symlink(filepath.Base(w.current), tmp); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, w.filename); err != nil {
+		os.Remove(tmp)
+	}
+}
+
+// nextRotation 返回 t 所在周期结束的时间，不按时间切割时返回零值。
+func (w *RotateWriter) nextRotation(t time.Time) time.Time {
+	switch w.opts.Interval {
+	case RotateHourly:
+		return t.Truncate(time.Hour).Add(time.Hour)
+	case RotateDaily:
+		y, m, d := t.Date()
+		return time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Time{}
+}
+
+// prefixAndExt 返回日志文件名去掉扩展名后的部分以及扩展名，例如 info 和 .log。
+func (w *RotateWriter) prefixAndExt() (string, string) {
+	base := filepath.Base(w.filename)
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + ".", ext
+}
+
+// backupName 返回在 t 时间创建的日志文件的路径，seq 大于 0 时追加在时间之后。
+func (w *RotateWriter) backupName(t time.Time, seq int) string {
+	prefix, ext := w.prefixAndExt()
+	name := prefix + t.Format(rotateTimeFormat)
+	if seq > 0 {
+		name += fmt.Sprintf(".%d", seq)
+	}
+	return filepath.Join(filepath.Dir(w.filename), name+ext)
+}
+
+// backupTime 从日志文件名中解析创建时间，不是该 RotateWriter 的日志文件时返回 false。
+func (w *RotateWriter) backupTime(name string) (time.Time, bool) {
+	prefix, ext := w.prefixAndExt()
+	name = strings.TrimSuffix(name, compressSuffix)
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+		return time.Time{}, false
+	}
+	// 去掉前缀、扩展名后以创建时间开头，之后可能是同一毫秒内切割时追加的序号
+	rest := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+	if len(rest) < len(rotateTimeFormat) {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(rotateTimeFormat, rest[:len(rotateTimeFormat)], time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// mill 在后台压缩、清理历史日志文件，同一时间只有一个协程执行。
+func (w *RotateWriter) mill() {
+	if !w.opts.Compress && w.opts.MaxBackups <= 0 && w.opts.MaxAge <= 0 {
+		return
+	}
+	w.mills.Add(1)
+	go func() {
+		defer w.mills.Done()
+		w.millMu.Lock()
+		defer w.millMu.Unlock()
+		if err := w.millRun(); err != nil {
+			fmt.Fprintf(os.Stderr, "log: rotate %s: %v\n", w.filename, err)
+		}
+	}()
+}
+
+// backup 是一个历史日志文件。
+type backup struct {
+	path    string
+	created time.Time
+}
+
+// millRun 压缩、清理历史日志文件。
+// 当前日志文件在读取目录之后读取：读取目录期间可能再次切割，新的日志文件会出现在目录中，
+// 因此创建时间不早于当前日志文件的文件都不作为历史日志文件处理。
+func (w *RotateWriter) millRun() error {
+	dir := filepath.Dir(w.filename)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	current := w.current
+	w.mu.Unlock()
+	currentCreated, hasCurrent := w.backupTime(filepath.Base(current))
+	var backups []backup
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if path == current {
+			continue
+		}
+		t, ok := w.backupTime(e.Name())
+		if !ok || (hasCurrent && !t.Before(currentCreated)) {
+			continue
+		}
+		backups = append(backups, backup{path: path, created: t})
+	}
+	// 按创建时间从新到旧排序
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].created.After(backups[j].created)
+	})
+	cutoff := currentTime().Add(-w.opts.MaxAge)
+	var errs []error
+	for i, b := range backups {
+		if (w.opts.MaxBackups > 0 && i >= w.opts.MaxBackups) || (w.opts.MaxAge > 0 && b.created.Before(cutoff)) {
+			if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if w.opts.Compress && !strings.HasSuffix(b.path, compressSuffix) {
+			if err := compressFile(b.path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// compressFile 使用 gzip 压缩文件，成功后删除原文件。
+func compressFile(name string) (err error) {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(name+compressSuffix, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			dst.Close()
+			os.Remove(name + compressSuffix)
+		}
+	}()
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err != nil {
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	src.Close()
+	return os.Remove(name)
+}
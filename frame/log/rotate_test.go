@@ -0,0 +1,323 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock 替换 currentTime，测试结束时恢复。
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func useFakeClock(t *testing.T, now time.Time) *fakeClock {
+	c := &fakeClock{now: now}
+	old := currentTime
+	currentTime = c.Now
+	t.Cleanup(func() { currentTime = old })
+	return c
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// logFiles 返回目录中除符号链接以外的文件名，按名称排序。
+func logFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		if e.Type().IsRegular() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func write(t *testing.T, w io.Writer, s string) {
+	t.Helper()
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRotateSize(t *testing.T) {
+	clock := useFakeClock(t, time.Date(2024, 1, 2, 15, 4, 5, 0, time.Local))
+	dir := t.TempDir()
+	name := filepath.Join(dir, "info.log")
+	w, err := NewRotateWriter(name, RotateOptions{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, w, "12345678\n")
+	clock.Add(time.Second)
+	write(t, w, "abcdefgh\n")
+	// 同一毫秒内再次切割时追加序号
+	write(t, w, "ABCDEFGH\n")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"info.20240102-150405.000.log", "info.20240102-150406.000.1.log", "info.20240102-150406.000.log"}
+	got := logFiles(t, dir)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("got files %v, want %v", got, want)
+	}
+	if s := readFile(t, filepath.Join(dir, want[0])); s != "12345678\n" {
+		t.Fatalf("unexpected first file %q", s)
+	}
+	// 符号链接指向最后创建的日志文件
+	target, err := os.Readlink(name)
+	if err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if target != want[1] || readFile(t, name) != "ABCDEFGH\n" {
+		t.Fatalf("link points to %s", target)
+	}
+}
+
+func TestRotateInterval(t *testing.T) {
+	for _, tt := range []struct {
+		interval RotateInterval
+		start    time.Time
+		next     time.Time
+		want     []string
+	}{
+		{
+			RotateHourly,
+			time.Date(2024, 1, 2, 10, 30, 0, 0, time.Local),
+			time.Date(2024, 1, 2, 11, 0, 0, 0, time.Local),
+			[]string{"app.20240102-103000.000.log", "app.20240102-110000.000.log"},
+		},
+		{
+			RotateDaily,
+			time.Date(2024, 1, 2, 23, 59, 0, 0, time.Local),
+			time.Date(2024, 1, 3, 0, 0, 0, 0, time.Local),
+			[]string{"app.20240102-235900.000.log", "app.20240103-000000.000.log"},
+		},
+	} {
+		clock := useFakeClock(t, tt.start)
+		dir := t.TempDir()
+		w, err := NewRotateWriter(filepath.Join(dir, "app.log"), RotateOptions{Interval: tt.interval})
+		if err != nil {
+			t.Fatal(err)
+		}
+		write(t, w, "first\n")
+		// 周期结束前不切割
+		clock.Set(tt.next.Add(-time.Millisecond))
+		write(t, w, "second\n")
+		clock.Set(tt.next)
+		write(t, w, "third\n")
+		w.Close()
+
+		got := logFiles(t, dir)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Fatalf("interval %d: got files %v, want %v", tt.interval, got, tt.want)
+		}
+		if s := readFile(t, filepath.Join(dir, tt.want[0])); s != "first\nsecond\n" {
+			t.Fatalf("interval %d: unexpected content %q", tt.interval, s)
+		}
+	}
+}
+
+func TestRotateMaxBackups(t *testing.T) {
+	clock := useFakeClock(t, time.Date(2024, 1, 2, 15, 0, 0, 0, time.Local))
+	dir := t.TempDir()
+	w, err := NewRotateWriter(filepath.Join(dir, "app.log"), RotateOptions{MaxSize: 1, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		clock.Add(time.Second)
+		write(t, w, "x")
+	}
+	w.Close()
+
+	// 保留当前日志文件以及最新的两个历史日志文件
+	want := []string{"app.20240102-150003.000.log", "app.20240102-150004.000.log", "app.20240102-150005.000.log"}
+	if got := logFiles(t, dir); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("got files %v, want %v", got, want)
+	}
+}
+
+func TestRotateMaxAge(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.Local)
+	useFakeClock(t, now)
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	ref := &RotateWriter{filename: name}
+	old := ref.backupName(now.Add(-72*time.Hour), 0)
+	recent := ref.backupName(now.Add(-time.Hour), 0)
+	other := filepath.Join(dir, "other.20240101-000000.000.log")
+	for _, f := range []string{old, recent, other} {
+		if err := os.WriteFile(f, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w, err := NewRotateWriter(name, RotateOptions{MaxAge: 48 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatal("backup older than MaxAge should be removed")
+	}
+	for _, f := range []string{recent, other} {
+		if _, err := os.Stat(f); err != nil {
+			t.Fatalf("%s should be kept: %v", f, err)
+		}
+	}
+}
+
+func TestRotateCompress(t *testing.T) {
+	clock := useFakeClock(t, time.Date(2024, 1, 2, 15, 0, 0, 0, time.Local))
+	dir := t.TempDir()
+	w, err := NewRotateWriter(filepath.Join(dir, "app.log"), RotateOptions{Interval: RotateHourly, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, w, "old\n")
+	clock.Add(time.Hour)
+	write(t, w, "new\n")
+	w.Close()
+
+	want := []string{"app.20240102-150000.000.log.gz", "app.20240102-160000.000.log"}
+	if got := logFiles(t, dir); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("got files %v, want %v", got, want)
+	}
+	f, err := os.Open(filepath.Join(dir, want[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := io.ReadAll(gz); err != nil || string(b) != "old\n" {
+		t.Fatalf("unexpected compressed content %q, %v", b, err)
+	}
+	if s := readFile(t, filepath.Join(dir, want[1])); s != "new\n" {
+		t.Fatalf("active file should not be compressed, got %q", s)
+	}
+}
+
+func TestRotateMillSkipsNewerFiles(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 0, 0, 0, time.Local)
+	useFakeClock(t, now)
+	dir := t.TempDir()
+	w := &RotateWriter{filename: filepath.Join(dir, "app.log"), opts: RotateOptions{Compress: true, MaxBackups: 1}}
+	older := w.backupName(now.Add(-2*time.Hour), 0)
+	w.current = w.backupName(now.Add(-time.Hour), 0)
+	// 读取目录时已经再次切割，新的日志文件还在写入，不能被压缩或删除
+	newer := w.backupName(now, 0)
+	for _, f := range []string{older, w.current, newer} {
+		if err := os.WriteFile(f, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.millRun(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Base(older) + compressSuffix, filepath.Base(w.current), filepath.Base(newer)}
+	if got := logFiles(t, dir); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("got files %v, want %v", got, want)
+	}
+}
+
+func TestRotateReopen(t *testing.T) {
+	clock := useFakeClock(t, time.Date(2024, 1, 2, 15, 0, 0, 0, time.Local))
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	opts := RotateOptions{MaxSize: 100, Interval: RotateHourly}
+	w, err := NewRotateWriter(name, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, w, "first run\n")
+	w.Close()
+	if _, err := os.Readlink(name); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	// 同一周期内重新打开时继续追加写入上一次运行的日志文件
+	clock.Add(time.Minute)
+	w, err = NewRotateWriter(name, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, w, "second run\n")
+	w.Close()
+	if got := logFiles(t, dir); len(got) != 1 || readFile(t, name) != "first run\nsecond run\n" {
+		t.Fatalf("expected to append to the previous file, got %v", got)
+	}
+
+	// 进入新的周期后重新打开时创建新的日志文件
+	clock.Add(time.Hour)
+	w, err = NewRotateWriter(name, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, w, "third run\n")
+	w.Close()
+	if got := logFiles(t, dir); len(got) != 2 || readFile(t, name) != "third run\n" {
+		t.Fatalf("expected a new file, got %v", got)
+	}
+
+	// 旧版本直接写入的普通文件被重命名为历史日志文件
+	plain := filepath.Join(dir, "plain.log")
+	if err := os.WriteFile(plain, []byte("legacy\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w, err = NewRotateWriter(plain, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	var legacy int
+	for _, f := range logFiles(t, dir) {
+		if strings.HasPrefix(f, "plain.") && readFile(t, filepath.Join(dir, f)) == "legacy\n" {
+			legacy++
+		}
+	}
+	if legacy != 1 {
+		t.Fatalf("legacy file not renamed: %v", logFiles(t, dir))
+	}
+}
@@ -0,0 +1,253 @@
+package log
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// sampleSlots 是每个日志级别的采样计数器数量，消息键按哈希值分配到计数器，哈希冲突的消息键共享计数器。
+const sampleSlots = 1024
+
+// numLevels 是日志级别的数量。
+const numLevels = int(LevelFatal) + 1
+
+// SamplingOptions 是日志采样的配置：每个周期内，同一级别、同一消息键的日志只记录前 First 条，
+// 之后每 Thereafter 条记录一条，其余的丢弃。消息键为日志消息，Infof 等方法使用格式化字符串，
+// 因此参数不同的同一类日志共享计数。
+type SamplingOptions struct {
+	Interval   time.Duration // 采样周期，小于等于 0 时为 1 秒
+	First      int           // 每个周期内记录的前 N 条
+	Thereafter int           // 之后每 M 条记录一条，小于等于 0 时全部丢弃
+}
+
+// sampler 使用原子操作计数的采样器。
+type sampler struct {
+	opts     SamplingOptions
+	counters [numLevels][sampleSlots]sampleCounter
+}
+
+// sampleCounter 是一个消息键在当前周期内的计数。
+type sampleCounter struct {
+	resetAt atomic.Int64 // 当前周期结束的时间（纳秒）
+	count   atomic.Uint64
+}
+
+// inc 将计数加一并返回当前周期内的计数，周期结束后重新计数。
+func (c *sampleCounter) inc(now int64, interval time.Duration) uint64 {
+	resetAt := c.resetAt.Load()
+	if now < resetAt {
+		return c.count.Add(1)
+	}
+	// 只有一个协程能重置计数，其它协程在新周期中继续累加。
+	// 重置时减去上一个周期的计数而不是直接置为 1，以免丢失其它协程已经在新周期中累加的计数
+	stale := c.count.Load()
+	if !c.resetAt.CompareAndSwap(resetAt, now+int64(interval)) {
+		return c.count.Add(1)
+	}
+	return c.count.Add(1 - stale)
+}
+
+// allow 判断一条日志是否被采样记录。
+func (s *sampler) allow(level LoggerLevel, key string, now int64) bool {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	n := s.counters[level][h.Sum32()%sampleSlots].inc(now, s.opts.Interval)
+	first := uint64(s.opts.First)
+	if n <= first {
+		return true
+	}
+	return s.opts.Thereafter > 0 && (n-first)%uint64(s.opts.Thereafter) == 0
+}
+
+// rateLimiter 是使用 GCRA（通用信元速率算法）实现的限流器，状态只有一个原子变量，不需要加锁。
+type rateLimiter struct {
+	interval  int64        // 两条日志之间的平均间隔（纳秒）
+	tolerance int64        // 允许的突发量对应的时间（纳秒）
+	tat       atomic.Int64 // 理论上下一条日志到达的时间
+}
+
+// newRateLimiter 创建每秒最多 perSecond 条、最多突发 burst 条的限流器。
+func newRateLimiter(perSecond float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	interval := int64(math.Max(1, float64(time.Second)/perSecond))
+	return &rateLimiter{interval: interval, tolerance: interval * int64(burst-1)}
+}
+
+// allow 判断 now 时刻的一条日志是否在速率限制内。
+func (r *rateLimiter) allow(now int64) bool {
+	for {
+		tat := r.tat.Load()
+		next := tat
+		if now > next {
+			next = now
+		}
+		if next-now > r.tolerance {
+			return false
+		}
+		if r.tat.CompareAndSwap(tat, next+r.interval) {
+			return true
+		}
+	}
+}
+
+// filter 是 Logger 的采样和限流状态，各字段使用原子操作读写，可以在运行时修改。
+type filter struct {
+	sampler atomic.Pointer[sampler]
+	limits  [numLevels]atomic.Pointer[rateLimiter]
+	sampled [numLevels]atomic.Uint64 // 被采样丢弃的日志条数
+	limited [numLevels]atomic.Uint64 // 被限流丢弃的日志条数
+	stop    chan struct{}            // 停止定期报告丢弃的日志条数
+}
+
+// allow 判断一条日志是否被记录，PANIC、FATAL 级别的日志总是被记录。
+// msg 与 key 只在开启采样时使用：key 为空时使用 msg 作为消息键。
+func (f *filter) allow(level LoggerLevel, msg any, key string) bool {
+	if level < 0 || level >= LevelPanic {
+		return true
+	}
+	s := f.sampler.Load()
+	limit := f.limits[level].Load()
+	if s == nil && limit == nil {
+		return true
+	}
+	now := time.Now().UnixNano()
+	if s != nil {
+		if key == "" {
+			key = messageKey(msg)
+		}
+		if !s.allow(level, key, now) {
+			f.sampled[level].Add(1)
+			return false
+		}
+	}
+	if limit != nil && !limit.allow(now) {
+		f.limited[level].Add(1)
+		return false
+	}
+	return true
+}
+
+// messageKey 返回日志消息的采样键。
+func messageKey(msg any) string {
+	switch m := msg.(type) {
+	case string:
+		return m
+	case error:
+		return m.Error()
+	case fmt.Stringer:
+		return m.String()
+	}
+	return fmt.Sprint(msg)
+}
+
+// SetSampling 开启日志采样（参见 SamplingOptions），First 小于等于 0 时关闭采样。可以在运行时调用。
+func (l *Logger) SetSampling(opts SamplingOptions) {
+	if opts.First <= 0 {
+		l.core.filter.sampler.Store(nil)
+		return
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	l.core.filter.sampler.Store(&sampler{opts: opts})
+}
+
+// SetRateLimit 限制 level 级别的日志每秒最多记录 perSecond 条，允许最多 burst 条的突发，超过的日志被丢弃。
+// perSecond 小于等于 0 时取消限制，PANIC、FATAL 级别不能限流。可以在运行时调用。
+func (l *Logger) SetRateLimit(level LoggerLevel, perSecond float64, burst int) {
+	if level < 0 || level >= LevelPanic {
+		panic("log: rate limit is only supported below PANIC level")
+	}
+	if perSecond <= 0 {
+		l.core.filter.limits[level].Store(nil)
+		return
+	}
+	l.core.filter.limits[level].Store(newRateLimiter(perSecond, burst))
+}
+
+// Dropped 返回 level 级别的日志自上一次报告（参见 ReportDropped）以来被采样丢弃和被限流丢弃的条数。
+func (l *Logger) Dropped(level LoggerLevel) (sampled, limited uint64) {
+	if level < 0 || int(level) >= numLevels {
+		return 0, 0
+	}
+	return l.core.filter.sampled[level].Load(), l.core.filter.limited[level].Load()
+}
+
+// ReportDropped 每隔 interval 以 WARN 级别报告上一个周期内被丢弃的日志条数，没有丢弃时不报告。
+// 报告不受日志级别、采样和限流的影响。Close 时报告最后一个周期并停止，重复调用时只有第一次生效。
+func (l *Logger) ReportDropped(interval time.Duration) {
+	if interval <= 0 {
+		panic("log: report interval must be positive")
+	}
+	l.core.mu.Lock()
+	defer l.core.mu.Unlock()
+	if l.core.filter.stop != nil {
+		return
+	}
+	stop := make(chan struct{})
+	l.core.filter.stop = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				l.reportDropped(interval)
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// stopReport 停止定期报告，并报告最后一个周期内被丢弃的日志条数。
+func (l *Logger) stopReport() {
+	l.core.mu.Lock()
+	stop := l.core.filter.stop
+	l.core.filter.stop = nil
+	l.core.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		l.reportDropped(0)
+	}
+}
+
+// droppedCounts 是一次报告中被丢弃的日志条数。
+type droppedCounts struct {
+	level   LoggerLevel
+	sampled uint64
+	limited uint64
+}
+
+// reportDropped 清零被丢弃的日志条数，并在有丢弃时记录一条 WARN 级别的日志。
+func (l *Logger) reportDropped(interval time.Duration) {
+	var counts []droppedCounts
+	for level := range l.core.filter.sampled {
+		c := droppedCounts{
+			level:   LoggerLevel(level),
+			sampled: l.core.filter.sampled[level].Swap(0),
+			limited: l.core.filter.limited[level].Swap(0),
+		}
+		if c.sampled > 0 || c.limited > 0 {
+			counts = append(counts, c)
+		}
+	}
+	if len(counts) == 0 {
+		return
+	}
+	parts := make([]string, len(counts))
+	for i, c := range counts {
+		parts[i] = fmt.Sprintf("%s %d (sampled %d, rate limited %d)", c.level.Level(), c.sampled+c.limited, c.sampled, c.limited)
+	}
+	msg := "log: dropped entries: " + strings.Join(parts, ", ")
+	if interval > 0 {
+		msg += " in the last " + interval.String()
+	}
+	l.write(LevelWarn, msg)
+}
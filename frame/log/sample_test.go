@@ -0,0 +1,170 @@
+package log
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSampler(t *testing.T) {
+	s := &sampler{opts: SamplingOptions{Interval: time.Second, First: 2, Thereafter: 3}}
+	var got []bool
+	for i := 0; i < 8; i++ {
+		got = append(got, s.allow(LevelInfo, "msg", 0))
+	}
+	// 前 2 条记录，之后每 3 条记录一条
+	want := []bool{true, true, false, false, true, false, false, true}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+	// 不同的级别、消息键分别计数
+	if !s.allow(LevelWarn, "msg", 0) || !s.allow(LevelInfo, "other", 0) {
+		t.Fatal("levels and keys should be counted separately")
+	}
+	// 新的周期重新计数
+	if !s.allow(LevelInfo, "msg", int64(time.Second)) || !s.allow(LevelInfo, "msg", int64(time.Second)) {
+		t.Fatal("counter should reset in a new interval")
+	}
+	if s.allow(LevelInfo, "msg", int64(time.Second)) {
+		t.Fatal("third entry in the new interval should be sampled")
+	}
+
+	drop := &sampler{opts: SamplingOptions{Interval: time.Second, First: 1}}
+	if !drop.allow(LevelInfo, "msg", 0) || drop.allow(LevelInfo, "msg", 0) {
+		t.Fatal("entries after First should be dropped when Thereafter is 0")
+	}
+}
+
+func TestSampleCounterReset(t *testing.T) {
+	// 多个协程同时进入新的周期时，重置计数不能丢失其它协程的累加
+	for round := 0; round < 200; round++ {
+		var c sampleCounter
+		c.count.Store(5)
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				c.inc(1, time.Hour)
+			}()
+		}
+		close(start)
+		wg.Wait()
+		if n := c.count.Load(); n != 16 {
+			t.Fatalf("round %d: got count %d, want 16", round, n)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	r := newRateLimiter(10, 3)
+	// 允许突发 3 条，之后按照每 100ms 一条的速率
+	for i := 0; i < 3; i++ {
+		if !r.allow(0) {
+			t.Fatalf("burst entry %d should be allowed", i)
+		}
+	}
+	if r.allow(0) || r.allow(int64(50*time.Millisecond)) {
+		t.Fatal("entries beyond the burst should be limited")
+	}
+	if !r.allow(int64(100*time.Millisecond)) || r.allow(int64(100*time.Millisecond)) {
+		t.Fatal("one entry should be allowed per interval")
+	}
+	// 空闲后突发量恢复，但不会超过 burst
+	now := int64(10 * time.Second)
+	for i := 0; i < 3; i++ {
+		if !r.allow(now) {
+			t.Fatalf("burst entry %d after idle should be allowed", i)
+		}
+	}
+	if r.allow(now) {
+		t.Fatal("burst should not exceed its size after idle")
+	}
+
+	if b := newRateLimiter(1, 0); !b.allow(0) || b.allow(0) {
+		t.Fatal("burst smaller than 1 should allow a single entry")
+	}
+}
+
+func TestLoggerFilter(t *testing.T) {
+	l := New()
+	l.Formatter = &TextFormatter{}
+	out := &syncBuffer{}
+	l.AddOut(&LoggerWriter{Level: -1, Out: out})
+	l.SetSampling(SamplingOptions{Interval: time.Hour, First: 1})
+	l.SetRateLimit(LevelError, 1, 1)
+
+	for i := 0; i < 3; i++ {
+		l.Infof("user %d logged in", i)
+		l.Error("failed")
+	}
+	// Infof 使用格式化字符串作为消息键，只记录第一条；ERROR 的后两条被采样丢弃，不再计入限流
+	if n := strings.Count(out.String(), "logged in"); n != 1 {
+		t.Fatalf("got %d sampled entries, want 1", n)
+	}
+	if sampled, limited := l.Dropped(LevelInfo); sampled != 2 || limited != 0 {
+		t.Fatalf("info dropped %d, %d", sampled, limited)
+	}
+	l.SetSampling(SamplingOptions{})
+	l.Error("failed")
+	if sampled, limited := l.Dropped(LevelError); sampled != 2 || limited != 1 {
+		t.Fatalf("error dropped %d, %d", sampled, limited)
+	}
+	// PANIC 及以上级别不受采样和限流的影响
+	if !l.core.filter.allow(LevelPanic, "msg", "") {
+		t.Fatal("panic entries should always be allowed")
+	}
+}
+
+func TestReportDropped(t *testing.T) {
+	newLogger := func(interval time.Duration) (*Logger, *syncBuffer) {
+		l := New()
+		l.Formatter = &TextFormatter{}
+		l.SetLevel(LevelError)
+		out := &syncBuffer{}
+		l.AddOut(&LoggerWriter{Level: -1, Out: out})
+		l.SetRateLimit(LevelError, 0.001, 1)
+		l.ReportDropped(interval)
+		return l, out
+	}
+
+	// 定期报告不受日志级别的影响，报告后计数清零
+	l, out := newLogger(20 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		l.Error("failed")
+	}
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(out.String(), "dropped entries") && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !strings.Contains(out.String(), "log: dropped entries: ERROR 2 (sampled 0, rate limited 2) in the last 20ms") {
+		t.Fatalf("periodic report not found in %q", out.String())
+	}
+	if sampled, limited := l.Dropped(LevelError); sampled != 0 || limited != 0 {
+		t.Fatalf("counts should be reset after a report, got %d, %d", sampled, limited)
+	}
+	l.Close()
+	reports := strings.Count(out.String(), "dropped entries")
+	time.Sleep(50 * time.Millisecond)
+	if strings.Count(out.String(), "dropped entries") != reports {
+		t.Fatal("reporting should stop after Close")
+	}
+
+	// Close 报告最后一个周期
+	l, out = newLogger(time.Hour)
+	l.Error("failed")
+	l.Error("failed")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	if strings.Count(out.String(), "dropped entries") != 1 || !strings.HasSuffix(last, "log: dropped entries: ERROR 1 (sampled 0, rate limited 1)") {
+		t.Fatalf("final report not found in %q", out.String())
+	}
+}
@@ -38,48 +38,41 @@ func (f *TextFormatter) Format(param *LoggingFormatParam) string {
 	}
 	var msgInfo = "\n msg: "
 	// 判断日志级别，如果是错误级别，则添加错误信息
-	if param.Level == LevelError {
+	if param.Level >= LevelError {
 		msgInfo = "\n Error Cause By: "
 	}
+	// 调用位置和调用栈
+	callerString := ""
+	if param.Caller != "" {
+		callerString = fmt.Sprintf(" | caller=%s %s", param.Caller, param.Function)
+	}
+	stackString := ""
+	if param.Stack != "" {
+		stackString = "\n" + param.Stack
+	}
 	// 判断是否需要带颜色
 	if param.IsColor {
 		// 要带颜色  error的颜色 为红色 info为绿色 debug为蓝色
 		levelColor := f.LevelColor(param.Level)
 		// 为日志信息添加颜色
 		msgColor := f.MsgColor(param.Level)
-		return fmt.Sprintf("%s [frame] %s %s%v%s | level= %s %s %s%s%s %v %s %s ",
+		return fmt.Sprintf("%s [frame] %s %s%v%s | level= %s %s %s%s%s%s %v %s %s %s",
 			yellow, reset, blue, now.Format("2006/01/02 - 15:04:05"), reset,
-			levelColor, param.Level.Level(), reset, msgColor, msgInfo, param.Msg, reset, fieldsString,
+			levelColor, param.Level.Level(), reset, callerString, msgColor, msgInfo, param.Msg, reset, fieldsString, stackString,
 		)
 	}
 	// 不带颜色直接返回
-	return fmt.Sprintf("[frame] %v | level=%s%s%v %s",
+	return fmt.Sprintf("[frame] %v | level=%s%s%s%v %s%s",
 		now.Format("2006/01/02 - 15:04:05"),
-		param.Level.Level(), msgInfo, param.Msg, fieldsString)
+		param.Level.Level(), callerString, msgInfo, param.Msg, fieldsString, stackString)
 }
 
 // LevelColor 根据日志级别返回对应的颜色
 func (f *TextFormatter) LevelColor(level LoggerLevel) string {
-	// 根据日志级别返回对应的颜色
-	switch level {
-	case LevelDebug:
-		return blue
-	case LevelInfo:
-		return green
-	case LevelError:
-		return red
-	default:
-		return cyan
-	}
+	return levelColor(level)
 }
 
 // MsgColor 根据日志信息级别返回对应的颜色
 func (f *TextFormatter) MsgColor(level LoggerLevel) string {
-	// 根据日志级别返回对应的颜色
-	switch level {
-	case LevelError:
-		return red
-	default:
-		return ""
-	}
+	return msgColor(level)
 }
@@ -0,0 +1,635 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// relationKind 表示关联关系的类型。
+type relationKind int
+
+const (
+	// hasOne 一对一，外键在关联的表中，例如 User 有一个 Profile（profile.user_id）。
+	hasOne relationKind = iota
+	// hasMany 一对多，外键在关联的表中，例如 User 有多个 Order（order.user_id）。
+	hasMany
+	// belongsTo 属于，外键在当前表中，例如 Order 属于 User（order.user_id）。
+	belongsTo
+	// manyToMany 多对多，通过中间表关联，例如 User 和 Role 通过 user_role 表关联。
+	manyToMany
+)
+
+// relation 描述了结构体中的一个关联字段，通过 gorm 标签的选项配置：
+//   - foreignkey: 外键列，has_one/has_many 时在关联的表中，belongs_to 时在当前表中；
+//   - references: 外键引用的列，默认为被引用表的主键；
+//   - many2many: 多对多关联的中间表名；
+//   - joinforeignkey、joinreferences: 中间表中引用当前表和关联表的列，默认为 表名_id。
+//
+// 只有设置了上面的选项，或者类型是表模型（有主键）的结构体字段才是关联，其它结构体字段是普通的列。
+// 单个结构体字段在当前表中存在外键列（默认为 字段名_id）时为 belongs_to，否则为 has_one；
+// 切片字段设置了 many2many 选项时为多对多，否则为 has_many。例如：
+//
+//	type User struct {
+//		Id      int64
+//		Profile *Profile                       // has_one，profile.user_id
+//		Orders  []*Order                       // has_many，order.user_id
+//		Roles   []*Role `gorm:",many2many:user_role"` // 多对多，user_role.user_id、user_role.role_id
+//	}
+//	type Order struct {
+//		Id     int64
+//		UserId int64
+//		User   *User                           // belongs_to，order.user_id
+//	}
+type relation struct {
+	// Name 是结构体中的字段名。
+	Name string
+	// Index 是字段在结构体中的下标。
+	Index int
+	// Type 是字段的反射类型。
+	Type reflect.Type
+	// Elem 是关联的结构体类型。
+	Elem reflect.Type
+	// Kind 是关联关系的类型。
+	Kind relationKind
+	// ForeignKey 是外键列。
+	ForeignKey string
+	// References 是外键引用的列，为空时使用被引用表的主键。
+	References string
+	// JoinTable、JoinForeignKey、JoinReferences 是多对多关联的中间表以及其中的列。
+	JoinTable      string
+	JoinForeignKey string
+	JoinReferences string
+	// Options 保存了标签中的所有选项。
+	Options map[string]string
+	// many 表示字段是否为切片。
+	many bool
+}
+
+// relationOptions 是关联字段的标签选项，设置了其中任意一个的字段是关联字段。
+var relationOptions = []string{"foreignkey", "references", "many2many", "joinforeignkey", "joinreferences"}
+
+// relationElem 判断字段是否为关联，返回关联的结构体类型以及是否为切片。
+// 结构体、结构体指针或者它们的切片在设置了关联选项，或者结构体本身是一个表模型（有主键）时是关联字段；
+// 其它结构体类型（包括 time.Time 以及实现了 sql.Scanner 或 driver.Valuer 的类型）是普通的列。
+func relationElem(t reflect.Type, options map[string]string) (elem reflect.Type, many bool, ok bool) {
+	// 自定义的切片类型（例如 type Tags []Tag）实现了 sql.Scanner 或 driver.Valuer 时同样是普通的列
+	if t.Implements(valuerType) || reflect.PointerTo(t).Implements(scannerType) {
+		return nil, false, false
+	}
+	if t.Kind() == reflect.Slice {
+		many = true
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType || reflect.PointerTo(t).Implements(scannerType) {
+		return nil, false, false
+	}
+	for _, opt := range relationOptions {
+		if _, ok := options[opt]; ok {
+			return t, many, true
+		}
+	}
+	if !isModel(t) {
+		return nil, false, false
+	}
+	return t, many, true
+}
+
+// isModel 判断结构体是否为表模型：有列名为 id 的字段，或者有字段使用了 primary_key、auto_increment 选项。
+// 这里只检查标签，不解析结构体，避免互相关联的模型递归解析。
+func isModel(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tag := sf.Tag.Get("gorm")
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		column := strings.TrimSpace(parts[0])
+		if column == "" {
+			column = strings.ToLower(Name(sf.Name))
+		}
+		if column == "id" || column == "auto_increment" {
+			return true
+		}
+		for _, opt := range parts[1:] {
+			key, _, _ := strings.Cut(strings.TrimSpace(opt), ":")
+			if key = strings.ToLower(key); key == "primary_key" || key == "auto_increment" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolve 根据标签选项和当前结构体的列推导关联的类型和外键。
+func (r *relation) resolve(owner *schema) {
+	ownerName := strings.ToLower(Name(owner.Type.Name()))
+	r.ForeignKey = r.Options["foreignkey"]
+	r.References = r.Options["references"]
+	if table, ok := r.Options["many2many"]; ok && r.many {
+		r.Kind = manyToMany
+		r.JoinTable = table
+		r.JoinForeignKey = r.Options["joinforeignkey"]
+		if r.JoinForeignKey == "" {
+			r.JoinForeignKey = ownerName + "_id"
+		}
+		r.JoinReferences = r.Options["joinreferences"]
+		if r.JoinReferences == "" {
+			r.JoinReferences = strings.ToLower(Name(r.Elem.Name())) + "_id"
+		}
+		return
+	}
+	if r.many {
+		r.Kind = hasMany
+	} else {
+		// 当前表中存在外键列时为 belongs_to
+		fk := r.ForeignKey
+		if fk == "" {
+			fk = strings.ToLower(Name(r.Name)) + "_id"
+		}
+		if _, ok := owner.columns[fk]; ok {
+			r.Kind = belongsTo
+			r.ForeignKey = fk
+			return
+		}
+		r.Kind = hasOne
+	}
+	if r.ForeignKey == "" {
+		r.ForeignKey = ownerName + "_id"
+	}
+}
+
+// Relation 根据字段名查找关联。
+func (sc *schema) Relation(name string) (*relation, bool) {
+	r, ok := sc.relations[name]
+	return r, ok
+}
+
+// Preload 设置查询时需要预加载的关联字段，多层关联使用 . 分隔，例如：
+//
+//	db.New(&User{}).Preload("Orders", "Orders.Goods", "Profile").Select(&User{})
+//
+// Select 和 SelectOne 查询完成后，每个关联使用一条 IN 查询批量加载，避免 N+1 查询。
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) Preload(names ...string) *FrameSession {
+	s.preloads = append(s.preloads, names...)
+	return s
+}
+
+// preload 为查询结果加载通过 Preload 设置的关联，items 是结构体指针。
+func (s *FrameSession) preload(sc *schema, items []reflect.Value) error {
+	if len(s.preloads) == 0 || len(items) == 0 {
+		return nil
+	}
+	// 按第一层字段名分组，剩余的路径交给关联的查询继续预加载
+	order := make([]string, 0, len(s.preloads))
+	nested := make(map[string][]string)
+	for _, path := range s.preloads {
+		name, rest, _ := strings.Cut(path, ".")
+		if _, ok := nested[name]; !ok {
+			order = append(order, name)
+			nested[name] = nil
+		}
+		if rest != "" {
+			nested[name] = append(nested[name], rest)
+		}
+	}
+	for _, name := range order {
+		rel, ok := sc.Relation(name)
+		if !ok {
+			return fmt.Errorf("%s has no association %s", sc.Type.Name(), name)
+		}
+		if err := s.loadRelation(sc, rel, items, nested[name]); err != nil {
+			return fmt.Errorf("preload %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// loadRelation 使用一条 IN 查询加载所有结果的同一个关联，并赋值给对应的字段。
+func (s *FrameSession) loadRelation(sc *schema, rel *relation, items []reflect.Value, preloads []string) error {
+	child, err := parseSchema(rel.Elem)
+	if err != nil {
+		return err
+	}
+	switch rel.Kind {
+	case belongsTo:
+		fk, ok := sc.FieldByColumn(rel.ForeignKey)
+		if !ok {
+			return fmt.Errorf("unknown foreign key %s", rel.ForeignKey)
+		}
+		ref, err := referenceField(child, rel.References)
+		if err != nil {
+			return err
+		}
+		related, err := s.findRelated(rel, ref.Column, fieldKeys(items, fk), preloads)
+		if err != nil {
+			return err
+		}
+		byKey := groupByField(related, ref)
+		for _, item := range items {
+			setRelation(item.Elem(), rel, byKey[keyString(item.Elem().Field(fk.Index).Interface())])
+		}
+	case hasOne, hasMany:
+		ref, err := referenceField(sc, rel.References)
+		if err != nil {
+			return err
+		}
+		fk, ok := child.FieldByColumn(rel.ForeignKey)
+		if !ok {
+			return fmt.Errorf("unknown foreign key %s", rel.ForeignKey)
+		}
+		related, err := s.findRelated(rel, fk.Column, fieldKeys(items, ref), preloads)
+		if err != nil {
+			return err
+		}
+		byKey := groupByField(related, fk)
+		for _, item := range items {
+			setRelation(item.Elem(), rel, byKey[keyString(item.Elem().Field(ref.Index).Interface())])
+		}
+	case manyToMany:
+		ownerKey, err := referenceField(sc, "")
+		if err != nil {
+			return err
+		}
+		ref, err := referenceField(child, rel.References)
+		if err != nil {
+			return err
+		}
+		pairs, err := s.joinPairs(rel, fieldKeys(items, ownerKey))
+		if err != nil {
+			return err
+		}
+		keys := make([]any, 0, len(pairs))
+		for _, pair := range pairs {
+			keys = append(keys, pair[1])
+		}
+		related, err := s.findRelated(rel, ref.Column, keys, preloads)
+		if err != nil {
+			return err
+		}
+		byKey := groupByField(related, ref)
+		byOwner := make(map[string][]reflect.Value)
+		for _, pair := range pairs {
+			owner := keyString(pair[0])
+			byOwner[owner] = append(byOwner[owner], byKey[keyString(pair[1])]...)
+		}
+		for _, item := range items {
+			setRelation(item.Elem(), rel, byOwner[keyString(item.Elem().Field(ownerKey.Index).Interface())])
+		}
+	}
+	return nil
+}
+
+// findRelated 查询 column 的值在 keys 中的关联记录，返回结构体指针。
+// 查询使用与当前会话相同的连接和事务，关联模型的软删除同样生效。
+func (s *FrameSession) findRelated(rel *relation, column string, keys []any, preloads []string) ([]reflect.Value, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	related := make([]reflect.Value, 0, len(keys))
+	err := s.eachKeyChunk(keys, func(keys []any) error {
+		model := reflect.New(rel.Elem).Interface()
+		rows, err := s.New(model).Preload(preloads...).Where(In(column, keys)).Select(model)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			related = append(related, reflect.ValueOf(row))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return related, nil
+}
+
+// eachKeyChunk 按占位符数量的限制将 keys 分批，依次对每一批执行 fn，使 IN 查询不超过数据库的占位符数量限制。
+func (s *FrameSession) eachKeyChunk(keys []any, fn func(keys []any) error) error {
+	size := s.chunkSize(1, 0)
+	for lo := 0; lo < len(keys); lo += size {
+		hi := lo + size
+		if hi > len(keys) {
+			hi = len(keys)
+		}
+		if err := fn(keys[lo:hi]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// joinPairs 查询多对多中间表，返回 (当前表的键, 关联表的键) 对。
+func (s *FrameSession) joinPairs(rel *relation, keys []any) ([][2]any, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	pairs := make([][2]any, 0)
+	err := s.eachKeyChunk(keys, func(keys []any) error {
+		query := fmt.Sprintf("select %s, %s from %s where %s in (%s)",
+			rel.JoinForeignKey, rel.JoinReferences, rel.JoinTable, rel.JoinForeignKey, placeholders(len(keys)))
+		rows, err := s.query(true, query, keys...)
+		if err != nil || rows == nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var pair [2]any
+			if err := rows.Scan(&pair[0], &pair[1]); err != nil {
+				return err
+			}
+			pairs = append(pairs, pair)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// referenceField 返回被引用的字段，column 为空时返回主键。
+func referenceField(sc *schema, column string) (*field, error) {
+	if column == "" {
+		if pk := sc.PrimaryKey(); pk != nil {
+			return pk, nil
+		}
+		return nil, fmt.Errorf("%s has no primary key", sc.Type.Name())
+	}
+	f, ok := sc.FieldByColumn(column)
+	if !ok {
+		return nil, fmt.Errorf("%s has no column %s", sc.Type.Name(), column)
+	}
+	return f, nil
+}
+
+// fieldKeys 返回所有结构体中字段 f 的非零值，并去除重复的值。
+func fieldKeys(items []reflect.Value, f *field) []any {
+	keys := make([]any, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		v := item.Elem().Field(f.Index)
+		if v.IsZero() {
+			continue
+		}
+		key := keyString(v.Interface())
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, v.Interface())
+	}
+	return keys
+}
+
+// groupByField 将结构体指针按字段 f 的值分组。
+func groupByField(items []reflect.Value, f *field) map[string][]reflect.Value {
+	groups := make(map[string][]reflect.Value, len(items))
+	for _, item := range items {
+		key := keyString(item.Elem().Field(f.Index).Interface())
+		groups[key] = append(groups[key], item)
+	}
+	return groups
+}
+
+// keyString 将键值转换为字符串，用于比较不同整数类型以及驱动返回的 []byte。
+func keyString(v any) string {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case nil:
+		return ""
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return ""
+		}
+		return fmt.Sprint(rv.Elem().Interface())
+	}
+	return fmt.Sprint(v)
+}
+
+// setRelation 将关联记录（结构体指针）赋值给关联字段。
+func setRelation(owner reflect.Value, rel *relation, related []reflect.Value) {
+	fv := owner.Field(rel.Index)
+	if !rel.many {
+		if len(related) == 0 {
+			return
+		}
+		if fv.Kind() == reflect.Pointer {
+			fv.Set(related[0])
+		} else {
+			fv.Set(related[0].Elem())
+		}
+		return
+	}
+	slice := reflect.MakeSlice(rel.Type, 0, len(related))
+	for _, item := range related {
+		if rel.Type.Elem().Kind() == reflect.Pointer {
+			slice = reflect.Append(slice, item)
+		} else {
+			slice = reflect.Append(slice, item.Elem())
+		}
+	}
+	fv.Set(slice)
+}
+
+// relationItems 返回关联字段中的结构体指针，值为空时返回 nil。
+func relationItems(owner reflect.Value, rel *relation) []reflect.Value {
+	fv := owner.Field(rel.Index)
+	if !rel.many {
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				return nil
+			}
+			return []reflect.Value{fv}
+		}
+		if fv.IsZero() {
+			return nil
+		}
+		return []reflect.Value{fv.Addr()}
+	}
+	items := make([]reflect.Value, 0, fv.Len())
+	for i := 0; i < fv.Len(); i++ {
+		item := fv.Index(i)
+		if item.Kind() == reflect.Pointer {
+			if !item.IsNil() {
+				items = append(items, item)
+			}
+			continue
+		}
+		items = append(items, item.Addr())
+	}
+	return items
+}
+
+// hasAssociations 判断 data 中是否有需要级联插入的关联记录。
+func hasAssociations(data any) bool {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return false
+	}
+	sc, err := parseSchema(v.Type())
+	if err != nil {
+		return false
+	}
+	for _, rel := range sc.Relations {
+		if len(relationItems(v.Elem(), rel)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// saveBelongsTo 在插入 data 之前插入它所属的新记录（主键为零值），并设置 data 的外键。
+func (s *FrameSession) saveBelongsTo(data any) error {
+	sc, v, ok := structValue(data)
+	if !ok {
+		return nil
+	}
+	for _, rel := range sc.Relations {
+		if rel.Kind != belongsTo {
+			continue
+		}
+		items := relationItems(v, rel)
+		if len(items) == 0 {
+			continue
+		}
+		child, err := parseSchema(rel.Elem)
+		if err != nil {
+			return err
+		}
+		ref, err := referenceField(child, rel.References)
+		if err != nil {
+			return err
+		}
+		if err := s.insertNew(items[0], child); err != nil {
+			return err
+		}
+		fk, ok := sc.FieldByColumn(rel.ForeignKey)
+		if !ok {
+			return fmt.Errorf("unknown foreign key %s", rel.ForeignKey)
+		}
+		if err := convertAssign(v.Field(fk.Index), items[0].Elem().Field(ref.Index).Interface(), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveAssociations 在插入 data 之后级联插入 has_one、has_many 和多对多关联中的新记录（主键为零值），
+// 并设置它们的外键。多对多关联中已存在的记录只插入中间表。
+func (s *FrameSession) saveAssociations(data any) error {
+	sc, v, ok := structValue(data)
+	if !ok {
+		return nil
+	}
+	for _, rel := range sc.Relations {
+		if rel.Kind == belongsTo {
+			continue
+		}
+		items := relationItems(v, rel)
+		if len(items) == 0 {
+			continue
+		}
+		child, err := parseSchema(rel.Elem)
+		if err != nil {
+			return err
+		}
+		if rel.Kind == manyToMany {
+			if err := s.saveManyToMany(sc, v, rel, child, items); err != nil {
+				return err
+			}
+			continue
+		}
+		ref, err := referenceField(sc, rel.References)
+		if err != nil {
+			return err
+		}
+		fk, ok := child.FieldByColumn(rel.ForeignKey)
+		if !ok {
+			return fmt.Errorf("unknown foreign key %s", rel.ForeignKey)
+		}
+		for _, item := range items {
+			if err := convertAssign(item.Elem().Field(fk.Index), v.Field(ref.Index).Interface(), false); err != nil {
+				return err
+			}
+			if err := s.insertNew(item, child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// saveManyToMany 插入多对多关联中的新记录，并为每条关联记录插入中间表数据。
+func (s *FrameSession) saveManyToMany(sc *schema, v reflect.Value, rel *relation, child *schema, items []reflect.Value) error {
+	ownerKey, err := referenceField(sc, "")
+	if err != nil {
+		return err
+	}
+	ref, err := referenceField(child, rel.References)
+	if err != nil {
+		return err
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "insert into %s (%s,%s) values ", rel.JoinTable, rel.JoinForeignKey, rel.JoinReferences)
+	values := make([]any, 0, 2*len(items))
+	for i, item := range items {
+		if err := s.insertNew(item, child); err != nil {
+			return err
+		}
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("(?,?)")
+		values = append(values, v.Field(ownerKey.Index).Interface(), item.Elem().Field(ref.Index).Interface())
+	}
+	_, err = s.exec(sb.String(), values...)
+	return err
+}
+
+// insertNew 插入主键为零值的关联记录，已存在的记录（主键不为零值）不做处理。
+func (s *FrameSession) insertNew(item reflect.Value, sc *schema) error {
+	if pk := sc.PrimaryKey(); pk != nil && !item.Elem().Field(pk.Index).IsZero() {
+		return nil
+	}
+	data := item.Interface()
+	_, _, err := s.New(data).Insert(data)
+	return err
+}
+
+// setPrimaryKey 插入成功后将自增ID写回 data 中值为零的整数主键。
+func setPrimaryKey(data any, id int64) {
+	sc, v, ok := structValue(data)
+	if !ok || id <= 0 {
+		return
+	}
+	pk := sc.PrimaryKey()
+	if pk == nil || !isIntField(pk) || !v.Field(pk.Index).IsZero() {
+		return
+	}
+	_ = convertAssign(v.Field(pk.Index), id, false)
+}
+
+// structValue 返回结构体指针 data 的表结构和结构体的值。
+func structValue(data any) (*schema, reflect.Value, bool) {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return nil, reflect.Value{}, false
+	}
+	sc, err := parseSchema(v.Type())
+	if err != nil {
+		return nil, reflect.Value{}, false
+	}
+	return sc, v.Elem(), true
+}
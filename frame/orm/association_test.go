@@ -0,0 +1,59 @@
+//go:build cgo
+
+package orm_test
+
+import (
+	"frame/orm"
+	"frame/orm/ormtest"
+	"strings"
+	"testing"
+)
+
+type preloadUser struct {
+	Id     int64
+	Name   string
+	Orders []*preloadOrder
+}
+
+type preloadOrder struct {
+	Id            int64
+	PreloadUserId int64
+}
+
+func TestPreloadChunksKeys(t *testing.T) {
+	db := ormtest.Open(t)
+	// SQLite 按每条语句最多 999 个占位符计算，1200 个用户的订单需要分两批查询
+	users := make([]map[string]any, 0, 1200)
+	orders := make([]map[string]any, 0, 1200)
+	for i := 1; i <= 1200; i++ {
+		users = append(users, map[string]any{"id": i, "name": "u"})
+		orders = append(orders, map[string]any{"id": i, "preload_user_id": i})
+	}
+	if err := db.Insert(map[string][]map[string]any{"preload_user": users, "preload_order": orders}); err != nil {
+		t.Fatal(err)
+	}
+	db.Reset()
+
+	rows, err := db.New(&preloadUser{}).Preload("Orders").Select(&preloadUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1200 {
+		t.Fatalf("got %d users", len(rows))
+	}
+	for _, row := range rows {
+		u := row.(*preloadUser)
+		if len(u.Orders) != 1 || u.Orders[0].PreloadUserId != u.Id {
+			t.Fatalf("unexpected orders of user %d: %+v", u.Id, u.Orders)
+		}
+	}
+	var queries []orm.Statement
+	for _, st := range db.Statements() {
+		if strings.Contains(st.SQL, "preload_order") {
+			queries = append(queries, st)
+		}
+	}
+	if len(queries) != 2 || len(queries[0].Args) != 999 || len(queries[1].Args) != 201 {
+		t.Fatalf("expected two chunked queries, got %d", len(queries))
+	}
+}
@@ -0,0 +1,375 @@
+package orm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// maxPlaceholders 返回方言单条语句允许的最大占位符数量。
+// MySQL 和 PostgreSQL 为 65535，SQLite 3.32 之前为 999，为了兼容旧版本按 999 计算。
+func maxPlaceholders(d Dialect) int {
+	if d != nil && d.Name() == "sqlite3" {
+		return 999
+	}
+	return 65535
+}
+
+// BatchSize 设置 InsertBatch 和 UpdateBatch 每批处理的最大行数。
+// 未设置或者超过占位符数量限制时，按方言的占位符数量限制自动计算每批的行数。
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) BatchSize(size int) *FrameSession {
+	s.batchSize = size
+	return s
+}
+
+// ChunkTx 设置每一批在单独的事务中执行，某一批失败时只回滚这一批，之前成功的批次已经提交。
+// 会话已在事务中时，所有批次都在该事务中执行。
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) ChunkTx() *FrameSession {
+	s.chunkTx = true
+	return s
+}
+
+// OnProgress 设置每一批执行成功后调用的进度回调，done 是已处理的行数，total 是总行数。
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) OnProgress(fn func(done, total int)) *FrameSession {
+	s.progress = fn
+	return s
+}
+
+// chunkSize 返回每批的行数，perRow 是每一行使用的占位符数量，extra 是每条语句额外使用的占位符数量。
+func (s *FrameSession) chunkSize(perRow int, extra int) int {
+	if perRow < 1 {
+		perRow = 1
+	}
+	limit := (maxPlaceholders(s.db.dialect) - extra) / perRow
+	if limit < 1 {
+		limit = 1
+	}
+	if s.batchSize > 0 && s.batchSize < limit {
+		return s.batchSize
+	}
+	return limit
+}
+
+// eachChunk 将 total 行按 size 分批，依次对每一批 [lo, hi) 执行 fn，并在每批成功后报告进度。
+func (s *FrameSession) eachChunk(total int, size int, fn func(lo, hi int) error) error {
+	for lo := 0; lo < total; lo += size {
+		hi := lo + size
+		if hi > total {
+			hi = total
+		}
+		if err := fn(lo, hi); err != nil {
+			return fmt.Errorf("batch rows %d-%d: %w", lo, hi-1, err)
+		}
+		if s.progress != nil {
+			s.progress(hi, total)
+		}
+	}
+	return nil
+}
+
+// resetValues 清空上一条语句使用的字段名、占位符和值，使会话可以执行下一批。
+func (s *FrameSession) resetValues() {
+	s.fieldName = nil
+	s.placeHolder = nil
+	s.values = nil
+	s.updateParam.Reset()
+	s.updateFields = nil
+}
+
+// UpdateBatch 根据主键批量更新数据，每一批生成一条使用 CASE WHEN 的更新语句，例如：
+//
+//	update user set age = case id when ? then ? when ? then ? end where id in (?,?)
+//
+// PostgreSQL 中 CASE 的参数会被推断为文本类型，因此使用 UPDATE ... FROM (VALUES ...) 并显式转换参数类型：
+//
+//	update user set age = v.c0 from (values (cast(? as bigint),cast(? as integer)),(?,?)) as v(k,c0) where id = v.k
+//
+// 参数 data 是结构体指针切片，主键不能重复，fields 是需要更新的列，未指定时更新除主键和创建时间以外的所有列。
+// 会话上的 Where 条件会与主键条件一起使用。UpdatedAt 字段会被自动设置为当前时间，
+// 每个元素的更新钩子与 Update 相同。返回所有批次受影响的行数。
+//
+// 结构体有版本号字段时与 Update 相同使用乐观锁：版本号不在更新的列中，每一行只在版本号与结构体一致时更新，
+// 版本号加一。每一批在事务中执行，有记录没有被更新时回滚这一批并返回 ErrStaleObject，
+// 之前成功的批次中结构体的版本号同步加一。
+func (s *FrameSession) UpdateBatch(data []any, fields ...string) (int64, error) {
+	if len(data) == 0 {
+		return 0, errors.New("no data update")
+	}
+	t := reflect.TypeOf(data[0])
+	if t.Kind() != reflect.Pointer {
+		return 0, errors.New("data must be pointer")
+	}
+	sc, err := parseSchema(t)
+	if err != nil {
+		return 0, err
+	}
+	pk := sc.PrimaryKey()
+	if pk == nil {
+		return 0, errors.New("update batch requires a primary key")
+	}
+	if err := checkDuplicateKeys(sc, pk, data); err != nil {
+		return 0, err
+	}
+	if s.tableName == "" {
+		s.tableName = s.db.Prefix + strings.ToLower(Name(sc.Type.Name()))
+	}
+	if s.model == nil {
+		s.model = data[0]
+	}
+
+	// 确定需要更新的字段
+	var updates []*field
+	if len(fields) == 0 {
+		for _, f := range sc.Fields {
+			if f != pk && f != sc.CreatedAt && f != sc.DeletedAt && f != sc.Version {
+				updates = append(updates, f)
+			}
+		}
+	} else {
+		for _, column := range fields {
+			f, ok := sc.FieldByColumn(column)
+			if !ok {
+				return 0, fmt.Errorf("unknown column %s", column)
+			}
+			// 版本号由乐观锁维护
+			if f != sc.Version {
+				updates = append(updates, f)
+			}
+		}
+		if sc.UpdatedAt != nil && !contains(fields, sc.UpdatedAt.Column) {
+			updates = append(updates, sc.UpdatedAt)
+		}
+	}
+	if len(updates) == 0 {
+		return 0, errors.New("no field update")
+	}
+
+	// 分片表按分片分组后分别更新
+	var affected int64
+	err = s.onShardGroups(data, func(group []any) error {
+		n, err := s.updateChunks(sc, pk, updates, group)
+		affected += n
+		return err
+	})
+	return affected, err
+}
+
+// updateChunks 按占位符数量的限制分批更新数据，返回受影响的总行数。
+func (s *FrameSession) updateChunks(sc *schema, pk *field, updates []*field, data []any) (int64, error) {
+	var affected int64
+	perRow := 2*len(updates) + 1
+	if sc.Version != nil {
+		perRow += 2
+	}
+	size := s.chunkSize(perRow, len(s.whereValues))
+	err := s.eachChunk(len(data), size, func(lo, hi int) error {
+		chunk := data[lo:hi]
+		// 乐观锁检查失败时需要回滚这一批
+		needTx := s.chunkTx || sc.Version != nil
+		for _, v := range chunk {
+			needTx = needTx || hasHook(v, hookAfterUpdate)
+		}
+		err := s.withHookTx(needTx, func() error {
+			for _, v := range chunk {
+				if err := s.callHook(v, hookBeforeUpdate); err != nil {
+					return err
+				}
+			}
+			n, err := s.updateBatch(sc, pk, updates, chunk)
+			if err != nil {
+				return err
+			}
+			if sc.Version != nil && s.dryRun == nil && n != int64(len(chunk)) {
+				return ErrStaleObject
+			}
+			affected += n
+			for _, v := range chunk {
+				if err := s.callHook(v, hookAfterUpdate); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err == nil && sc.Version != nil && s.dryRun == nil {
+			for _, v := range chunk {
+				incrementVersion(sc.Version, reflect.ValueOf(v).Elem())
+			}
+		}
+		return err
+	})
+	return affected, err
+}
+
+// checkDuplicateKeys 检查 data 中是否有主键相同的元素。同一个主键在一条语句中只能更新一次，
+// 受影响的行数会少于元素的数量，有版本号字段时会被误判为版本冲突。
+func checkDuplicateKeys(sc *schema, pk *field, data []any) error {
+	seen := make(map[any]struct{}, len(data))
+	for _, v := range data {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Pointer || rv.Elem().Type() != sc.Type {
+			return errors.New("update batch data must be pointers to the same struct")
+		}
+		key := rv.Elem().Field(pk.Index).Interface()
+		if _, ok := seen[key]; ok {
+			return fmt.Errorf("update batch: duplicate primary key %v", key)
+		}
+		seen[key] = struct{}{}
+	}
+	return nil
+}
+
+// updateBatch 生成一批数据的更新语句并执行，PostgreSQL 使用 UPDATE ... FROM (VALUES ...)，其它数据库使用 CASE WHEN。
+func (s *FrameSession) updateBatch(sc *schema, pk *field, updates []*field, data []any) (int64, error) {
+	s.resetValues()
+	now := time.Now()
+	rows := make([]reflect.Value, 0, len(data))
+	for _, v := range data {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Pointer || rv.Elem().Type() != sc.Type {
+			return 0, errors.New("update batch data must be pointers to the same struct")
+		}
+		setUpdateTime(sc, rv.Elem(), now)
+		rows = append(rows, rv.Elem())
+	}
+
+	var query string
+	var err error
+	if s.db.dialect != nil && s.db.dialect.Name() == "postgres" {
+		query, err = s.updateBatchValues(sc, pk, updates, rows)
+	} else {
+		query, err = s.updateBatchCase(sc, pk, updates, rows)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if where := s.whereSQL(); where != "" {
+		query += " and (" + strings.TrimPrefix(where, " where ") + ")"
+		s.values = append(s.values, s.whereValues...)
+	}
+
+	r, err := s.exec(query, s.values...)
+	if err != nil {
+		return 0, err
+	}
+	return r.RowsAffected()
+}
+
+// updateBatchCase 生成使用 CASE WHEN 的更新语句，参数追加到 s.values。
+func (s *FrameSession) updateBatchCase(sc *schema, pk *field, updates []*field, rows []reflect.Value) (string, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "update %s set ", s.tableName)
+	for i, f := range updates {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		fmt.Fprintf(&sb, "%s = case %s", f.Column, pk.Column)
+		for _, row := range rows {
+			value, err := fieldValue(f, row.Field(f.Index))
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(" when ? then ?")
+			s.values = append(s.values, row.Field(pk.Index).Interface(), value)
+		}
+		sb.WriteString(" end")
+	}
+	if sc.Version != nil {
+		fmt.Fprintf(&sb, ",%s = %s + 1", sc.Version.Column, sc.Version.Column)
+	}
+
+	// 主键条件与会话上的条件一起使用
+	keys := make([]any, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, row.Field(pk.Index).Interface())
+	}
+	fmt.Fprintf(&sb, " where %s in (%s)", pk.Column, placeholders(len(keys)))
+	s.values = append(s.values, keys...)
+	// 乐观锁：每一行的版本号与结构体一致
+	if sc.Version != nil {
+		fmt.Fprintf(&sb, " and %s = case %s", sc.Version.Column, pk.Column)
+		for _, row := range rows {
+			sb.WriteString(" when ? then ?")
+			s.values = append(s.values, row.Field(pk.Index).Interface(), row.Field(sc.Version.Index).Interface())
+		}
+		sb.WriteString(" end")
+	}
+	return sb.String(), nil
+}
+
+// updateBatchValues 生成 PostgreSQL 的 UPDATE ... FROM (VALUES ...) 更新语句，参数追加到 s.values。
+// VALUES 中的参数没有类型信息，第一行的参数显式转换为列的类型，其余行的类型与第一行一致。
+// VALUES 的列名为 k（主键）、c0、c1 ...（更新的列）以及 ver（版本号），避免与会话条件中的列名冲突。
+func (s *FrameSession) updateBatchValues(sc *schema, pk *field, updates []*field, rows []reflect.Value) (string, error) {
+	columns := append([]*field{pk}, updates...)
+	names := []string{"k"}
+	for i := range updates {
+		names = append(names, fmt.Sprintf("c%d", i))
+	}
+	if sc.Version != nil {
+		columns = append(columns, sc.Version)
+		names = append(names, "ver")
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "update %s set ", s.tableName)
+	for i, f := range updates {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		fmt.Fprintf(&sb, "%s = v.%s", f.Column, names[i+1])
+	}
+	if sc.Version != nil {
+		fmt.Fprintf(&sb, ",%s = %s + 1", sc.Version.Column, sc.Version.Column)
+	}
+	sb.WriteString(" from (values ")
+	for r, row := range rows {
+		if r > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("(")
+		for i, f := range columns {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			if r == 0 {
+				fmt.Fprintf(&sb, "cast(? as %s)", castType(s.db.dialect, f))
+			} else {
+				sb.WriteString("?")
+			}
+			value, err := fieldValue(f, row.Field(f.Index))
+			if err != nil {
+				return "", err
+			}
+			s.values = append(s.values, value)
+		}
+		sb.WriteString(")")
+	}
+	fmt.Fprintf(&sb, ") as v(%s) where %s = v.k", strings.Join(names, ","), pk.Column)
+	if sc.Version != nil {
+		fmt.Fprintf(&sb, " and %s = v.ver", sc.Version.Column)
+	}
+	return sb.String(), nil
+}
+
+// castType 返回 CAST 使用的列类型：自增类型转换为对应的整数类型，去掉长度等类型参数，
+// 避免 cast(? as varchar(255)) 这样的转换截断数据。
+func castType(d Dialect, f *field) string {
+	typ := strings.ToLower(columnType(d, f))
+	if i := strings.IndexByte(typ, '('); i >= 0 {
+		typ = strings.TrimSpace(typ[:i])
+	}
+	switch typ {
+	case "smallserial":
+		return "smallint"
+	case "serial":
+		return "integer"
+	case "bigserial":
+		return "bigint"
+	}
+	return typ
+}
@@ -0,0 +1,180 @@
+//go:build cgo
+
+package orm_test
+
+import (
+	"errors"
+	"frame/orm"
+	"frame/orm/ormtest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type batchItem struct {
+	Id      int64
+	Name    string
+	Stock   int
+	Version int64 `gorm:"version,version"`
+}
+
+func openItems(t *testing.T) *ormtest.DB {
+	db := ormtest.Open(t)
+	if err := db.AutoMigrate(&batchItem{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Insert(map[string][]map[string]any{
+		"batch_item": {
+			{"id": 1, "name": "pen", "stock": 1, "version": 1},
+			{"id": 2, "name": "ink", "stock": 2, "version": 1},
+			{"id": 3, "name": "cap", "stock": 3, "version": 1},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestUpdateBatch(t *testing.T) {
+	db := openItems(t)
+	items := []any{
+		&batchItem{Id: 1, Name: "pen", Stock: 10, Version: 1},
+		&batchItem{Id: 2, Name: "ink", Stock: 20, Version: 1},
+		&batchItem{Id: 3, Name: "cap", Stock: 30, Version: 1},
+	}
+	var progress []int
+	n, err := db.New(&batchItem{}).BatchSize(2).OnProgress(func(done, total int) {
+		progress = append(progress, done)
+	}).UpdateBatch(items, "stock")
+	if err != nil || n != 3 {
+		t.Fatalf("update batch: %d %v", n, err)
+	}
+	rows := db.Rows("batch_item")
+	if rows[0]["stock"] != int64(10) || rows[1]["stock"] != int64(20) || rows[2]["stock"] != int64(30) {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+	if len(progress) != 2 || progress[0] != 2 || progress[1] != 3 {
+		t.Fatalf("unexpected progress %v", progress)
+	}
+
+	// 有版本号字段时，被会话条件过滤掉的记录同样视为版本冲突，整批回滚
+	items = []any{
+		&batchItem{Id: 1, Name: "pen", Stock: 11, Version: 2},
+		&batchItem{Id: 3, Name: "cap", Stock: 31, Version: 2},
+	}
+	n, err = db.New(&batchItem{}).Where("stock", 10).UpdateBatch(items, "stock")
+	if !errors.Is(err, orm.ErrStaleObject) || n != 0 {
+		t.Fatalf("expected ErrStaleObject, got %d %v", n, err)
+	}
+	rows = db.Rows("batch_item")
+	if rows[0]["stock"] != int64(10) || rows[2]["stock"] != int64(30) {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+}
+
+func TestUpdateBatchOptimisticLock(t *testing.T) {
+	db := openItems(t)
+	items := []any{
+		&batchItem{Id: 1, Name: "pen", Stock: 10, Version: 1},
+		&batchItem{Id: 2, Name: "ink", Stock: 20, Version: 1},
+	}
+	// 指定版本号列时同样由乐观锁维护，不会被结构体中的值覆盖
+	n, err := db.New(&batchItem{}).UpdateBatch(items, "stock", "version")
+	if err != nil || n != 2 {
+		t.Fatalf("update batch: %d %v", n, err)
+	}
+	if items[0].(*batchItem).Version != 2 || items[1].(*batchItem).Version != 2 {
+		t.Fatalf("struct versions should be incremented: %+v %+v", items[0], items[1])
+	}
+	rows := db.Rows("batch_item")
+	if rows[0]["stock"] != int64(10) || rows[1]["stock"] != int64(20) || rows[0]["version"] != int64(2) || rows[2]["version"] != int64(1) {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+
+	// 第二批中 id 为 3 的记录版本号已过期：这一批回滚，第一批已经提交
+	items = []any{
+		&batchItem{Id: 1, Name: "pen", Stock: 11, Version: 2},
+		&batchItem{Id: 2, Name: "ink", Stock: 21, Version: 2},
+		&batchItem{Id: 3, Name: "cap", Stock: 31, Version: 0},
+	}
+	_, err = db.New(&batchItem{}).BatchSize(1).UpdateBatch(items)
+	if !errors.Is(err, orm.ErrStaleObject) {
+		t.Fatalf("expected ErrStaleObject, got %v", err)
+	}
+	rows = db.Rows("batch_item")
+	if rows[0]["stock"] != int64(11) || rows[1]["stock"] != int64(21) || rows[2]["stock"] != int64(3) {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+	if items[1].(*batchItem).Version != 3 || items[2].(*batchItem).Version != 0 {
+		t.Fatalf("only committed chunks should increment versions: %+v %+v", items[1], items[2])
+	}
+}
+
+func TestUpdateBatchDuplicateKeys(t *testing.T) {
+	db := openItems(t)
+	items := []any{
+		&batchItem{Id: 1, Name: "pen", Stock: 10, Version: 1},
+		&batchItem{Id: 2, Name: "ink", Stock: 20, Version: 1},
+		&batchItem{Id: 1, Name: "pen", Stock: 11, Version: 1},
+	}
+	_, err := db.New(&batchItem{}).UpdateBatch(items, "stock")
+	if err == nil || !strings.Contains(err.Error(), "duplicate primary key 1") {
+		t.Fatalf("expected duplicate key error, got %v", err)
+	}
+	if rows := db.Rows("batch_item"); rows[0]["stock"] != int64(1) || rows[1]["stock"] != int64(2) {
+		t.Fatalf("nothing should be updated: %v", rows)
+	}
+}
+
+func TestUpdateBatchPostgresValues(t *testing.T) {
+	db := ormtest.Open(t)
+	db.SetDialect(orm.LookupDialect("postgres"))
+	items := []any{
+		&batchItem{Id: 1, Name: "pen", Stock: 10, Version: 1},
+		&batchItem{Id: 2, Name: "ink", Stock: 20, Version: 3},
+	}
+	statements := ormtest.DryRun(db.New(&batchItem{}).Where("stock", 0), func(s *orm.FrameSession) {
+		if _, err := s.UpdateBatch(items); err != nil {
+			t.Fatal(err)
+		}
+	})
+	ormtest.AssertStatements(t, statements, orm.Statement{
+		SQL: "update batch_item set name = v.c0,stock = v.c1,version = version + 1 " +
+			"from (values (cast(? as bigint),cast(? as varchar),cast(? as bigint),cast(? as bigint)),(?,?,?,?)) as v(k,c0,c1,ver) " +
+			"where id = v.k and version = v.ver and (stock = ?)",
+		Args: []any{1, "pen", 10, 1, 2, "ink", 20, 3, 0},
+	})
+}
+
+type batchNote struct {
+	Id        int64 `gorm:"id,auto_increment"`
+	Body      string
+	CreatedAt time.Time
+	// createdInHook 记录 BeforeInsert 中 CreatedAt 是否已经被设置
+	createdInHook bool
+}
+
+func (n *batchNote) BeforeInsert(*orm.FrameSession) error {
+	n.createdInHook = !n.CreatedAt.IsZero()
+	return nil
+}
+
+func TestInsertBatchHookBeforeTimestamps(t *testing.T) {
+	db := ormtest.Open(t)
+	if err := db.AutoMigrate(&batchNote{}); err != nil {
+		t.Fatal(err)
+	}
+	notes := []any{&batchNote{Body: "a"}, &batchNote{Body: "b"}}
+	if _, n, err := db.New(&batchNote{}).InsertBatch(notes); err != nil || n != 2 {
+		t.Fatalf("insert batch: %d %v", n, err)
+	}
+	for i, v := range notes {
+		note := v.(*batchNote)
+		if note.createdInHook {
+			t.Fatalf("note %d: CreatedAt should be zero inside BeforeInsert", i)
+		}
+		if note.CreatedAt.IsZero() {
+			t.Fatalf("note %d: CreatedAt should be set after BeforeInsert", i)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package orm
+
+import (
+	"container/list"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scopes 按顺序将可复用的查询条件（例如租户、状态、时间范围）应用到当前会话，例如：
+//
+//	func Active(s *orm.FrameSession) *orm.FrameSession {
+//		return s.Where("status", 1)
+//	}
+//	db.New(&User{}).Scopes(Active, Tenant(tenantId)).Select(&User{})
+//
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) Scopes(scopes ...func(*FrameSession) *FrameSession) *FrameSession {
+	for _, scope := range scopes {
+		if m := scope(s); m != nil {
+			s = m
+		}
+	}
+	return s
+}
+
+// CacheStore 是查询缓存的存储，FrameDb 默认使用容量为 DefaultCacheSize 的内存 LRU 缓存。
+// 存储中的值由 ORM 复制后使用，实现不需要复制，但需要保证并发安全。
+type CacheStore interface {
+	// Get 返回 key 对应的未过期的值。
+	Get(key string) (any, bool)
+	// Set 保存 key 对应的值，ttl 小于等于 0 时不过期。
+	Set(key string, value any, ttl time.Duration)
+}
+
+// DefaultCacheSize 是默认的内存 LRU 缓存的容量。
+const DefaultCacheSize = 1024
+
+// NewLRUCache 返回一个最多保存 size 个结果的内存 LRU 缓存，超出容量时淘汰最久未使用的结果。
+func NewLRUCache(size int) CacheStore {
+	if size < 1 {
+		size = DefaultCacheSize
+	}
+	return &lruCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// lruCache 是 CacheStore 的内存 LRU 实现。
+type lruCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+// lruEntry 是 lruCache 中的一个结果。
+type lruEntry struct {
+	key     string
+	value   any
+	expires time.Time
+}
+
+func (c *lruCache) Get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*lruEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.ll.Remove(e)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return entry.value, true
+}
+
+func (c *lruCache) Set(key string, value any, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	if e, ok := c.items[key]; ok {
+		entry := e.Value.(*lruEntry)
+		entry.value, entry.expires = value, expires
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for c.ll.Len() > c.size {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.items, e.Value.(*lruEntry).key)
+	}
+}
+
+// queryCache 是 FrameDb 的查询缓存。
+// 每张表有一个版本号，缓存的键包含表的版本号，表被修改时版本号加一，旧的结果不会再被读取，
+// 由存储按容量或过期时间淘汰，因此失效不依赖存储是否支持删除。
+type queryCache struct {
+	mu    sync.Mutex
+	store CacheStore
+	// tables 是表名到版本号的映射。
+	tables map[string]uint64
+	// all 是全局版本号，执行无法确定表名的 SQL 时加一，使所有结果失效。
+	all uint64
+}
+
+// SetCacheStore 设置查询缓存的存储，设置为 nil 时恢复为默认的内存 LRU 缓存。
+func (db *FrameDb) SetCacheStore(store CacheStore) {
+	db.cache.mu.Lock()
+	defer db.cache.mu.Unlock()
+	db.cache.store = store
+}
+
+// InvalidateCache 使指定表的查询缓存失效，未指定表时使所有查询缓存失效。
+// 通过 FrameDb 执行的写操作会自动使对应表的缓存失效，绕过 FrameDb 修改数据时需要手动调用。
+func (db *FrameDb) InvalidateCache(tables ...string) {
+	db.cache.mu.Lock()
+	defer db.cache.mu.Unlock()
+	if len(tables) == 0 {
+		db.cache.all++
+		return
+	}
+	if db.cache.tables == nil {
+		db.cache.tables = make(map[string]uint64)
+	}
+	for _, table := range tables {
+		db.cache.tables[table]++
+	}
+}
+
+// cacheStore 返回查询缓存的存储以及表和全局的版本号，没有设置存储时创建默认的内存 LRU 缓存。
+func (db *FrameDb) cacheStore(table string) (CacheStore, uint64, uint64) {
+	db.cache.mu.Lock()
+	defer db.cache.mu.Unlock()
+	if db.cache.store == nil {
+		db.cache.store = NewLRUCache(DefaultCacheSize)
+	}
+	return db.cache.store, db.cache.tables[table], db.cache.all
+}
+
+// Cache 开启查询缓存：Select、SelectOne、Count、Aggregate 的结果按照 SQL 和参数缓存 ttl 时间，
+// ttl 小于等于 0 时结果在表被修改或者被存储淘汰之前一直有效。
+// 通过同一个 FrameDb 对该表执行 Insert、Update、Delete 等写操作时缓存自动失效。
+// 缓存只按会话的主表失效，Join、Preload 涉及的其它表被修改时不会失效，需要设置合适的 ttl 或者调用 InvalidateCache。
+// 事务中的查询不使用缓存。返回修改后的 FrameSession 实例。
+func (s *FrameSession) Cache(ttl time.Duration) *FrameSession {
+	s.cache = true
+	s.cacheTTL = ttl
+	return s
+}
+
+// cacheKey 返回查询结果在缓存中的键，会话没有开启缓存时 ok 为 false。
+// kind 区分了结果的类型，例如同一条 SQL 映射到不同的结构体。
+func (s *FrameSession) cacheKey(kind string, query string, args []any) (store CacheStore, key string, ok bool) {
+	if !s.cache || s.beginTx || s.dryRun != nil {
+		return nil, "", false
+	}
+	store, version, all := s.db.cacheStore(s.tableName)
+	key = fmt.Sprintf("%s:%d:%d:%s:%s:%s", s.tableName, version, all, kind, strings.Join(s.preloads, ","), interpolate(query, args, false))
+	return store, key, true
+}
+
+// invalidateCache 在写操作后使会话的表的查询缓存失效。
+// 事务中的修改在提交前对其它会话不可见，提交后需要再次失效，避免提交前读取到的旧结果被缓存。
+func (s *FrameSession) invalidateCache() {
+	tables := []string{s.tableName}
+	if s.tableName == "" {
+		tables = nil
+	}
+	s.db.InvalidateCache(tables...)
+	if s.beginTx {
+		s.AfterCommit(func() { s.db.InvalidateCache(tables...) })
+	}
+}
+
+// cloneItems 深拷贝查询结果中的结构体，避免调用方修改缓存中的结果，
+// 预加载的关联、指针字段以及 []byte 等字段都会被复制。
+func cloneItems(items []any) []any {
+	seen := make(map[visit]reflect.Value)
+	result := make([]any, len(items))
+	for i, item := range items {
+		result[i] = cloneValue(reflect.ValueOf(item), seen).Interface()
+	}
+	return result
+}
+
+// clonePointer 深拷贝指针指向的值，返回指向副本的指针。
+func clonePointer(p any) any {
+	return cloneValue(reflect.ValueOf(p), make(map[visit]reflect.Value)).Interface()
+}
+
+// visit 是已经复制过的指针，同一个指针只复制一次，保持结果中共享的关联记录并避免循环引用。
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// cloneValue 深拷贝 v：指针、切片、映射以及接口中的值都会被复制。
+// 结构体中未导出的字段无法通过反射赋值，按值复制（例如 time.Time 内部的字段）。
+func cloneValue(v reflect.Value, seen map[visit]reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		key := visit{ptr: v.Pointer(), typ: v.Type()}
+		if c, ok := seen[key]; ok {
+			return c
+		}
+		c := reflect.New(v.Type().Elem())
+		seen[key] = c
+		c.Elem().Set(cloneValue(v.Elem(), seen))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			reflect.Copy(c, v)
+			return c
+		}
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(cloneValue(v.Index(i), seen))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(cloneValue(v.Index(i), seen))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), cloneValue(iter.Value(), seen))
+		}
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(cloneValue(v.Elem(), seen))
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if f := c.Field(i); f.CanSet() {
+				f.Set(cloneValue(v.Field(i), seen))
+			}
+		}
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,53 @@
+//go:build cgo
+
+package orm_test
+
+import (
+	"frame/orm/ormtest"
+	"testing"
+)
+
+type cacheUser struct {
+	Id     int64
+	Avatar []byte
+	Orders []*cacheOrder
+}
+
+type cacheOrder struct {
+	Id          int64
+	CacheUserId int64
+	Amount      int
+}
+
+func TestCachedResultsAreIsolated(t *testing.T) {
+	db := ormtest.Open(t)
+	if err := db.Insert(map[string][]map[string]any{
+		"cache_user":  {{"id": 1, "avatar": "ab"}},
+		"cache_order": {{"id": 1, "cache_user_id": 1, "amount": 10}, {"id": 2, "cache_user_id": 1, "amount": 20}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	query := func() *cacheUser {
+		rows, err := db.New(&cacheUser{}).Cache(0).Preload("Orders").Where("id", 1).Select(&cacheUser{})
+		if err != nil || len(rows) != 1 {
+			t.Fatalf("select: %v %v", rows, err)
+		}
+		return rows[0].(*cacheUser)
+	}
+
+	// 修改第一次查询（写入缓存）和第二次查询（命中缓存）的结果都不影响缓存中的数据
+	for i := 0; i < 2; i++ {
+		u := query()
+		u.Avatar[0] = 'x'
+		u.Orders[0].Amount = 99
+		u.Orders = u.Orders[:1]
+	}
+	db.Reset()
+	u := query()
+	if len(db.Statements()) != 0 {
+		t.Fatalf("expected a cache hit, got %v", db.Statements())
+	}
+	if string(u.Avatar) != "ab" || len(u.Orders) != 2 || u.Orders[0].Amount != 10 || u.Orders[1].Amount != 20 {
+		t.Fatalf("cached result was modified: %s %+v %+v", u.Avatar, u.Orders[0], u.Orders)
+	}
+}
@@ -0,0 +1,278 @@
+package orm
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Condition 表示一个可以被渲染为 WHERE 子句的查询条件。
+// Build 返回带有 ? 占位符的条件语句以及与占位符一一对应的参数。
+type Condition interface {
+	Build() (string, []any)
+}
+
+// compareCond 表示 field op ? 形式的比较条件。
+type compareCond struct {
+	field string
+	op    string
+	value any
+}
+
+// Build 实现 Condition 接口。
+func (c *compareCond) Build() (string, []any) {
+	value, args := bindValue(c.value)
+	return c.field + " " + c.op + " " + value, args
+}
+
+// Eq 创建一个等于条件：field = value。
+func Eq(field string, value any) Condition {
+	return &compareCond{field: field, op: "=", value: value}
+}
+
+// Ne 创建一个不等于条件：field <> value。
+func Ne(field string, value any) Condition {
+	return &compareCond{field: field, op: "<>", value: value}
+}
+
+// Gt 创建一个大于条件：field > value。
+func Gt(field string, value any) Condition {
+	return &compareCond{field: field, op: ">", value: value}
+}
+
+// Gte 创建一个大于等于条件：field >= value。
+func Gte(field string, value any) Condition {
+	return &compareCond{field: field, op: ">=", value: value}
+}
+
+// Lt 创建一个小于条件：field < value。
+func Lt(field string, value any) Condition {
+	return &compareCond{field: field, op: "<", value: value}
+}
+
+// Lte 创建一个小于等于条件：field <= value。
+func Lte(field string, value any) Condition {
+	return &compareCond{field: field, op: "<=", value: value}
+}
+
+// Like 创建一个模糊匹配条件：field like value，value 中的通配符需要调用方自行拼接。
+func Like(field string, value any) Condition {
+	return &compareCond{field: field, op: "like", value: value}
+}
+
+// inCond 表示 field in (?,?,...) 形式的条件。
+type inCond struct {
+	field  string
+	values any
+	not    bool
+}
+
+// Build 实现 Condition 接口。
+// 切片参数会被展开为与元素个数相同的占位符，空切片会生成恒为假（NOT IN 时恒为真）的条件。
+func (c *inCond) Build() (string, []any) {
+	op := " in "
+	if c.not {
+		op = " not in "
+	}
+	// 子查询直接作为 in 的参数
+	if sub, ok := c.values.(Condition); ok {
+		query, args := sub.Build()
+		return c.field + op + query, args
+	}
+	args := expandSlice(c.values)
+	if len(args) == 0 {
+		if c.not {
+			return "1 = 1", nil
+		}
+		return "1 = 0", nil
+	}
+	var sb strings.Builder
+	sb.WriteString(c.field)
+	sb.WriteString(op)
+	sb.WriteString("(")
+	sb.WriteString(placeholders(len(args)))
+	sb.WriteString(")")
+	return sb.String(), args
+}
+
+// In 创建一个 IN 条件：field in (values...)，values 通常为切片或数组，也可以是 SubQuery 返回的子查询。
+func In(field string, values any) Condition {
+	return &inCond{field: field, values: values}
+}
+
+// NotIn 创建一个 NOT IN 条件：field not in (values...)。
+func NotIn(field string, values any) Condition {
+	return &inCond{field: field, values: values, not: true}
+}
+
+// betweenCond 表示 field between ? and ? 形式的条件。
+type betweenCond struct {
+	field string
+	start any
+	end   any
+}
+
+// Build 实现 Condition 接口。
+func (c *betweenCond) Build() (string, []any) {
+	start, startArgs := bindValue(c.start)
+	end, endArgs := bindValue(c.end)
+	args := make([]any, 0, len(startArgs)+len(endArgs))
+	args = append(args, startArgs...)
+	return c.field + " between " + start + " and " + end, append(args, endArgs...)
+}
+
+// Between 创建一个区间条件：field between start and end。
+func Between(field string, start, end any) Condition {
+	return &betweenCond{field: field, start: start, end: end}
+}
+
+// nullCond 表示 field is null 或 field is not null 条件。
+type nullCond struct {
+	field string
+	not   bool
+}
+
+// Build 实现 Condition 接口。
+func (c *nullCond) Build() (string, []any) {
+	if c.not {
+		return c.field + " is not null", nil
+	}
+	return c.field + " is null", nil
+}
+
+// IsNull 创建一个空值条件：field is null。
+func IsNull(field string) Condition {
+	return &nullCond{field: field}
+}
+
+// IsNotNull 创建一个非空条件：field is not null。
+func IsNotNull(field string) Condition {
+	return &nullCond{field: field, not: true}
+}
+
+// notCond 表示对另一个条件取反。
+type notCond struct {
+	cond Condition
+}
+
+// Build 实现 Condition 接口。
+func (c *notCond) Build() (string, []any) {
+	query, args := c.cond.Build()
+	return "not (" + query + ")", args
+}
+
+// Not 创建一个取反条件：not (cond)。
+func Not(cond Condition) Condition {
+	return &notCond{cond: cond}
+}
+
+// groupCond 表示使用同一个连接符组合起来并用括号包裹的一组条件。
+type groupCond struct {
+	sep   string
+	conds []Condition
+}
+
+// Build 实现 Condition 接口。
+func (c *groupCond) Build() (string, []any) {
+	if len(c.conds) == 0 {
+		return "1 = 1", nil
+	}
+	parts := make([]string, 0, len(c.conds))
+	args := make([]any, 0)
+	for _, cond := range c.conds {
+		query, values := cond.Build()
+		parts = append(parts, query)
+		args = append(args, values...)
+	}
+	return "(" + strings.Join(parts, c.sep) + ")", args
+}
+
+// AndGroup 将多个条件使用 and 组合并用括号包裹：(c1 and c2 ...)。
+func AndGroup(conds ...Condition) Condition {
+	return &groupCond{sep: " and ", conds: conds}
+}
+
+// OrGroup 将多个条件使用 or 组合并用括号包裹：(c1 or c2 ...)。
+func OrGroup(conds ...Condition) Condition {
+	return &groupCond{sep: " or ", conds: conds}
+}
+
+// Expression 是一段原生的 SQL 片段，其中的 ? 占位符与参数一一对应。
+// Expression 实现了 Condition 接口，可以用在 Where、Having、Join 以及条件的值中，
+// 执行前会与其它条件一起按照方言改写占位符。
+type Expression struct {
+	sql  string
+	args []any
+}
+
+// Expr 创建一个原生 SQL 片段，例如：orm.Expr("age > ? and age < ?", 18, 30)。
+func Expr(sql string, args ...any) *Expression {
+	return &Expression{sql: sql, args: args}
+}
+
+// Build 实现 Condition 接口。
+func (e *Expression) Build() (string, []any) {
+	return e.sql, e.args
+}
+
+// needParens 判断条件与其它条件连接时是否需要使用括号包裹：原生SQL片段的内容无法确定，
+// 其余条件包含 or 时需要括号，AndGroup、OrGroup 已经带有括号。
+func needParens(cond Condition, query string) bool {
+	switch cond.(type) {
+	case *Expression:
+		return true
+	case *groupCond:
+		return false
+	}
+	return strings.Contains(strings.ToLower(query), " or ")
+}
+
+// bindValue 返回值在 SQL 中的表示形式：普通值使用 ? 占位符，Condition（如 Expr、子查询）直接内联其 SQL。
+func bindValue(value any) (string, []any) {
+	if cond, ok := value.(Condition); ok {
+		return cond.Build()
+	}
+	return "?", []any{value}
+}
+
+// toCondition 将 Where、Having、Join 等方法的参数转换为条件。
+// 参数为 Condition 时直接使用；参数为单独的列名和一个值时生成等值条件；其余字符串视为原生 SQL 片段。
+func toCondition(query any, args []any) Condition {
+	switch q := query.(type) {
+	case Condition:
+		return q
+	case string:
+		if len(args) == 1 && !strings.ContainsAny(q, " ?()=<>!") {
+			return Eq(q, args[0])
+		}
+		return Expr(q, args...)
+	default:
+		panic("condition param not valid")
+	}
+}
+
+// expandSlice 将切片或数组展开为参数列表，[]byte 以及非切片类型视为单个参数。
+func expandSlice(values any) []any {
+	if values == nil {
+		return nil
+	}
+	if args, ok := values.([]any); ok {
+		return args
+	}
+	v := reflect.ValueOf(values)
+	if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Type().Elem().Kind() == reflect.Uint8 {
+		return []any{values}
+	}
+	args := make([]any, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		args[i] = v.Index(i).Interface()
+	}
+	return args
+}
+
+// placeholders 返回 n 个以逗号分隔的 ? 占位符。
+func placeholders(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strings.Repeat("?,", n-1) + "?"
+}
@@ -0,0 +1,230 @@
+package orm
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	newLog "frame/log"
+	"math"
+	"strconv"
+	"time"
+)
+
+// 连接池的默认配置，与 Open 使用的配置一致
+const (
+	defaultMaxIdleConns    = 5
+	defaultMaxOpenConns    = 100
+	defaultConnMaxLifetime = 3 * time.Minute
+	defaultConnMaxIdleTime = time.Minute
+)
+
+// DbConfig 是数据库连接以及连接池的配置，对应配置文件中的：
+//
+//	database:
+//	  dsn: "root:root@tcp(localhost:3306)/blog?parseTime=true"
+//	  max-idle-conns: 10      # 空闲连接的最大数量（-1 不限制）
+//	  max-open-conns: 100     # 打开连接的最大数量（-1 不限制）
+//	  conn-max-lifetime: 3600 # 连接可复用的最大时间，单位秒（-1 不限制）
+//	  conn-max-idle-time: 60  # 连接空闲的最大时间，单位秒（-1 不限制）
+//	  replicas:               # 只读副本的 dsn
+//	    - "root:root@tcp(replica:3306)/blog?parseTime=true"
+type DbConfig struct {
+	DSN             string
+	MaxIdleConns    int
+	MaxOpenConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	Replicas        []string
+}
+
+// LoadDbConfig 从配置文件的数据库配置项 values 中读取数据库配置，未配置的项使用与 Open 相同的默认值。
+// orm 不读取配置文件，values 由调用方传入，例如 config.InitConfig 加载的 yaml 配置或者 app.toml 中的 [database]：
+//
+//	c, err := orm.LoadDbConfig(config.AppConf.GetStringMap("database"))
+//	c, err := orm.LoadDbConfig(config.Conf.Database)
+func LoadDbConfig(values map[string]any) (*DbConfig, error) {
+	if values == nil {
+		return nil, errors.New("database config not found")
+	}
+	c := &DbConfig{
+		MaxIdleConns:    defaultMaxIdleConns,
+		MaxOpenConns:    defaultMaxOpenConns,
+		ConnMaxLifetime: defaultConnMaxLifetime,
+		ConnMaxIdleTime: defaultConnMaxIdleTime,
+	}
+	c.DSN, _ = values["dsn"].(string)
+	if c.DSN == "" {
+		return nil, errors.New("database config has no dsn")
+	}
+	var err error
+	if c.MaxIdleConns, err = configInt(values, "max-idle-conns", c.MaxIdleConns); err != nil {
+		return nil, err
+	}
+	if c.MaxOpenConns, err = configInt(values, "max-open-conns", c.MaxOpenConns); err != nil {
+		return nil, err
+	}
+	lifetime, err := configInt(values, "conn-max-lifetime", int(c.ConnMaxLifetime/time.Second))
+	if err != nil {
+		return nil, err
+	}
+	c.ConnMaxLifetime = time.Duration(lifetime) * time.Second
+	idleTime, err := configInt(values, "conn-max-idle-time", int(c.ConnMaxIdleTime/time.Second))
+	if err != nil {
+		return nil, err
+	}
+	c.ConnMaxIdleTime = time.Duration(idleTime) * time.Second
+	if replicas, ok := values["replicas"].([]any); ok {
+		for _, r := range replicas {
+			if dsn, ok := r.(string); ok && dsn != "" {
+				c.Replicas = append(c.Replicas, dsn)
+			}
+		}
+	}
+	return c, nil
+}
+
+// configInt 读取整数配置项，兼容 yaml、toml 解析出的整数以及字符串，未配置时返回 def。
+func configInt(values map[string]any, key string, def int) (int, error) {
+	v, ok := values[key]
+	if !ok || v == nil {
+		return def, nil
+	}
+	switch v := v.(type) {
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		return int(v), nil
+	case string:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("database config %s: %w", key, err)
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("database config %s: invalid value %v", key, v)
+}
+
+// OpenWithConfig 使用指定的配置打开数据库连接，并按配置设置连接池，配置通常由 LoadDbConfig 读取。
+// 配置了 replicas 时会同时打开只读副本。
+func OpenWithConfig(driverName string, c *DbConfig) (*FrameDb, error) {
+	if c == nil || c.DSN == "" {
+		return nil, errors.New("database dsn is empty")
+	}
+	db, err := sql.Open(driverName, c.DSN)
+	if err != nil {
+		return nil, err
+	}
+	c.apply(db)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	frameDb := &FrameDb{
+		db:         db,
+		logger:     newLog.Default(),
+		dialect:    dialectFor(driverName),
+		driverName: driverName,
+	}
+	frameDb.queryLog.Store(&queryLog{level: LogInfo})
+	for _, dsn := range c.Replicas {
+		if err := frameDb.AddReplica(dsn); err != nil {
+			frameDb.Close()
+			return nil, fmt.Errorf("open replica: %w", err)
+		}
+	}
+	// 副本使用与主库相同的连接池配置
+	for _, r := range frameDb.Replicas() {
+		c.apply(r.DB)
+	}
+	return frameDb, nil
+}
+
+// apply 将连接池配置应用到 db，-1 表示不限制。
+func (c *DbConfig) apply(db *sql.DB) {
+	idle := c.MaxIdleConns
+	if idle < 0 {
+		// database/sql 中空闲连接数小于等于 0 表示不保留空闲连接，不限制时与最大连接数保持一致
+		idle = c.MaxOpenConns
+		if idle <= 0 {
+			idle = math.MaxInt32
+		}
+	}
+	db.SetMaxIdleConns(idle)
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+}
+
+// PoolStats 是连接池的统计信息，字段含义与 sql.DBStats 相同。
+type PoolStats struct {
+	// MaxOpenConnections 是打开连接的最大数量，0 表示不限制。
+	MaxOpenConnections int `json:"max_open_connections"`
+	// OpenConnections 是已经打开的连接数量，包括使用中和空闲的连接。
+	OpenConnections int `json:"open_connections"`
+	// InUse 是使用中的连接数量。
+	InUse int `json:"in_use"`
+	// Idle 是空闲的连接数量。
+	Idle int `json:"idle"`
+	// WaitCount 是等待连接的总次数。
+	WaitCount int64 `json:"wait_count"`
+	// WaitDuration 是等待连接的总时间。
+	WaitDuration time.Duration `json:"wait_duration"`
+	// MaxIdleClosed 是因为超过空闲连接数量而关闭的连接数量。
+	MaxIdleClosed int64 `json:"max_idle_closed"`
+	// MaxIdleTimeClosed 是因为超过空闲时间而关闭的连接数量。
+	MaxIdleTimeClosed int64 `json:"max_idle_time_closed"`
+	// MaxLifetimeClosed 是因为超过最大存活时间而关闭的连接数量。
+	MaxLifetimeClosed int64 `json:"max_lifetime_closed"`
+}
+
+// ReplicaStats 是只读副本的统计信息。
+type ReplicaStats struct {
+	PoolStats
+	// Weight 是副本的权重。
+	Weight int `json:"weight"`
+	// Healthy 表示副本最近一次健康检查是否成功。
+	Healthy bool `json:"healthy"`
+}
+
+// DbStats 是数据库连接的统计信息，可以直接序列化为 JSON 输出给监控系统。
+type DbStats struct {
+	// Driver 是数据库驱动名称。
+	Driver string `json:"driver"`
+	// Primary 是主库连接池的统计信息。
+	Primary PoolStats `json:"primary"`
+	// Replicas 是只读副本连接池的统计信息。
+	Replicas []ReplicaStats `json:"replicas,omitempty"`
+}
+
+// Stats 返回主库以及所有副本连接池的统计信息。
+func (db *FrameDb) Stats() DbStats {
+	stats := DbStats{
+		Driver:  db.driverName,
+		Primary: poolStats(db.db.Stats()),
+	}
+	for _, r := range db.Replicas() {
+		stats.Replicas = append(stats.Replicas, ReplicaStats{
+			PoolStats: poolStats(r.DB.Stats()),
+			Weight:    r.Weight,
+			Healthy:   r.Healthy(),
+		})
+	}
+	return stats
+}
+
+// poolStats 将 sql.DBStats 转换为 PoolStats。
+func poolStats(s sql.DBStats) PoolStats {
+	return PoolStats{
+		MaxOpenConnections: s.MaxOpenConnections,
+		OpenConnections:    s.OpenConnections,
+		InUse:              s.InUse,
+		Idle:               s.Idle,
+		WaitCount:          s.WaitCount,
+		WaitDuration:       s.WaitDuration,
+		MaxIdleClosed:      s.MaxIdleClosed,
+		MaxIdleTimeClosed:  s.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  s.MaxLifetimeClosed,
+	}
+}
@@ -0,0 +1,351 @@
+package orm
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 反射中常用的类型，用于判断字段是否实现了对应的接口
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// timeLayouts 是从字符串解析时间时依次尝试的格式。
+// MySQL 在 DSN 中未设置 parseTime=true 时，时间列会以 []byte 的形式返回。
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"15:04:05",
+}
+
+// ConvertError 表示将数据库列的值转换为结构体字段时发生的错误。
+type ConvertError struct {
+	// Column 是发生错误的列名。
+	Column string
+	// Field 是目标结构体字段名。
+	Field string
+	// Src 是数据库返回的原始值。
+	Src any
+	// Type 是目标字段的类型。
+	Type reflect.Type
+	// Err 是具体的错误原因。
+	Err error
+}
+
+// Error 实现 error 接口。
+func (e *ConvertError) Error() string {
+	return fmt.Sprintf("column %s: cannot convert %T(%v) into field %s of type %s: %v",
+		e.Column, e.Src, e.Src, e.Field, e.Type, e.Err)
+}
+
+// Unwrap 返回具体的错误原因，以便使用 errors.Is/errors.As 进行判断。
+func (e *ConvertError) Unwrap() error {
+	return e.Err
+}
+
+// scanRow 将当前行的数据扫描到 data 指向的结构体中。
+// 参数 columns 是查询结果的列名，未在结构体中找到对应字段的列会被忽略。
+func scanRow(rows *sql.Rows, columns []string, sc *schema, data reflect.Value) error {
+	// 扫描到 any 中，由 convertAssign 负责后续的类型转换
+	values := make([]any, len(columns))
+	fieldScan := make([]any, len(columns))
+	for i := range fieldScan {
+		fieldScan[i] = &values[i]
+	}
+	if err := rows.Scan(fieldScan...); err != nil {
+		return err
+	}
+	return assignRow(columns, values, sc, data)
+}
+
+// assignRow 将一行中各列的原始值赋值到结构体对应的字段中。
+func assignRow(columns []string, values []any, sc *schema, data reflect.Value) error {
+	for j, colName := range columns {
+		f, ok := sc.FieldByColumn(colName)
+		if !ok {
+			f, ok = sc.FieldByColumn(strings.ToLower(colName))
+		}
+		if !ok {
+			continue
+		}
+		if err := convertAssign(data.Field(f.Index), values[j], f.JSON); err != nil {
+			return &ConvertError{Column: colName, Field: f.Name, Src: values[j], Type: f.Type, Err: err}
+		}
+	}
+	return nil
+}
+
+// convertAssign 将数据库驱动返回的值 src 赋值给 dst。
+// 支持以下几种情况：
+//   - src 为 NULL 时，dst 被置为零值（指针字段为 nil，sql.Null* 的 Valid 为 false）；
+//   - dst 实现了 sql.Scanner 接口时，交由其 Scan 方法处理；
+//   - dst 为指针类型时，分配新的值后再进行转换；
+//   - isJSON 为 true 时，将 src 作为 JSON 反序列化到 dst 中；
+//   - 其余情况按照字符串、整数、浮点数、布尔值、时间等类型进行转换。
+func convertAssign(dst reflect.Value, src any, isJSON bool) error {
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	if dst.CanAddr() && dst.Addr().Type().Implements(scannerType) {
+		return dst.Addr().Interface().(sql.Scanner).Scan(src)
+	}
+	if dst.Kind() == reflect.Pointer {
+		elem := reflect.New(dst.Type().Elem())
+		if err := convertAssign(elem.Elem(), src, isJSON); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+	}
+	if isJSON {
+		b, err := asBytes(src)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(b, dst.Addr().Interface())
+	}
+
+	switch dst.Kind() {
+	case reflect.String:
+		switch v := src.(type) {
+		case []byte:
+			dst.SetString(string(v))
+		case string:
+			dst.SetString(v)
+		case time.Time:
+			dst.SetString(v.Format(time.RFC3339Nano))
+		default:
+			dst.SetString(fmt.Sprint(v))
+		}
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := asInt(src)
+		if err != nil {
+			return err
+		}
+		if dst.OverflowInt(n) {
+			return fmt.Errorf("value %d overflows %s", n, dst.Type())
+		}
+		dst.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := asUint(src)
+		if err != nil {
+			return err
+		}
+		if dst.OverflowUint(n) {
+			return fmt.Errorf("value %d overflows %s", n, dst.Type())
+		}
+		dst.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		n, err := asFloat(src)
+		if err != nil {
+			return err
+		}
+		if dst.OverflowFloat(n) {
+			return fmt.Errorf("value %v overflows %s", n, dst.Type())
+		}
+		dst.SetFloat(n)
+		return nil
+	case reflect.Bool:
+		b, err := asBool(src)
+		if err != nil {
+			return err
+		}
+		dst.SetBool(b)
+		return nil
+	case reflect.Slice:
+		// []byte 字段需要拷贝一份数据，驱动可能会复用底层的缓冲区
+		if dst.Type().Elem().Kind() == reflect.Uint8 {
+			b, err := asBytes(src)
+			if err != nil {
+				return err
+			}
+			dst.SetBytes(append([]byte(nil), b...))
+			return nil
+		}
+	case reflect.Struct:
+		if dst.Type() == timeType {
+			t, err := asTime(src)
+			if err != nil {
+				return err
+			}
+			dst.Set(reflect.ValueOf(t))
+			return nil
+		}
+	}
+
+	// 兜底：类型可以直接转换时使用反射进行转换
+	sv := reflect.ValueOf(src)
+	if sv.Type().ConvertibleTo(dst.Type()) {
+		dst.Set(sv.Convert(dst.Type()))
+		return nil
+	}
+	return fmt.Errorf("unsupported conversion from %T to %s", src, dst.Type())
+}
+
+// fieldValue 返回字段写入数据库时使用的值。
+// JSON 字段会被序列化为字符串，其余字段原样返回，由 database/sql 处理指针和 driver.Valuer。
+func fieldValue(f *field, v reflect.Value) (any, error) {
+	if !f.JSON {
+		return v.Interface(), nil
+	}
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return nil, nil
+	}
+	if v.Type().Implements(valuerType) {
+		return v.Interface(), nil
+	}
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, fmt.Errorf("field %s: %w", f.Name, err)
+	}
+	return string(b), nil
+}
+
+// asBytes 将原始值转换为字节切片。
+func asBytes(src any) ([]byte, error) {
+	switch v := src.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	default:
+		return nil, fmt.Errorf("cannot convert %T to []byte", src)
+	}
+}
+
+// asInt 将原始值转换为 int64。
+func asInt(src any) (int64, error) {
+	switch v := src.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case uint64:
+		if v > 1<<63-1 {
+			return 0, fmt.Errorf("value %d overflows int64", v)
+		}
+		return int64(v), nil
+	case float64:
+		// 小数以及超出 int64 范围的值不能转换为整数，避免静默截断
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, fmt.Errorf("value %v is not an integer", v)
+		}
+		return int64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case []byte:
+		return strconv.ParseInt(strings.TrimSpace(string(v)), 10, 64)
+	case string:
+		return strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	default:
+		return 0, fmt.Errorf("cannot convert %T to integer", src)
+	}
+}
+
+// asUint 将原始值转换为 uint64。
+func asUint(src any) (uint64, error) {
+	switch v := src.(type) {
+	case uint64:
+		return v, nil
+	case int64:
+		if v < 0 {
+			return 0, fmt.Errorf("negative value %d for unsigned field", v)
+		}
+		return uint64(v), nil
+	case []byte:
+		return strconv.ParseUint(strings.TrimSpace(string(v)), 10, 64)
+	case string:
+		return strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+	default:
+		n, err := asInt(src)
+		if err != nil {
+			return 0, err
+		}
+		if n < 0 {
+			return 0, fmt.Errorf("negative value %d for unsigned field", n)
+		}
+		return uint64(n), nil
+	}
+}
+
+// asFloat 将原始值转换为 float64。
+func asFloat(src any) (float64, error) {
+	switch v := src.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case []byte:
+		return strconv.ParseFloat(strings.TrimSpace(string(v)), 64)
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	default:
+		return 0, fmt.Errorf("cannot convert %T to float", src)
+	}
+}
+
+// asBool 将原始值转换为 bool，MySQL 中的 tinyint(1) 会以整数返回。
+func asBool(src any) (bool, error) {
+	switch v := src.(type) {
+	case bool:
+		return v, nil
+	case int64:
+		return v != 0, nil
+	case []byte:
+		return strconv.ParseBool(strings.TrimSpace(string(v)))
+	case string:
+		return strconv.ParseBool(strings.TrimSpace(v))
+	default:
+		return false, fmt.Errorf("cannot convert %T to bool", src)
+	}
+}
+
+// asTime 将原始值转换为 time.Time。没有时区信息的字符串按 UTC 解析，与 MySQL 驱动默认的 loc=UTC 一致，
+// 结果不依赖运行程序的机器的时区。
+func asTime(src any) (time.Time, error) {
+	var s string
+	switch v := src.(type) {
+	case time.Time:
+		return v, nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		return time.Unix(v, 0).UTC(), nil
+	default:
+		return time.Time{}, fmt.Errorf("cannot convert %T to time.Time", src)
+	}
+	// MySQL 中的零值时间
+	if strings.HasPrefix(s, "0000-00-00") {
+		return time.Time{}, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as time", s)
+}
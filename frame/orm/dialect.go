@@ -0,0 +1,192 @@
+package orm
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Dialect 描述了不同数据库之间的 SQL 方言差异。
+// 会话内部统一使用 ? 作为占位符构建 SQL，执行前由方言改写为数据库实际使用的占位符。
+type Dialect interface {
+	// Name 返回方言的名称，例如 mysql、postgres、sqlite3。
+	Name() string
+	// BindVar 返回第 i 个（从 1 开始）参数的占位符。
+	BindVar(i int) string
+	// Quote 返回使用标识符引号包裹后的表名或列名。
+	Quote(name string) string
+	// OnConflict 返回追加在 INSERT 语句之后的冲突处理子句。
+	OnConflict(c *Conflict) string
+	// Returning 返回追加在 INSERT 语句之后用于返回列值的子句，数据库支持 LastInsertId 时返回空字符串。
+	Returning(column string) string
+}
+
+// mysqlDialect 是 MySQL 的方言实现。
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string { return "mysql" }
+
+func (mysqlDialect) BindVar(int) string { return "?" }
+
+func (mysqlDialect) Quote(name string) string { return quoteIdent(name, "`") }
+
+// OnConflict 生成 ON DUPLICATE KEY UPDATE 子句，MySQL 根据表中任意唯一索引判断冲突，忽略冲突列。
+// 存在自增主键时追加 id = LAST_INSERT_ID(id)，使 LastInsertId 在更新时返回已存在记录的 ID。
+func (mysqlDialect) OnConflict(c *Conflict) string {
+	if c.DoNothing || len(c.Updates) == 0 {
+		// 将某一列更新为自身，冲突时不做任何修改，也不会像 INSERT IGNORE 那样忽略其它错误
+		column := c.Key
+		if column == "" && len(c.Columns) > 0 {
+			column = c.Columns[0]
+		}
+		if column == "" && len(c.Inserted) > 0 {
+			column = c.Inserted[0]
+		}
+		return " on duplicate key update " + column + " = " + column
+	}
+	sets := make([]string, 0, len(c.Updates)+1)
+	for _, column := range c.Updates {
+		sets = append(sets, column+" = values("+column+")")
+	}
+	if c.Key != "" {
+		sets = append(sets, c.Key+" = last_insert_id("+c.Key+")")
+	}
+	return " on duplicate key update " + strings.Join(sets, ",")
+}
+
+func (mysqlDialect) Returning(string) string { return "" }
+
+// postgresDialect 是 PostgreSQL 的方言实现，占位符为 $1、$2 ...
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string { return "postgres" }
+
+func (postgresDialect) BindVar(i int) string { return "$" + strconv.Itoa(i) }
+
+func (postgresDialect) Quote(name string) string { return quoteIdent(name, `"`) }
+
+func (postgresDialect) OnConflict(c *Conflict) string { return onConflictExcluded(c) }
+
+// Returning 使用 RETURNING 返回插入或更新的记录的主键，PostgreSQL 不支持 LastInsertId。
+func (postgresDialect) Returning(column string) string {
+	if column == "" {
+		return ""
+	}
+	return " returning " + column
+}
+
+// sqliteDialect 是 SQLite 的方言实现。
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string { return "sqlite3" }
+
+func (sqliteDialect) BindVar(int) string { return "?" }
+
+func (sqliteDialect) Quote(name string) string { return quoteIdent(name, `"`) }
+
+func (sqliteDialect) OnConflict(c *Conflict) string { return onConflictExcluded(c) }
+
+func (sqliteDialect) Returning(string) string { return "" }
+
+// onConflictExcluded 生成 PostgreSQL 和 SQLite 通用的 ON CONFLICT 子句，使用 excluded 引用插入的新值。
+// DO UPDATE 必须指定冲突列，未指定时使用主键。
+func onConflictExcluded(c *Conflict) string {
+	var sb strings.Builder
+	sb.WriteString(" on conflict")
+	columns := c.Columns
+	if len(columns) == 0 && c.Key != "" && !c.DoNothing {
+		columns = []string{c.Key}
+	}
+	if len(columns) > 0 {
+		sb.WriteString(" (")
+		sb.WriteString(strings.Join(columns, ","))
+		sb.WriteString(")")
+	}
+	if c.DoNothing || len(c.Updates) == 0 {
+		sb.WriteString(" do nothing")
+		return sb.String()
+	}
+	sb.WriteString(" do update set ")
+	for i, column := range c.Updates {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(column + " = excluded." + column)
+	}
+	return sb.String()
+}
+
+// dialects 保存了驱动名称到方言的映射。
+var (
+	dialectsMu sync.RWMutex
+	dialects   = map[string]Dialect{
+		"mysql":    mysqlDialect{},
+		"postgres": postgresDialect{},
+		"pgx":      postgresDialect{},
+		"sqlite3":  sqliteDialect{},
+		"sqlite":   sqliteDialect{},
+	}
+)
+
+// RegisterDialect 为指定的驱动名称注册方言，已存在的方言会被覆盖。
+func RegisterDialect(driverName string, d Dialect) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	dialects[driverName] = d
+}
+
+// dialectFor 返回驱动名称对应的方言，未注册的驱动默认使用 MySQL 方言。
+func dialectFor(driverName string) Dialect {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	if d, ok := dialects[driverName]; ok {
+		return d
+	}
+	return mysqlDialect{}
+}
+
+// LookupDialect 返回驱动名称对应的方言，未注册的驱动返回 MySQL 方言，用于为包装了其它驱动的驱动注册方言。
+func LookupDialect(driverName string) Dialect {
+	return dialectFor(driverName)
+}
+
+// quoteIdent 使用引号 q 包裹标识符，带有表名前缀的标识符（例如 u.id）会分别包裹。
+func quoteIdent(name string, q string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		if p == "*" {
+			continue
+		}
+		parts[i] = q + strings.ReplaceAll(p, q, q+q) + q
+	}
+	return strings.Join(parts, ".")
+}
+
+// rebind 将 SQL 中的 ? 占位符按顺序改写为方言对应的占位符。
+// 引号中的 ? 不是占位符，会被原样保留。
+func rebind(d Dialect, query string) string {
+	if d == nil || d.BindVar(1) == "?" {
+		return query
+	}
+	var sb strings.Builder
+	sb.Grow(len(query) + 8)
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			n++
+			sb.WriteString(d.BindVar(n))
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
@@ -0,0 +1,173 @@
+package orm
+
+import (
+	"database/sql"
+	"reflect"
+	"time"
+)
+
+// BeforeInsertHook 由需要在插入前执行逻辑的模型实现，返回错误时中止插入。
+type BeforeInsertHook interface {
+	BeforeInsert(s *FrameSession) error
+}
+
+// AfterInsertHook 由需要在插入后执行逻辑的模型实现，返回错误时插入会被回滚。
+type AfterInsertHook interface {
+	AfterInsert(s *FrameSession) error
+}
+
+// BeforeUpdateHook 由需要在更新前执行逻辑的模型实现，返回错误时中止更新。
+type BeforeUpdateHook interface {
+	BeforeUpdate(s *FrameSession) error
+}
+
+// AfterUpdateHook 由需要在更新后执行逻辑的模型实现，返回错误时更新会被回滚。
+type AfterUpdateHook interface {
+	AfterUpdate(s *FrameSession) error
+}
+
+// BeforeDeleteHook 由需要在删除前执行逻辑的模型实现，返回错误时中止删除。
+type BeforeDeleteHook interface {
+	BeforeDelete(s *FrameSession) error
+}
+
+// AfterDeleteHook 由需要在删除后执行逻辑的模型实现，返回错误时删除会被回滚。
+type AfterDeleteHook interface {
+	AfterDelete(s *FrameSession) error
+}
+
+// AfterFindHook 由需要在查询结果映射完成后执行逻辑的模型实现，返回错误时查询返回该错误。
+type AfterFindHook interface {
+	AfterFind(s *FrameSession) error
+}
+
+// hook 表示一个生命周期钩子。
+type hook int
+
+const (
+	hookBeforeInsert hook = iota
+	hookAfterInsert
+	hookBeforeUpdate
+	hookAfterUpdate
+	hookBeforeDelete
+	hookAfterDelete
+	hookAfterFind
+)
+
+// callHook 在模型实现了对应的钩子接口时调用它，model 为 nil 时不做任何事情。
+func (s *FrameSession) callHook(model any, h hook) error {
+	if model == nil {
+		return nil
+	}
+	switch h {
+	case hookBeforeInsert:
+		if m, ok := model.(BeforeInsertHook); ok {
+			return m.BeforeInsert(s)
+		}
+	case hookAfterInsert:
+		if m, ok := model.(AfterInsertHook); ok {
+			return m.AfterInsert(s)
+		}
+	case hookBeforeUpdate:
+		if m, ok := model.(BeforeUpdateHook); ok {
+			return m.BeforeUpdate(s)
+		}
+	case hookAfterUpdate:
+		if m, ok := model.(AfterUpdateHook); ok {
+			return m.AfterUpdate(s)
+		}
+	case hookBeforeDelete:
+		if m, ok := model.(BeforeDeleteHook); ok {
+			return m.BeforeDelete(s)
+		}
+	case hookAfterDelete:
+		if m, ok := model.(AfterDeleteHook); ok {
+			return m.AfterDelete(s)
+		}
+	case hookAfterFind:
+		if m, ok := model.(AfterFindHook); ok {
+			return m.AfterFind(s)
+		}
+	}
+	return nil
+}
+
+// hasHook 判断模型是否实现了对应的钩子接口。
+func hasHook(model any, h hook) bool {
+	switch h {
+	case hookAfterInsert:
+		_, ok := model.(AfterInsertHook)
+		return ok
+	case hookAfterUpdate:
+		_, ok := model.(AfterUpdateHook)
+		return ok
+	case hookAfterDelete:
+		_, ok := model.(AfterDeleteHook)
+		return ok
+	}
+	return false
+}
+
+// withHookTx 执行 fn，need 为 true 且会话不在事务中时，在一个新事务中执行，
+// 这样 After 钩子返回错误时已经执行的 SQL 可以被回滚。
+// 会话已在事务中时，错误交由外层事务处理（Transaction 会自动回滚）。
+func (s *FrameSession) withHookTx(need bool, fn func() error) (err error) {
+	// 试运行时不执行 SQL，也不需要事务
+	if !need || s.beginTx || s.dryRun != nil {
+		return fn()
+	}
+	if err := s.Begin(); err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			_ = s.Rollback()
+			panic(r)
+		}
+	}()
+	if err := fn(); err != nil {
+		_ = s.Rollback()
+		return err
+	}
+	return s.Commit()
+}
+
+// setCreateTime 在插入前为值为零的 CreatedAt、UpdatedAt 字段设置当前时间。
+func setCreateTime(sc *schema, v reflect.Value, now time.Time) {
+	for _, f := range []*field{sc.CreatedAt, sc.UpdatedAt} {
+		if f != nil && v.Field(f.Index).IsZero() {
+			setTime(v.Field(f.Index), now)
+		}
+	}
+}
+
+// setUpdateTime 在更新前为 UpdatedAt 字段设置当前时间。
+func setUpdateTime(sc *schema, v reflect.Value, now time.Time) {
+	if sc.UpdatedAt != nil {
+		setTime(v.Field(sc.UpdatedAt.Index), now)
+	}
+}
+
+// setTime 将时间赋值给字段，支持 time.Time、*time.Time、sql.NullTime 以及表示 Unix 秒数的整数字段。
+func setTime(v reflect.Value, now time.Time) {
+	switch {
+	case v.Type() == timeType:
+		v.Set(reflect.ValueOf(now))
+	case v.Type() == nullTimeType:
+		v.Set(reflect.ValueOf(sql.NullTime{Time: now, Valid: true}))
+	case v.Kind() == reflect.Pointer && v.Type().Elem() == timeType:
+		v.Set(reflect.ValueOf(&now))
+	case v.CanInt():
+		v.SetInt(now.Unix())
+	case v.CanUint():
+		v.SetUint(uint64(now.Unix()))
+	}
+}
+
+// timeValue 返回写入数据库时使用的时间值，与 setTime 设置的字段类型对应。
+func timeValue(f *field, now time.Time) any {
+	if isIntField(f) {
+		return now.Unix()
+	}
+	return now
+}
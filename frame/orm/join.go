@@ -0,0 +1,91 @@
+package orm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Alias 设置当前表的别名，用于多表查询时区分不同表的列，例如：Alias("u").Where("u.id", 1)。
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) Alias(alias string) *FrameSession {
+	s.alias = alias
+	return s
+}
+
+// Join 添加一个内连接。
+// 参数 table 是连接的表名（可以带别名，例如 "order o"），on 是连接条件，使用方式与 Where 相同，
+// 例如：Join("order o", "o.user_id = u.id")、Join("order o", orm.Expr("o.user_id = u.id and o.status = ?", 1))。
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) Join(table string, on any, args ...any) *FrameSession {
+	return s.join("inner join", table, on, args)
+}
+
+// LeftJoin 添加一个左连接，参数与 Join 相同。
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) LeftJoin(table string, on any, args ...any) *FrameSession {
+	return s.join("left join", table, on, args)
+}
+
+// RightJoin 添加一个右连接，参数与 Join 相同。
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) RightJoin(table string, on any, args ...any) *FrameSession {
+	return s.join("right join", table, on, args)
+}
+
+// join 向JOIN子句追加一个连接。
+func (s *FrameSession) join(kind string, table string, on any, args []any) *FrameSession {
+	cond, values := toCondition(on, args).Build()
+	s.joinParam.WriteString(" ")
+	s.joinParam.WriteString(kind)
+	s.joinParam.WriteString(" ")
+	s.joinParam.WriteString(table)
+	s.joinParam.WriteString(" on ")
+	s.joinParam.WriteString(cond)
+	s.joinValues = append(s.joinValues, values...)
+	return s
+}
+
+// From 使用子查询作为查询的数据来源，例如：
+//
+//	sub := db.New(&Order{}).Group("user_id").SubQuery("user_id", "sum(amount) as total")
+//	db.New(&UserTotal{}).From(sub, "t").Where(orm.Gt("t.total", 100)).Select(&UserTotal{})
+//
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) From(sub Condition, alias string) *FrameSession {
+	query, values := sub.Build()
+	s.tableName = query
+	s.alias = alias
+	s.fromValues = values
+	return s
+}
+
+// SubQuery 将当前会话构建的查询作为子查询返回，可用于 In、比较条件的值以及 From 中。
+// 参数 fields 是子查询选择的列，未提供时选择所有列。
+func (s *FrameSession) SubQuery(fields ...string) *Expression {
+	fieldStr := "*"
+	if len(fields) > 0 {
+		fieldStr = strings.Join(fields, ",")
+	}
+	query := fmt.Sprintf("(select %s from %s %s)", fieldStr, s.tableSQL(), strings.TrimSpace(s.conditionSQL()))
+	return Expr(query, s.queryValues()...)
+}
+
+// tableSQL 返回查询语句FROM之后的部分，包括表名、别名以及JOIN子句。
+func (s *FrameSession) tableSQL() string {
+	var sb strings.Builder
+	sb.WriteString(s.tableName)
+	if s.alias != "" {
+		sb.WriteString(" ")
+		sb.WriteString(s.alias)
+	}
+	sb.WriteString(s.joinParam.String())
+	return sb.String()
+}
+
+// queryValues 返回查询语句中按占位符顺序排列的全部参数：FROM子查询、JOIN、WHERE、HAVING。
+func (s *FrameSession) queryValues() []any {
+	values := make([]any, 0, len(s.fromValues)+len(s.joinValues)+len(s.whereValues)+len(s.havingValues))
+	values = append(values, s.fromValues...)
+	values = append(values, s.joinValues...)
+	return append(values, s.conditionValues()...)
+}
@@ -0,0 +1,70 @@
+package orm
+
+import (
+	"errors"
+	"reflect"
+)
+
+// ErrStaleObject 表示乐观锁更新失败：记录已经被其它会话修改（版本号不一致）或者已经不存在。
+// 调用方可以使用 errors.Is 判断，重新读取记录后重试。
+var ErrStaleObject = errors.New("orm: stale object")
+
+// 乐观锁：结构体中使用 version 选项标记的整数字段是版本号，例如：
+//
+//	type Order struct {
+//		Id      int64
+//		Status  int
+//		Version int64 `gorm:"version,version"`
+//	}
+//
+// 使用结构体调用 Update 时，会追加 version = ? 条件（结构体中的版本号）并将版本号加一，
+// 没有记录被更新时返回 ErrStaleObject，更新成功后结构体中的版本号同步加一。
+// 按列更新时版本号同样加一，使其它持有旧版本的会话更新失败。
+
+// versionField 返回会话模型的版本号字段，没有时返回 nil。
+func (s *FrameSession) versionField() *field {
+	if s.model == nil {
+		return nil
+	}
+	sc, err := parseSchema(reflect.TypeOf(s.model))
+	if err != nil {
+		return nil
+	}
+	return sc.Version
+}
+
+// setVersion 在SET子句中追加版本号加一，已经手动设置了版本号列时不做处理。
+func (s *FrameSession) setVersion(f *field) {
+	if contains(s.updateFields, f.Column) {
+		return
+	}
+	s.setParam(f.Column, Expr(f.Column+" + 1"))
+}
+
+// whereVersion 在WHERE子句中追加版本号条件，已有的条件作为一个整体，避免与 or 条件混合。
+func (s *FrameSession) whereVersion(f *field, version any) {
+	where := s.whereParam.String()
+	s.whereParam.Reset()
+	s.whereConn = ""
+	s.whereWrap = false
+	if where == "" {
+		s.whereParam.WriteString(" where ")
+	} else {
+		s.whereParam.WriteString(" where (")
+		s.whereParam.WriteString(where[len(" where "):])
+		s.whereParam.WriteString(") and ")
+	}
+	s.whereParam.WriteString(f.Column)
+	s.whereParam.WriteString(" = ?")
+	s.whereValues = append(s.whereValues, version)
+}
+
+// incrementVersion 将结构体中的版本号加一，与数据库中的值保持一致。
+func incrementVersion(f *field, v reflect.Value) {
+	fv := v.Field(f.Index)
+	if fv.CanInt() {
+		fv.SetInt(fv.Int() + 1)
+	} else {
+		fv.SetUint(fv.Uint() + 1)
+	}
+}
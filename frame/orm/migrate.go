@@ -0,0 +1,350 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 迁移时需要特殊处理的类型
+var (
+	bytesType       = reflect.TypeOf([]byte(nil))
+	nullStringType  = reflect.TypeOf(sql.NullString{})
+	nullInt64Type   = reflect.TypeOf(sql.NullInt64{})
+	nullInt32Type   = reflect.TypeOf(sql.NullInt32{})
+	nullInt16Type   = reflect.TypeOf(sql.NullInt16{})
+	nullFloat64Type = reflect.TypeOf(sql.NullFloat64{})
+	nullBoolType    = reflect.TypeOf(sql.NullBool{})
+	nullTimeType    = reflect.TypeOf(sql.NullTime{})
+)
+
+// AutoMigrate 根据结构体的定义自动创建或更新数据表：
+//   - 表不存在时执行 CREATE TABLE，并为标记了 index/unique 选项的字段创建索引；
+//   - 表已存在时为新增的字段执行 ALTER TABLE ADD COLUMN 并创建对应的索引。
+//
+// AutoMigrate 不会删除或修改已有的列，这类变更需要使用 orm/migrate 中的版本化迁移。
+// 字段通过 gorm 标签控制生成的列定义，例如：
+//
+//	type User struct {
+//		Id       int64  `gorm:"id,auto_increment"`
+//		UserName string `gorm:"user_name,size:64,unique"`
+//		Age      int    `gorm:"age,not null,default:0,index"`
+//		Remark   string `gorm:"remark,type:text"`
+//	}
+func (db *FrameDb) AutoMigrate(models ...any) error {
+	for _, model := range models {
+		if err := db.New(model).AutoMigrate(model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AutoMigrate 根据结构体 model 的定义自动创建或更新当前会话对应的数据表，
+// 可以配合 Table 为结构体指定表名。规则与 FrameDb.AutoMigrate 相同。
+func (s *FrameSession) AutoMigrate(model any) error {
+	sc, err := parseSchema(reflect.TypeOf(model))
+	if err != nil {
+		return err
+	}
+	if s.tableName == "" {
+		s.tableName = s.db.Prefix + strings.ToLower(Name(sc.Type.Name()))
+	}
+	columns, exists := s.tableColumns()
+
+	var statements []string
+	var indexFields []*field
+	if !exists {
+		statements = append(statements, createTableSQL(s.db.dialect, s.tableName, sc))
+		indexFields = sc.Fields
+	} else {
+		for _, f := range sc.Fields {
+			if columns[strings.ToLower(f.Column)] {
+				continue
+			}
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s",
+				s.db.dialect.Quote(s.tableName), columnSQL(s.db.dialect, f)))
+			indexFields = append(indexFields, f)
+		}
+	}
+	statements = append(statements, createIndexSQL(s.db.dialect, s.tableName, indexFields)...)
+	joinTables, err := s.joinTableSQL(sc)
+	if err != nil {
+		return err
+	}
+	statements = append(statements, joinTables...)
+
+	for _, statement := range statements {
+		if _, err := s.ExecContext(context.Background(), statement); err != nil {
+			return fmt.Errorf("migrate %s: %w", s.tableName, err)
+		}
+	}
+	return nil
+}
+
+// joinTableSQL 为多对多关联生成不存在的中间表的建表语句，中间表的两列分别引用两张表的主键。
+func (s *FrameSession) joinTableSQL(sc *schema) ([]string, error) {
+	statements := make([]string, 0)
+	for _, rel := range sc.Relations {
+		if rel.Kind != manyToMany {
+			continue
+		}
+		if _, exists := s.New(nil).Table(rel.JoinTable).tableColumns(); exists {
+			continue
+		}
+		child, err := parseSchema(rel.Elem)
+		if err != nil {
+			return nil, err
+		}
+		ownerKey, err := referenceField(sc, "")
+		if err != nil {
+			return nil, err
+		}
+		ref, err := referenceField(child, rel.References)
+		if err != nil {
+			return nil, err
+		}
+		d := s.db.dialect
+		fk := &field{Column: rel.JoinForeignKey, Type: ownerKey.Type, PrimaryKey: true}
+		rk := &field{Column: rel.JoinReferences, Type: ref.Type, PrimaryKey: true}
+		statements = append(statements, fmt.Sprintf("CREATE TABLE %s (%s, %s, PRIMARY KEY (%s, %s))",
+			d.Quote(rel.JoinTable), columnSQL(d, fk), columnSQL(d, rk), d.Quote(fk.Column), d.Quote(rk.Column)))
+	}
+	return statements, nil
+}
+
+// tableColumns 返回表中已有的列名（小写），表不存在时 exists 为 false。
+// 通过查询一条不返回数据的语句获取列信息，不依赖各数据库的元数据表。
+func (s *FrameSession) tableColumns() (columns map[string]bool, exists bool) {
+	// 表不存在时查询会失败，这是预期的结果，不记录 SQL 日志；试运行时视为表不存在
+	if s.dryRun != nil {
+		return nil, false
+	}
+	query := fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", s.db.dialect.Quote(s.tableName))
+	rows, err := s.conn(false).Query(query)
+	if err != nil {
+		return nil, false
+	}
+	defer rows.Close()
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, false
+	}
+	columns = make(map[string]bool, len(names))
+	for _, name := range names {
+		columns[strings.ToLower(name)] = true
+	}
+	return columns, true
+}
+
+// createTableSQL 生成建表语句。
+func createTableSQL(d Dialect, table string, sc *schema) string {
+	defs := make([]string, 0, len(sc.Fields)+1)
+	for _, f := range sc.Fields {
+		defs = append(defs, columnSQL(d, f))
+	}
+	// SQLite 的自增主键需要在列定义中声明，不能再单独声明主键
+	if pk := sc.PrimaryKey(); pk != nil && !(d.Name() == "sqlite3" && pk.AutoIncrement) {
+		defs = append(defs, fmt.Sprintf("PRIMARY KEY (%s)", d.Quote(pk.Column)))
+	}
+	return fmt.Sprintf("CREATE TABLE %s (%s)", d.Quote(table), strings.Join(defs, ", "))
+}
+
+// columnSQL 生成单个列的定义。
+func columnSQL(d Dialect, f *field) string {
+	var sb strings.Builder
+	sb.WriteString(d.Quote(f.Column))
+	sb.WriteString(" ")
+	sb.WriteString(columnType(d, f))
+	_, notNull := f.Options["not null"]
+	if _, ok := f.Options["not_null"]; ok {
+		notNull = true
+	}
+	if notNull || f.PrimaryKey {
+		sb.WriteString(" NOT NULL")
+	}
+	if def, ok := f.Options["default"]; ok && def != "" {
+		sb.WriteString(" DEFAULT ")
+		sb.WriteString(def)
+	}
+	return sb.String()
+}
+
+// createIndexSQL 为标记了 index 或 unique 选项的字段生成建索引语句。
+// 选项可以携带索引名称，例如 index:idx_user_age，未指定时使用 idx_表名_列名。
+func createIndexSQL(d Dialect, table string, fields []*field) []string {
+	statements := make([]string, 0)
+	for _, f := range fields {
+		for _, kind := range []string{"index", "unique"} {
+			name, ok := f.Options[kind]
+			if !ok {
+				continue
+			}
+			if name == "" {
+				name = "idx_" + table + "_" + f.Column
+			}
+			unique := ""
+			if kind == "unique" {
+				unique = "UNIQUE "
+			}
+			statements = append(statements, fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)",
+				unique, d.Quote(name), d.Quote(table), d.Quote(f.Column)))
+		}
+	}
+	return statements
+}
+
+// columnType 返回字段在指定方言中的列类型，可以通过 type 选项直接指定。
+func columnType(d Dialect, f *field) string {
+	if typ, ok := f.Options["type"]; ok && typ != "" {
+		return typ
+	}
+	size := 255
+	if v, ok := f.Options["size"]; ok {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			size = n
+		}
+	}
+
+	t := f.Type
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	kind := t.Kind()
+	switch t {
+	case timeType, nullTimeType:
+		kind = reflect.Struct
+	case nullStringType:
+		kind = reflect.String
+	case nullInt64Type:
+		kind = reflect.Int64
+	case nullInt32Type:
+		kind = reflect.Int32
+	case nullInt16Type:
+		kind = reflect.Int16
+	case nullFloat64Type:
+		kind = reflect.Float64
+	case nullBoolType:
+		kind = reflect.Bool
+	case bytesType:
+		kind = reflect.Slice
+	}
+	if f.JSON {
+		kind = reflect.Map
+	}
+
+	switch d.Name() {
+	case "postgres":
+		return postgresType(kind, f, size)
+	case "sqlite3":
+		return sqliteType(kind, f)
+	default:
+		return mysqlType(kind, f, size)
+	}
+}
+
+// mysqlType 返回 MySQL 中的列类型。
+func mysqlType(kind reflect.Kind, f *field, size int) string {
+	auto := ""
+	if f.AutoIncrement {
+		auto = " AUTO_INCREMENT"
+	}
+	switch kind {
+	case reflect.Bool:
+		return "tinyint(1)"
+	case reflect.Int8:
+		return "tinyint" + auto
+	case reflect.Int16:
+		return "smallint" + auto
+	case reflect.Int32:
+		return "int" + auto
+	case reflect.Int, reflect.Int64:
+		return "bigint" + auto
+	case reflect.Uint8:
+		return "tinyint unsigned" + auto
+	case reflect.Uint16:
+		return "smallint unsigned" + auto
+	case reflect.Uint32:
+		return "int unsigned" + auto
+	case reflect.Uint, reflect.Uint64:
+		return "bigint unsigned" + auto
+	case reflect.Float32:
+		return "float"
+	case reflect.Float64:
+		return "double"
+	case reflect.String:
+		if size > 65535 {
+			return "longtext"
+		}
+		return fmt.Sprintf("varchar(%d)", size)
+	case reflect.Struct:
+		return "datetime(3)"
+	case reflect.Map:
+		return "json"
+	default:
+		return "longblob"
+	}
+}
+
+// postgresType 返回 PostgreSQL 中的列类型。
+func postgresType(kind reflect.Kind, f *field, size int) string {
+	switch kind {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		if f.AutoIncrement {
+			return "smallserial"
+		}
+		return "smallint"
+	case reflect.Int32, reflect.Uint16:
+		if f.AutoIncrement {
+			return "serial"
+		}
+		return "integer"
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		if f.AutoIncrement {
+			return "bigserial"
+		}
+		return "bigint"
+	case reflect.Float32:
+		return "real"
+	case reflect.Float64:
+		return "double precision"
+	case reflect.String:
+		if size > 10485760 {
+			return "text"
+		}
+		return fmt.Sprintf("varchar(%d)", size)
+	case reflect.Struct:
+		return "timestamptz"
+	case reflect.Map:
+		return "jsonb"
+	default:
+		return "bytea"
+	}
+}
+
+// sqliteType 返回 SQLite 中的列类型。
+func sqliteType(kind reflect.Kind, f *field) string {
+	switch kind {
+	case reflect.Bool:
+		return "numeric"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if f.AutoIncrement {
+			return "integer PRIMARY KEY AUTOINCREMENT"
+		}
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "real"
+	case reflect.String, reflect.Map:
+		return "text"
+	case reflect.Struct:
+		return "datetime"
+	default:
+		return "blob"
+	}
+}
@@ -0,0 +1,113 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// usage 是命令行工具的帮助信息。
+const usage = `usage: migrate <command> [args]
+
+commands:
+  up               执行所有未执行的迁移
+  down [n]         回退最近执行的 n 个迁移，默认为 1
+  status           查看所有迁移的执行状态
+  create <name>    在迁移目录中创建新的 up/down 迁移文件
+`
+
+// Command 执行迁移命令行工具的子命令，输出写入 out。
+// 参数 open 用于创建迁移执行器，只在 up、down、status 这些需要连接数据库的子命令中调用，create 不需要数据库；
+// dir 是 SQL 迁移文件所在的目录，args 是去掉程序名后的命令行参数，例如 []string{"down", "2"}。
+func Command(open func() (*Migrator, error), dir string, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(out, usage)
+		return errors.New("missing command")
+	}
+	ctx := context.Background()
+	switch args[0] {
+	case "create":
+		if len(args) < 2 {
+			return errors.New("create: missing migration name")
+		}
+		return create(dir, args[1], out)
+	case "up":
+		m, err := load(open, dir)
+		if err != nil {
+			return err
+		}
+		return m.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("down: invalid steps %q", args[1])
+			}
+			steps = n
+		}
+		m, err := load(open, dir)
+		if err != nil {
+			return err
+		}
+		return m.Down(ctx, steps)
+	case "status":
+		m, err := load(open, dir)
+		if err != nil {
+			return err
+		}
+		list, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range list {
+			state := "pending"
+			if st.Applied {
+				state = "applied " + st.AppliedAt.Format("2006/01/02 - 15:04:05")
+			}
+			if st.Dirty {
+				state += " (modified)"
+			}
+			fmt.Fprintf(out, "%s_%s\t%s\n", st.Version, st.Name, state)
+		}
+		return nil
+	default:
+		fmt.Fprint(out, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// load 创建迁移执行器并加载 dir 中的 SQL 迁移文件。
+func load(open func() (*Migrator, error), dir string) (*Migrator, error) {
+	m, err := open()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.LoadDir(dir); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// create 使用当前时间作为版本号创建一对空的迁移文件。
+func create(dir string, name string, out io.Writer) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	version := time.Now().Format("20060102150405")
+	name = strings.ReplaceAll(strings.TrimSpace(name), " ", "_")
+	for _, direction := range []string{"up", "down"} {
+		file := filepath.Join(dir, fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+		if err := os.WriteFile(file, []byte("-- "+direction+" migration\n"), 0644); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "created", file)
+	}
+	return nil
+}
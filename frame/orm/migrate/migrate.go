@@ -0,0 +1,402 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"frame/orm"
+	"hash/crc32"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DefaultTable 是记录已执行迁移的默认表名。
+const DefaultTable = "schema_migrations"
+
+// ErrChecksumMismatch 表示已执行的迁移文件在执行后被修改过。
+var ErrChecksumMismatch = errors.New("migration checksum mismatch")
+
+// MigrateFunc 是使用 Go 代码编写的迁移函数，在事务中执行。
+type MigrateFunc func(tx *orm.FrameSession) error
+
+// Migration 表示一个版本化的迁移，可以使用 SQL 或 Go 函数编写。
+type Migration struct {
+	// Version 是迁移的版本号，按字符串顺序执行，推荐使用 0001 或时间戳 20240101120000 的形式。
+	Version string
+	// Name 是迁移的描述名称。
+	Name string
+	// UpSQL 和 DownSQL 是升级和回退时执行的 SQL，可以包含多条以分号分隔的语句。
+	UpSQL   string
+	DownSQL string
+	// Up 和 Down 是升级和回退时执行的 Go 函数，与 SQL 同时存在时先执行 SQL。
+	Up   MigrateFunc
+	Down MigrateFunc
+}
+
+// Checksum 返回迁移内容的校验和，用于检测已执行的迁移是否被修改。
+// 只有 SQL 迁移参与校验，Go 函数迁移的校验和为空。
+func (m *Migration) Checksum() string {
+	if m.UpSQL == "" && m.DownSQL == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(m.UpSQL + "\n--down--\n" + m.DownSQL))
+	return hex.EncodeToString(sum[:])
+}
+
+// record 是迁移记录表中的一行。
+type record struct {
+	Version   string    `gorm:"version,primary_key,size:64"`
+	Name      string    `gorm:"name,size:255"`
+	Checksum  string    `gorm:"checksum,size:64"`
+	AppliedAt time.Time `gorm:"applied_at"`
+}
+
+// Status 描述了一个迁移的执行状态。
+type Status struct {
+	Version   string
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// Dirty 表示迁移已执行但内容的校验和与记录不一致。
+	Dirty bool
+}
+
+// Migrator 负责按版本顺序执行迁移，并在迁移记录表中记录执行结果。
+type Migrator struct {
+	db *orm.FrameDb
+	// Table 是迁移记录表的表名，默认为 schema_migrations。
+	Table string
+	// LockTimeout 是等待其它迁移进程释放锁的最长时间，默认为 1 分钟。
+	LockTimeout time.Duration
+	migrations  map[string]*Migration
+}
+
+// New 创建一个迁移执行器。
+func New(db *orm.FrameDb) *Migrator {
+	return &Migrator{
+		db:          db,
+		Table:       DefaultTable,
+		LockTimeout: time.Minute,
+		migrations:  make(map[string]*Migration),
+	}
+}
+
+// Register 注册一个使用 Go 函数编写的迁移。
+func (m *Migrator) Register(version, name string, up, down MigrateFunc) {
+	mig := m.add(version, name)
+	mig.Up = up
+	mig.Down = down
+}
+
+// add 获取或创建指定版本的迁移。
+func (m *Migrator) add(version, name string) *Migration {
+	mig, ok := m.migrations[version]
+	if !ok {
+		mig = &Migration{Version: version, Name: name}
+		m.migrations[version] = mig
+	}
+	if mig.Name == "" {
+		mig.Name = name
+	}
+	return mig
+}
+
+// LoadDir 从目录中加载 SQL 迁移文件，参见 LoadFS。
+func (m *Migrator) LoadDir(dir string) error {
+	return m.LoadFS(os.DirFS(dir), ".")
+}
+
+// LoadFS 从文件系统的 dir 目录中加载 SQL 迁移文件。
+// 文件名格式为 版本号_名称.up.sql 和 版本号_名称.down.sql，例如 0001_create_user.up.sql。
+func (m *Migrator) LoadFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		base := strings.TrimSuffix(entry.Name(), ".sql")
+		direction := path.Ext(base)
+		if direction != ".up" && direction != ".down" {
+			return fmt.Errorf("migration file %s must end with .up.sql or .down.sql", entry.Name())
+		}
+		version, name, _ := strings.Cut(strings.TrimSuffix(base, direction), "_")
+		if version == "" {
+			return fmt.Errorf("migration file %s has no version", entry.Name())
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		mig := m.add(version, name)
+		if direction == ".up" {
+			mig.UpSQL = string(content)
+		} else {
+			mig.DownSQL = string(content)
+		}
+	}
+	return nil
+}
+
+// Migrations 返回按版本号排序的全部迁移。
+func (m *Migrator) Migrations() []*Migration {
+	list := make([]*Migration, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		list = append(list, mig)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+	return list
+}
+
+// Up 按版本顺序执行所有未执行的迁移。
+// 已执行的 SQL 迁移内容被修改时返回 ErrChecksumMismatch，不会执行任何迁移。
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func() error {
+		applied, err := m.applied()
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.Migrations() {
+			if rec, ok := applied[mig.Version]; ok && rec.Checksum != "" && rec.Checksum != mig.Checksum() {
+				return fmt.Errorf("%w: %s_%s", ErrChecksumMismatch, mig.Version, mig.Name)
+			}
+		}
+		for _, mig := range m.Migrations() {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			if err := m.run(ctx, mig, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down 按版本倒序回退最近执行的 steps 个迁移。
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func() error {
+		applied, err := m.applied()
+		if err != nil {
+			return err
+		}
+		list := m.Migrations()
+		for i := len(list) - 1; i >= 0 && steps > 0; i-- {
+			if _, ok := applied[list[i].Version]; !ok {
+				continue
+			}
+			if err := m.run(ctx, list[i], false); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// Status 返回所有迁移的执行状态。
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.Migrations() {
+		st := Status{Version: mig.Version, Name: mig.Name}
+		if rec, ok := applied[mig.Version]; ok {
+			st.Applied = true
+			st.AppliedAt = rec.AppliedAt
+			st.Dirty = rec.Checksum != "" && rec.Checksum != mig.Checksum()
+		}
+		list = append(list, st)
+	}
+	return list, nil
+}
+
+// run 在事务中执行一个迁移并更新迁移记录。
+// 注意：MySQL 中的 DDL 语句会隐式提交事务，迁移失败时已执行的 DDL 无法回滚。
+func (m *Migrator) run(ctx context.Context, mig *Migration, up bool) error {
+	query, fn := mig.UpSQL, mig.Up
+	if !up {
+		query, fn = mig.DownSQL, mig.Down
+		if query == "" && fn == nil {
+			return fmt.Errorf("migration %s_%s has no down migration", mig.Version, mig.Name)
+		}
+	}
+	err := m.db.Transaction(ctx, func(tx *orm.FrameSession) error {
+		for _, statement := range SplitStatements(query) {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return err
+			}
+		}
+		if fn != nil {
+			if err := fn(tx); err != nil {
+				return err
+			}
+		}
+		if !up {
+			_, err := tx.New(nil).Table(m.Table).Where("version", mig.Version).Delete()
+			return err
+		}
+		_, _, err := tx.New(nil).Table(m.Table).Insert(&record{
+			Version:   mig.Version,
+			Name:      mig.Name,
+			Checksum:  mig.Checksum(),
+			AppliedAt: time.Now(),
+		})
+		return err
+	})
+	if err != nil {
+		direction := "up"
+		if !up {
+			direction = "down"
+		}
+		return fmt.Errorf("migration %s_%s %s: %w", mig.Version, mig.Name, direction, err)
+	}
+	return nil
+}
+
+// applied 返回已执行的迁移记录，迁移记录表不存在时会自动创建。
+func (m *Migrator) applied() (map[string]*record, error) {
+	if err := m.db.New(&record{}).Table(m.Table).AutoMigrate(&record{}); err != nil {
+		return nil, err
+	}
+	rows, err := m.db.New(&record{}).Table(m.Table).Select(&record{})
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[string]*record, len(rows))
+	for _, row := range rows {
+		rec := row.(*record)
+		applied[rec.Version] = rec
+	}
+	return applied, nil
+}
+
+// withLock 在持有迁移锁期间执行 fn，防止多个进程同时执行迁移。
+// MySQL 使用 GET_LOCK，PostgreSQL 使用 pg_advisory_lock，它们都是连接级别的锁，
+// 因此需要在同一个独占连接上加锁和解锁。其它数据库不加锁。
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	dialect := m.db.Dialect().Name()
+	if dialect != "mysql" && dialect != "postgres" {
+		return fn()
+	}
+
+	conn, err := m.db.DB().Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	lockCtx, cancel := context.WithTimeout(ctx, m.LockTimeout)
+	defer cancel()
+	name := "migrate:" + m.Table
+	if dialect == "mysql" {
+		// GET_LOCK 超时返回 0，成功返回 1
+		var locked sql.NullInt64
+		err := conn.QueryRowContext(lockCtx, "SELECT GET_LOCK(?, ?)", name, int(m.LockTimeout.Seconds())).Scan(&locked)
+		if err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		if locked.Int64 != 1 {
+			return errors.New("acquire migration lock: timeout, another migration is running")
+		}
+		defer conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", name)
+		return fn()
+	}
+
+	// pg_advisory_lock 会一直阻塞到获得锁，超时由上下文控制
+	key := int64(crc32.ChecksumIEEE([]byte(name)))
+	if _, err := conn.ExecContext(lockCtx, "SELECT pg_advisory_lock($1)", key); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+	return fn()
+}
+
+// SplitStatements 将包含多条语句的 SQL 按分号拆分为单独的语句。
+// 引号、/* */ 注释以及 PostgreSQL 的 $$ 或 $tag$ 引用（例如函数体）中的分号不会拆分语句，
+// -- 开头的注释会被去掉，只有注释的语句会被忽略。
+func SplitStatements(query string) []string {
+	statements := make([]string, 0)
+	var sb strings.Builder
+	// code 表示当前语句中是否有注释以外的内容
+	code := false
+	flush := func() {
+		if stmt := strings.TrimSpace(sb.String()); stmt != "" && code {
+			statements = append(statements, stmt)
+		}
+		sb.Reset()
+		code = false
+	}
+	// copyUntil 原样复制从 i 开始、到 start 之后第一个 end 结束的内容，没有结束标记时复制到末尾，返回最后一个字节的位置
+	copyUntil := func(i, start int, end string) int {
+		n := strings.Index(query[start:], end)
+		if n < 0 {
+			sb.WriteString(query[i:])
+			return len(query) - 1
+		}
+		last := start + n + len(end)
+		sb.WriteString(query[i:last])
+		return last - 1
+	}
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			// 两个连续的引号表示引号本身，相当于一个引用结束后立即开始下一个
+			i = copyUntil(i, i+1, string(c))
+			code = true
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			// 跳过注释直到行尾
+			for i+1 < len(query) && query[i+1] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			// 原样保留块注释，例如 MySQL 的 /*!50001 ... */
+			i = copyUntil(i, i+2, "*/")
+		case c == '$' && dollarTag(query[i:]) != "":
+			tag := dollarTag(query[i:])
+			i = copyUntil(i, i+len(tag), tag)
+			code = true
+		case c == ';':
+			flush()
+		default:
+			sb.WriteByte(c)
+			if !unicode.IsSpace(rune(c)) {
+				code = true
+			}
+		}
+	}
+	flush()
+	return statements
+}
+
+// dollarTag 返回 s 开头的 PostgreSQL 美元引用标记（$$ 或 $tag$），不是美元引用时返回空字符串。
+// $1 这样的参数占位符不是美元引用。
+func dollarTag(s string) string {
+	for j := 1; j < len(s); j++ {
+		c := s[j]
+		switch {
+		case c == '$':
+			return s[:j+1]
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80:
+		case c >= '0' && c <= '9' && j > 1:
+		default:
+			return ""
+		}
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package migrate
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "simple",
+			query: "create table a (id int);\ninsert into a values (1);",
+			want:  []string{"create table a (id int)", "insert into a values (1)"},
+		},
+		{
+			name:  "quotes",
+			query: `insert into a values ('x;y', "a;b", ` + "`c;d`" + `, 'it''s;');select 1`,
+			want:  []string{`insert into a values ('x;y', "a;b", ` + "`c;d`" + `, 'it''s;')`, "select 1"},
+		},
+		{
+			name:  "line comments",
+			query: "-- first; not a statement\nselect 1; -- trailing;\n-- only a comment;",
+			want:  []string{"select 1"},
+		},
+		{
+			name:  "block comments",
+			query: "/* header; with semicolons */\nselect 1 /* a;b */ + 2;\n/* only a comment; */;",
+			want:  []string{"/* header; with semicolons */\nselect 1 /* a;b */ + 2"},
+		},
+		{
+			name: "dollar quoted function body",
+			query: `CREATE FUNCTION touch() RETURNS trigger AS $$
+BEGIN
+  NEW.updated_at = now();
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+CREATE FUNCTION f() RETURNS text AS $body$ select 'a;b'; $body$ LANGUAGE sql;
+SELECT $1;`,
+			want: []string{
+				"CREATE FUNCTION touch() RETURNS trigger AS $$\nBEGIN\n  NEW.updated_at = now();\n  RETURN NEW;\nEND;\n$$ LANGUAGE plpgsql",
+				"CREATE FUNCTION f() RETURNS text AS $body$ select 'a;b'; $body$ LANGUAGE sql",
+				"SELECT $1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SplitStatements(tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandCreateWithoutDatabase(t *testing.T) {
+	dir := t.TempDir()
+	var out bytes.Buffer
+	open := func() (*Migrator, error) {
+		t.Fatal("create should not open the database")
+		return nil, nil
+	}
+	if err := Command(open, dir, []string{"create", "add email"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*_add_email.*.sql"))
+	if len(files) != 2 {
+		t.Fatalf("unexpected files %v\n%s", files, out.String())
+	}
+	if _, err := os.Stat(files[0]); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,63 @@
+//go:build cgo
+
+package migrate
+
+import (
+	"context"
+	"frame/orm"
+	"frame/orm/ormtest"
+	"strings"
+	"testing"
+)
+
+func TestMigratorUpDownStatus(t *testing.T) {
+	db := ormtest.Open(t)
+	ctx := context.Background()
+	m := New(db.FrameDb)
+	m.migrations["0001"] = &Migration{
+		Version: "0001",
+		Name:    "create_user",
+		UpSQL:   "CREATE TABLE app_user (id INTEGER PRIMARY KEY, name TEXT); -- seed data below\nINSERT INTO app_user (name) VALUES ('admin');",
+		DownSQL: "DROP TABLE app_user;",
+	}
+	m.Register("0002", "rename_admin", func(tx *orm.FrameSession) error {
+		_, err := tx.ExecContext(ctx, "UPDATE app_user SET name = ? WHERE name = ?", "root", "admin")
+		return err
+	}, func(tx *orm.FrameSession) error {
+		_, err := tx.ExecContext(ctx, "UPDATE app_user SET name = ? WHERE name = ?", "admin", "root")
+		return err
+	})
+
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if rows := db.Rows("app_user"); len(rows) != 1 || rows[0]["name"] != "root" {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+	list, err := m.Status(ctx)
+	if err != nil || len(list) != 2 || !list[0].Applied || !list[1].Applied {
+		t.Fatalf("unexpected status %+v %v", list, err)
+	}
+
+	if err := m.Down(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if rows := db.Rows("app_user"); rows[0]["name"] != "admin" {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+	if err := m.Down(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if db.Rows("app_user") != nil {
+		t.Fatal("table should be dropped")
+	}
+
+	// 已执行的迁移被修改时拒绝执行
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	m.migrations["0001"].UpSQL += " "
+	if err := m.Up(ctx); err == nil || !strings.Contains(err.Error(), ErrChecksumMismatch.Error()) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+}
@@ -1,12 +1,15 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	newLog "frame/log"
 	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -21,6 +24,25 @@ type FrameDb struct {
 
 	// Prefix 是表名的前缀，用于在查询中动态指定表名。
 	Prefix string
+
+	// dialect 是数据库对应的 SQL 方言，根据驱动名称自动选择。
+	dialect Dialect
+
+	// driverName 是数据库驱动名称，打开副本时使用。
+	driverName string
+
+	// replicas 是只读副本以及负载均衡策略，只读查询会路由到副本。
+	replicas replicaSet
+
+	// queryLog 是 SQL 日志的配置，queryLogMu 用于串行地修改配置。
+	queryLog   atomic.Pointer[queryLog]
+	queryLogMu sync.Mutex
+
+	// cache 是查询缓存。
+	cache queryCache
+
+	// shards 是表的分片规则。
+	shards shardRules
 }
 
 // FrameSession 是一个数据库会话结构体，用于执行数据库操作。
@@ -32,6 +54,8 @@ type FrameSession struct {
 	tx *sql.Tx
 	// beginTx 表示是否已经开始了一个事务。
 	beginTx bool
+	// txState 保存了同一个事务中所有会话共享的状态（保存点、提交后回调）。
+	txState *txState
 	// tableName 是当前操作的数据库表名。
 	tableName string
 	// fieldName 存储了当前操作涉及的字段名列表。
@@ -46,11 +70,70 @@ type FrameSession struct {
 	whereParam strings.Builder
 	// whereValues 是WHERE子句中的值，用于匹配条件。
 	whereValues []any
+	// whereConn 是下一个条件与前一个条件之间的连接符，由 And/Or 设置，默认为 and。
+	whereConn string
+	// whereWrap 表示WHERE子句中唯一的条件需要在追加下一个条件时使用括号包裹。
+	whereWrap bool
+	// groupParam 用于构建GROUP BY子句。
+	groupParam strings.Builder
+	// orderParam 用于构建ORDER BY子句。
+	orderParam strings.Builder
+	// havingParam 用于构建HAVING子句，havingWrap 的含义与 whereWrap 相同。
+	havingParam strings.Builder
+	havingWrap  bool
+	// havingValues 是HAVING子句中的值。
+	havingValues []any
+	// limit 是查询返回的最大行数，0 表示不限制。
+	limit int
+	// offset 是查询跳过的行数。
+	offset int
+	// cursor 是通过 After 设置的键集分页游标。
+	cursor string
+	// alias 是当前表的别名。
+	alias string
+	// fromValues 是FROM子查询中的值。
+	fromValues []any
+	// joinParam 用于构建JOIN子句。
+	joinParam strings.Builder
+	// joinValues 是JOIN子句中的值。
+	joinValues []any
+	// model 是创建会话时传入的模型，Update 和 Delete 的生命周期钩子在它上面调用。
+	model any
+	// updateFields 记录了SET子句中已经设置的列，用于判断是否需要自动设置更新时间。
+	updateFields []string
+	// trashed 是软删除的查询范围，由 Unscoped、WithTrashed、OnlyTrashed 设置。
+	trashed int
+	// conflict 是插入时的冲突处理方式，由 OnConflict、DoUpdate、DoNothing 设置。
+	conflict *Conflict
+	// batchSize 是批量插入和更新时每批的最大行数，0 表示按占位符数量限制自动计算。
+	batchSize int
+	// chunkTx 表示批量操作时每一批是否在单独的事务中执行。
+	chunkTx bool
+	// progress 是批量操作每批完成后调用的进度回调。
+	progress func(done, total int)
+	// preloads 是查询时需要预加载的关联字段，由 Preload 设置。
+	preloads []string
+	// usePrimary 表示只读查询也使用主库，由 UsePrimary 设置。
+	usePrimary bool
+	// dryRun 保存了试运行模式下生成的 SQL，为 nil 时正常执行，由 DryRun 设置。
+	dryRun *dryRun
+	// cache 表示查询使用查询缓存，cacheTTL 是结果的有效时间，由 Cache 设置。
+	cache    bool
+	cacheTTL time.Duration
+	// shardKey 是由 ShardKey 指定的分片键的值，hasShardKey 表示是否指定。
+	shardKey    any
+	hasShardKey bool
+	// fanOut 表示没有分片键时在所有分片上执行，由 FanOut 设置。
+	fanOut bool
+	// whereEq 是WHERE子句中的等值条件，whereOr 表示条件中使用了 or，用于从条件中获取分片键。
+	whereEq []*compareCond
+	whereOr bool
 }
 
 // Open 是一个用于初始化 FrameDb 数据库连接的方法。
 // 它接受数据库驱动名称和数据源作为参数，并返回一个 FrameDb 实例。
 // 该方法配置了数据库连接池的各项参数，确保数据库连接的高效和稳定。
+// 需要从配置文件读取连接池参数时使用 LoadDbConfig 和 OpenWithConfig。
 func Open(driverName string, source string) *FrameDb {
 	// 打开数据库连接
 	db, err := sql.Open(driverName, source)
@@ -58,20 +141,24 @@ func Open(driverName string, source string) *FrameDb {
 		panic(err)
 	}
 	// 设置最大空闲连接数
-	db.SetMaxIdleConns(5)
+	db.SetMaxIdleConns(defaultMaxIdleConns)
 	// 设置最大连接数
-	db.SetMaxOpenConns(100)
+	db.SetMaxOpenConns(defaultMaxOpenConns)
 	// 设置连接最大存活时间
-	db.SetConnMaxLifetime(time.Minute * 3)
+	db.SetConnMaxLifetime(defaultConnMaxLifetime)
 	// 设置空闲连接最大存活时间
-	db.SetConnMaxIdleTime(time.Minute * 1)
+	db.SetConnMaxIdleTime(defaultConnMaxIdleTime)
 
 	// 创建 FrameDb 实例
 	frameDb := &FrameDb{
 		db: db,
 		// logger 用于记录数据库操作的日志
 		logger: newLog.Default(),
+		// dialect 根据驱动名称选择对应的 SQL 方言
+		dialect:    dialectFor(driverName),
+		driverName: driverName,
 	}
+	frameDb.queryLog.Store(&queryLog{level: LogInfo})
 	// 测试连接
 	err = db.Ping()
 	if err != nil {
@@ -81,8 +168,12 @@ func Open(driverName string, source string) *FrameDb {
 	return frameDb
 }
 
-// Close 关闭数据库连接
+// Close 关闭数据库连接，同时停止健康检查并关闭所有副本。
 func (db *FrameDb) Close() error {
+	if err := db.closeReplicas(); err != nil {
+		db.db.Close()
+		return err
+	}
 	return db.db.Close()
 }
 
@@ -91,11 +182,27 @@ func (db *FrameDb) SetMaxIdleConns(n int) {
 	db.db.SetMaxIdleConns(n)
 }
 
+// DB 返回底层的 *sql.DB，用于需要独占连接等框架未封装的操作。
+func (db *FrameDb) DB() *sql.DB {
+	return db.db
+}
+
+// Dialect 返回当前数据库使用的 SQL 方言。
+func (db *FrameDb) Dialect() Dialect {
+	return db.dialect
+}
+
+// SetDialect 设置当前数据库使用的 SQL 方言，用于驱动名称无法识别的场景。
+func (db *FrameDb) SetDialect(d Dialect) {
+	db.dialect = d
+}
+
 // New 创建一个新的 FrameSession 实例，用于执行数据库操作。
 func (db *FrameDb) New(data any) *FrameSession {
 	// 创建 FrameSession 实例并将其 db 字段设置为当前 FrameDb 实例。
 	m := &FrameSession{
-		db: db,
+		db:    db,
+		model: data,
 	}
 
 	// 获取 data 参数的类型。
@@ -128,62 +235,61 @@ func (s *FrameSession) Table(name string) *FrameSession {
 
 // TODO 重要部分，解析相关的插入数据
 // fieldNames 提取数据结构中的字段名和对应值，准备用于SQL查询。
-// 该方法主要作用是通过反射机制遍历给定数据结构的字段，根据字段标签确定SQL查询中的字段名和占位符，并将字段值存储起来。
+// 该方法主要作用是通过解析后的表结构遍历给定数据结构的字段，根据字段标签确定SQL查询中的字段名和占位符，并将字段值存储起来。
 // 参数 data 是一个指向任意类型的指针，该类型将被反射以提取字段信息。
-func (s *FrameSession) fieldNames(data any) {
-	// 使用反射获取数据的类型和值
-	t := reflect.TypeOf(data)
-	v := reflect.ValueOf(data)
-
+func (s *FrameSession) fieldNames(data any) error {
 	// 确保 data 参数是一个指针类型，以防止反射操作出错
+	t := reflect.TypeOf(data)
 	if t.Kind() != reflect.Pointer {
-		panic(errors.New("data must be pointer"))
+		return errors.New("data must be pointer")
 	}
-
-	// 获取指针所指向的类型的元素类型和值
-	tVar := t.Elem()
-	vVar := v.Elem()
+	// 解析数据结构对应的表结构
+	sc, err := parseSchema(t)
+	if err != nil {
+		return err
+	}
+	vVar := reflect.ValueOf(data).Elem()
+	setCreateTime(sc, vVar, time.Now())
 
 	// 如果表名尚未设置，则根据数据结构的名称生成一个默认表名
 	if s.tableName == "" {
-		// 根据数据结构的名称生成表名
-		s.tableName = s.db.Prefix + strings.ToLower(Name(tVar.Name()))
+		s.tableName = s.db.Prefix + strings.ToLower(Name(sc.Type.Name()))
 	}
 
 	// 遍历数据结构的每个字段
-	for i := 0; i < tVar.NumField(); i++ {
-		// 获取字段的名称和标签
-		fieldName := tVar.Field(i).Name
-		tag := tVar.Field(i).Tag
-
-		// 从字段标签中提取gorm标签的值，用于SQL查询
-		sqlTag := tag.Get("gorm")
-		if sqlTag == "" {
-			sqlTag = strings.ToLower(Name(fieldName))
-		} else {
-			// 如果字段标记包含“auto_increment”，则跳过该字段，因为它通常是自增长的主键
-			if strings.Contains(sqlTag, "auto_increment") {
-				continue
-			}
-			// 如果sqlTag包含逗号，取逗号前的部分作为字段名
-			if strings.Contains(sqlTag, ",") {
-				sqlTag = sqlTag[:strings.Index(sqlTag, ",")]
-			}
+	for _, f := range insertFields(sc, vVar) {
+		value, err := fieldValue(f, vVar.Field(f.Index))
+		if err != nil {
+			return err
 		}
+		// 将处理后的字段名和对应的值添加到session的相应切片中
+		s.fieldName = append(s.fieldName, f.Column)
+		s.placeHolder = append(s.placeHolder, "?")
+		s.values = append(s.values, value)
+	}
+	return nil
+}
 
-		// 获取字段的值并进行类型断言
-		id := vVar.Field(i).Interface()
-
-		// 如果sqlTag是"id"且字段值是自增长的主键，则跳过
-		if strings.ToLower(sqlTag) == "id" && IsAutoId(id) {
+// insertFields 返回插入数据时需要写入的字段。
+// 自增字段以及值为零的 id 字段由数据库生成，不参与插入，值为零的时间类型软删除字段也不参与插入。
+func insertFields(sc *schema, v reflect.Value) []*field {
+	fields := make([]*field, 0, len(sc.Fields))
+	for _, f := range sc.Fields {
+		// 如果字段标记包含“auto_increment”，则跳过该字段，因为它通常是自增长的主键
+		if f.AutoIncrement {
 			continue
 		}
-
-		// 将处理后的字段名和对应的值添加到session的相应切片中
-		s.fieldName = append(s.fieldName, sqlTag)
-		s.placeHolder = append(s.placeHolder, "?")
-		s.values = append(s.values, vVar.Field(i).Interface())
+		// 如果列名是"id"且字段值是自增长的主键，则跳过
+		if strings.ToLower(f.Column) == "id" && IsAutoId(v.Field(f.Index).Interface()) {
+			continue
+		}
+		// 未删除的时间类型软删除字段保持数据库中的 NULL
+		if f == sc.DeletedAt && !isIntField(f) && v.Field(f.Index).IsZero() {
+			continue
+		}
+		fields = append(fields, f)
 	}
+	return fields
 }
 
 // TODO 重要部分，解析相关的插入数据 根据字段的 tag 决定是否将值添加到 s.values 中。
@@ -191,121 +297,175 @@ func (s *FrameSession) fieldNames(data any) {
 // 它接受一个 any 类型的切片 data，该切片包含了多个结构体对象。
 // 函数会遍历每个结构体对象，提取其字段值，并根据字段的 tag 决定是否将值添加到 s.values 中。
 // s.values 是一个用于存储所有数据的切片，这些数据将用于数据库的批量插入操作。
-func (s *FrameSession) batchValues(data []any) {
+func (s *FrameSession) batchValues(data []any) error {
 	// 初始化 s.values 为一个新的空切片，用于存储处理后的字段值。
 	s.values = make([]any, 0)
 
 	// 遍历 data 切片中的每个元素。
+	now := time.Now()
 	for _, v := range data {
-		// 获取当前元素的类型和值。
+		// 检查元素是否为指针类型，如果不是，则返回错误。
 		t := reflect.TypeOf(v)
-		v := reflect.ValueOf(v)
-
-		// 检查元素是否为指针类型，如果不是，则抛出错误。
 		if t.Kind() != reflect.Pointer {
-			panic(errors.New("data must be pointer"))
+			return errors.New("data must be pointer")
 		}
+		sc, err := parseSchema(t)
+		if err != nil {
+			return err
+		}
+		vVar := reflect.ValueOf(v).Elem()
+		setCreateTime(sc, vVar, now)
 
-		// 获取指针所指向的类型的变量和值。
-		tVar := t.Elem()
-		vVar := v.Elem()
-
-		// 遍历类型的每个字段。
-		for i := 0; i < tVar.NumField(); i++ {
-			// 获取当前字段的名称和 tag。
-			fieldName := tVar.Field(i).Name
-			tag := tVar.Field(i).Tag
-			sqlTag := tag.Get("gorm")
-
-			// 如果 gorm tag 为空，则使用字段名称的小写形式作为默认值。
-			if sqlTag == "" {
-				sqlTag = strings.ToLower(Name(fieldName))
-			} else {
-				// 如果 tag 中包含 "auto_increment"，表示该字段是自增长的主键，跳过该字段。
-				if strings.Contains(sqlTag, "auto_increment") {
-					continue
-				}
-			}
-
-			// 获取字段的值并进行类型断言。
-			id := vVar.Field(i).Interface()
-
-			// 如果字段的 tag 是 "id" 且值是自动生成的 ID，则跳过该字段。
-			if strings.ToLower(sqlTag) == "id" && IsAutoId(id) {
-				continue
+		// 遍历需要插入的字段，将字段的值添加到 s.values 中。
+		for _, f := range insertFields(sc, vVar) {
+			value, err := fieldValue(f, vVar.Field(f.Index))
+			if err != nil {
+				return err
 			}
-
-			// 将字段的值添加到 s.values 中。
-			s.values = append(s.values, vVar.Field(i).Interface())
+			s.values = append(s.values, value)
 		}
 	}
+	return nil
 }
 
 // Insert 方法用于向数据库中插入一条记录。
 // 参数 data 代表要插入的数据，其类型为任意类型。
 // 返回值为插入记录的自增ID、受影响的行数以及可能的错误。
+// data 实现了 BeforeInsertHook、AfterInsertHook 时会在插入前后调用，
+// 值为零的 CreatedAt、UpdatedAt 字段会在 BeforeInsert 之后被设置为当前时间。
+// 插入成功后自增ID会写回值为零的整数主键，关联字段中的新记录（主键为零值）会在同一个事务中级联插入。
+func (s *FrameSession) Insert(data any) (int64, int64, error) {
+	var id, affected int64
+	err := s.onShards(data, func() error {
+		return s.withHookTx(hasHook(data, hookAfterInsert) || hasAssociations(data), func() error {
+			if err := s.callHook(data, hookBeforeInsert); err != nil {
+				return err
+			}
+			// 先插入所属的记录，得到外键的值
+			if err := s.saveBelongsTo(data); err != nil {
+				return err
+			}
+			var err error
+			if id, affected, err = s.insert(data); err != nil {
+				return err
+			}
+			setPrimaryKey(data, id)
+			if err := s.saveAssociations(data); err != nil {
+				return err
+			}
+			return s.callHook(data, hookAfterInsert)
+		})
+	})
+	if err != nil {
+		return -1, -1, err
+	}
+	return id, affected, nil
+}
+
+// insert 构建插入SQL语句并执行。
 // 该方法首先构建插入SQL语句，然后根据是否在事务中选择不同的数据库连接进行预编译。
 // 预编译成功后执行SQL语句，并获取执行结果。
 // 最后，从执行结果中获取最后插入记录的自增ID和受影响的行数，并返回这些值。
-func (s *FrameSession) Insert(data any) (int64, int64, error) {
+func (s *FrameSession) insert(data any) (int64, int64, error) {
 	// 构建插入SQL语句的字段名部分。（解析相关的插入数据）
-	s.fieldNames(data)
-	// 构建完整的插入SQL语句。
-	query := fmt.Sprintf("insert into %s (%s) values (%s)", s.tableName, strings.Join(s.fieldName, ","), strings.Join(s.placeHolder, ","))
-	// 记录SQL语句日志。
-	s.db.logger.Info(query)
-
-	// 声明stmt变量用于存储预编译的SQL语句。
-	var stmt *sql.Stmt
-	// 声明err变量用于存储错误信息。
-	var err error
-
-	// 根据是否在事务中选择不同的数据库连接进行预编译。
-	if s.beginTx {
-		stmt, err = s.tx.Prepare(query)
-	} else {
-		stmt, err = s.db.db.Prepare(query)
-	}
-	// 如果预编译失败，返回错误。
-	if err != nil {
+	if err := s.fieldNames(data); err != nil {
 		return -1, -1, err
 	}
+	// 构建完整的插入SQL语句，追加冲突处理和 RETURNING 子句。
+	suffix, returning := s.insertSuffix(data)
+	query := fmt.Sprintf("insert into %s (%s) values (%s)%s", s.tableName, strings.Join(s.fieldName, ","), strings.Join(s.placeHolder, ","), suffix)
 
-	// 执行预编译的SQL语句。
-	r, err := stmt.Exec(s.values...)
-	// 如果执行失败，返回错误。
+	// 执行SQL语句，返回插入记录的ID和受影响的行数。
+	return s.execInsert(query, returning, false)
+}
+
+// InsertBatch 批量插入数据到数据库中。
+// 该方法根据提供的数据数组生成批量插入查询，并执行该查询。
+// 数据按 BatchSize 设置的行数（默认按占位符数量限制计算）分批插入，每批一条语句，
+// 可以通过 ChunkTx 让每批在单独的事务中执行，通过 OnProgress 获取每批完成后的进度。
+// 每个元素的插入钩子和创建时间的处理与 Insert 相同。
+// 返回第一批插入的ID和所有批次受影响的行数。
+func (s *FrameSession) InsertBatch(data []any) (int64, int64, error) {
+	// 当数据为空时，返回错误。
+	if len(data) == 0 {
+		return -1, -1, errors.New("no data insert")
+	}
+	// 分片表按分片分组后分别插入，返回第一组的 ID
+	var id, affected int64
+	first := true
+	err := s.onShardGroups(data, func(group []any) error {
+		groupId, n, err := s.insertChunks(group)
+		if err != nil {
+			return err
+		}
+		if first {
+			id, first = groupId, false
+		}
+		affected += n
+		return nil
+	})
 	if err != nil {
 		return -1, -1, err
 	}
+	return id, affected, nil
+}
 
-	// 获取最后插入记录的自增ID。
-	id, err := r.LastInsertId()
-	// 如果获取失败，返回错误。
+// insertChunks 按占位符数量的限制分批插入数据，返回第一批的 ID 以及受影响的总行数。
+func (s *FrameSession) insertChunks(data []any) (int64, int64, error) {
+	// 通过第一个数据的表结构计算每行最多使用的占位符数量，确定每批的行数。
+	// 这里不能调用 fieldNames，它会在 BeforeInsert 之前设置创建时间；BeforeInsert 可能填充主键，因此按所有列计算。
+	t := reflect.TypeOf(data[0])
+	if t.Kind() != reflect.Pointer {
+		return -1, -1, errors.New("data must be pointer")
+	}
+	sc, err := parseSchema(t)
 	if err != nil {
 		return -1, -1, err
 	}
+	size := s.chunkSize(len(sc.Fields), 0)
 
-	// 获取受影响的行数。
-	affected, err := r.RowsAffected()
-	// 如果获取失败，返回错误。
+	var id, affected int64
+	err = s.eachChunk(len(data), size, func(lo, hi int) error {
+		chunk := data[lo:hi]
+		needTx := s.chunkTx
+		for _, v := range chunk {
+			needTx = needTx || hasHook(v, hookAfterInsert)
+		}
+		return s.withHookTx(needTx, func() error {
+			for _, v := range chunk {
+				if err := s.callHook(v, hookBeforeInsert); err != nil {
+					return err
+				}
+			}
+			s.resetValues()
+			chunkId, n, err := s.insertBatch(chunk)
+			if err != nil {
+				return err
+			}
+			if lo == 0 {
+				id = chunkId
+			}
+			affected += n
+			for _, v := range chunk {
+				if err := s.callHook(v, hookAfterInsert); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
 	if err != nil {
 		return -1, -1, err
 	}
-
-	// 返回插入记录的自增ID、受影响的行数以及nil错误。
 	return id, affected, nil
 }
 
-// InsertBatch 批量插入数据到数据库中。
-// 该方法根据提供的数据数组生成一个批量插入查询，并执行该查询。
-func (s *FrameSession) InsertBatch(data []any) (int64, int64, error) {
-	// 当数据为空时，返回错误。
-	if len(data) == 0 {
-		return -1, -1, errors.New("no data insert")
-	}
-
+// insertBatch 构建批量插入SQL语句并执行。
+func (s *FrameSession) insertBatch(data []any) (int64, int64, error) {
 	// 准备插入查询的字段名。（通过第一个数据获取对应信息）
-	s.fieldNames(data[0])
+	if err := s.fieldNames(data[0]); err != nil {
+		return -1, -1, err
+	}
 
 	// 构建插入查询的初始部分，包括表名和字段名。
 	query := fmt.Sprintf("insert into %s (%s) values ", s.tableName, strings.Join(s.fieldName, ","))
@@ -321,61 +481,24 @@ func (s *FrameSession) InsertBatch(data []any) (int64, int64, error) {
 			sb.WriteString(",")
 		}
 	}
+	// 追加冲突处理和 RETURNING 子句。
+	suffix, returning := s.insertSuffix(data[0])
+	sb.WriteString(suffix)
 
 	// 将所有数据记录的值添加到batchValues中，以备后续执行查询。
-	s.batchValues(data)
-
-	// 记录日志信息。
-	s.db.logger.Info(sb.String())
-
-	// 准备SQL语句。
-	var stmt *sql.Stmt
-	var err error
-	if s.beginTx {
-		stmt, err = s.tx.Prepare(sb.String())
-	} else {
-		stmt, err = s.db.db.Prepare(sb.String())
-	}
-
-	// 如果准备SQL语句时发生错误，返回错误。
-	if err != nil {
-		return -1, -1, err
-	}
-
-	// 执行SQL语句。
-	r, err := stmt.Exec(s.values...)
-	if err != nil {
+	if err := s.batchValues(data); err != nil {
 		return -1, -1, err
 	}
 
-	// 获取最后插入行的ID。
-	id, err := r.LastInsertId()
-	if err != nil {
-		return -1, -1, err
-	}
-
-	// 获取受影响的行数。
-	affected, err := r.RowsAffected()
-	if err != nil {
-		return -1, -1, err
-	}
-
-	// 返回最后插入行的ID、受影响的行数和nil错误。
-	return id, affected, nil
+	// 执行SQL语句，返回插入行的ID和受影响的行数。
+	return s.execInsert(sb.String(), returning, true)
 }
 
 // UpdateParam 更新FrameSession对象中的参数。
 // 该方法用于动态构建SQL更新语句的SET部分，通过接受字段名称和对应的值来更新session的状态。
+// 值可以是 orm.Expr 构建的表达式，例如：UpdateParam("stock", orm.Expr("stock - ?", 1))。
 func (s *FrameSession) UpdateParam(field string, value any) *FrameSession {
-	// 检查是否已经有参数被添加，如果有，则添加逗号分隔。
-	if s.updateParam.String() != "" {
-		s.updateParam.WriteString(",")
-	}
-	// 将字段名称和对应的占位符添加到updateParam中，用于后续构建SQL语句。
-	s.updateParam.WriteString(field)
-	s.updateParam.WriteString(" = ? ")
-	// 将实际的值添加到values切片中，用于后续的SQL查询。
-	s.values = append(s.values, value)
+	s.setParam(field, value)
 	// 返回FrameSession对象，支持链式调用。
 	return s
 }
@@ -385,20 +508,29 @@ func (s *FrameSession) UpdateParam(field string, value any) *FrameSession {
 func (s *FrameSession) UpdateMap(data map[string]any) *FrameSession {
 	// 遍历data映射，构建SQL的SET子句需要的部分。
 	for k, v := range data {
-		// 如果已经有字段需要更新，添加逗号分隔。
-		if s.updateParam.String() != "" {
-			s.updateParam.WriteString(",")
-		}
-		// 将字段名和对应的占位符"?"添加到updateParam中。
-		s.updateParam.WriteString(k)
-		s.updateParam.WriteString(" = ? ")
-		// 将字段的值添加到values切片中，作为SQL语句的参数。
-		s.values = append(s.values, v)
+		s.setParam(k, v)
 	}
 	// 返回FrameSession对象，支持链式调用。
 	return s
 }
 
+// setParam 向SET子句追加一个 field = value 赋值。
+func (s *FrameSession) setParam(field string, value any) {
+	// 检查是否已经有参数被添加，如果有，则添加逗号分隔。
+	if s.updateParam.Len() > 0 {
+		s.updateParam.WriteString(",")
+	}
+	// 将字段名称和对应的占位符（或表达式）添加到updateParam中，用于后续构建SQL语句。
+	placeholder, values := bindValue(value)
+	s.updateFields = append(s.updateFields, field)
+	s.updateParam.WriteString(field)
+	s.updateParam.WriteString(" = ")
+	s.updateParam.WriteString(placeholder)
+	s.updateParam.WriteString(" ")
+	// 将实际的值添加到values切片中，用于后续的SQL查询。
+	s.values = append(s.values, values...)
+}
+
 // Update 更新数据库中的记录。
 // 该方法支持两种调用方式：
 // 1. 传递键值对（列名和新值），例如：Update("age", 1)
@@ -406,109 +538,94 @@ func (s *FrameSession) UpdateMap(data map[string]any) *FrameSession {
 // 参数说明：
 //   - data: 可变参数，用于指定更新的字段或结构体。如果传递两个参数，则第一个参数为列名，第二个参数为新值；
 //     如果传递一个参数，则该参数应为一个结构体指针。
+//
+// 更新钩子在传入的结构体上调用，没有传入结构体时在创建会话的模型上调用。
+// UpdatedAt 字段会被自动设置为当前时间。
+// 模型有 version 选项标记的版本号字段时使用乐观锁更新，版本号不一致时返回 ErrStaleObject。
 func (s *FrameSession) Update(data ...any) (int64, int64, error) {
 	// 检查参数数量是否合法。如果参数数量超过2个，则返回错误。
 	if len(data) > 2 {
 		return -1, -1, errors.New("param not valid")
 	}
-
-	// 如果没有传递任何参数，则执行无条件更新操作。
-	if len(data) == 0 {
-		// 构建更新SQL语句。
-		query := fmt.Sprintf("update %s set %s", s.tableName, s.updateParam.String())
-		var sb strings.Builder
-		sb.WriteString(query)
-		sb.WriteString(s.whereParam.String())
-		s.db.logger.Info(sb.String())
-
-		// 根据事务状态选择不同的数据库连接进行预编译。
-		var stmt *sql.Stmt
-		var err error
-		if s.beginTx {
-			stmt, err = s.tx.Prepare(sb.String())
-		} else {
-			stmt, err = s.db.db.Prepare(sb.String())
-		}
-		if err != nil {
-			return -1, -1, err
-		}
-
-		// 执行SQL语句并获取结果。
-		s.values = append(s.values, s.whereValues...)
-		r, err := stmt.Exec(s.values...)
-		if err != nil {
-			return -1, -1, err
-		}
-		id, err := r.LastInsertId()
-		if err != nil {
-			return -1, -1, err
-		}
-		affected, err := r.RowsAffected()
-		if err != nil {
-			return -1, -1, err
-		}
-		return id, affected, nil
+	model := s.model
+	if len(data) == 1 {
+		model = data[0]
 	}
-
-	// 判断是单个结构体还是键值对更新。
-	single := true
-	if len(data) == 2 {
-		single = false
+	var id, affected int64
+	var structData any
+	if len(data) == 1 {
+		structData = data[0]
+	}
+	err := s.onShards(structData, func() error {
+		return s.withHookTx(hasHook(model, hookAfterUpdate), func() error {
+			if err := s.callHook(model, hookBeforeUpdate); err != nil {
+				return err
+			}
+			// 在多个分片上执行时每个分片重新构建SET子句
+			s.resetValues()
+			shardId, n, err := s.update(data...)
+			if err != nil {
+				return err
+			}
+			id = shardId
+			affected += n
+			return s.callHook(model, hookAfterUpdate)
+		})
+	})
+	if err != nil {
+		return -1, -1, err
 	}
+	return id, affected, nil
+}
 
-	// 如果是键值对更新，则直接构建SET子句。
-	if !single {
-		if s.updateParam.String() != "" {
-			s.updateParam.WriteString(",")
-		}
-		s.updateParam.WriteString(data[0].(string))
-		s.updateParam.WriteString(" = ? ")
-		s.values = append(s.values, data[1])
-	} else {
-		// 如果是结构体更新，则通过反射提取结构体字段信息。
+// update 构建更新SQL语句并执行。
+func (s *FrameSession) update(data ...any) (int64, int64, error) {
+	now := time.Now()
+	var version *field
+	var vVar reflect.Value
+	switch len(data) {
+	case 2:
+		// 如果是键值对更新，则直接构建SET子句。
+		s.setParam(data[0].(string), data[1])
+	case 1:
+		// 如果是结构体更新，则通过解析后的表结构提取结构体字段信息。
 		updateData := data[0]
 		t := reflect.TypeOf(updateData)
-		v := reflect.ValueOf(updateData)
 
 		// 确保传递的是一个指针类型。
 		if t.Kind() != reflect.Pointer {
-			panic(errors.New("updateData must be pointer"))
+			return -1, -1, errors.New("updateData must be pointer")
 		}
+		sc, err := parseSchema(t)
+		if err != nil {
+			return -1, -1, err
+		}
+		vVar = reflect.ValueOf(updateData).Elem()
+		setUpdateTime(sc, vVar, now)
 
-		tVar := t.Elem()
-		vVar := v.Elem()
-
-		// 遍历结构体字段，构建SET子句。
-		for i := 0; i < tVar.NumField(); i++ {
-			fieldName := tVar.Field(i).Name
-			tag := tVar.Field(i).Tag
-			sqlTag := tag.Get("gorm")
-
-			// 如果字段标记包含"auto_increment"，则跳过该字段。
-			if sqlTag == "" {
-				sqlTag = strings.ToLower(Name(fieldName))
-			} else {
-				if strings.Contains(sqlTag, "auto_increment") {
-					continue
-				}
-				if strings.Contains(sqlTag, ",") {
-					sqlTag = sqlTag[:strings.Index(sqlTag, ",")]
-				}
-			}
-
-			id := vVar.Field(i).Interface()
-
-			// 如果字段是自增长主键且值为默认值，则跳过该字段。
-			if strings.ToLower(sqlTag) == "id" && IsAutoId(id) {
+		// 遍历结构体字段，构建SET子句。自增字段和值为默认值的主键不参与更新，
+		// 值为零的创建时间不参与更新，避免覆盖数据库中已有的值，版本号由乐观锁维护。
+		for _, f := range insertFields(sc, vVar) {
+			if f == sc.CreatedAt && vVar.Field(f.Index).IsZero() || f == sc.Version {
 				continue
 			}
-
-			if s.updateParam.String() != "" {
-				s.updateParam.WriteString(",")
+			value, err := fieldValue(f, vVar.Field(f.Index))
+			if err != nil {
+				return -1, -1, err
 			}
-			s.updateParam.WriteString(sqlTag)
-			s.updateParam.WriteString(" = ? ")
-			s.values = append(s.values, vVar.Field(i).Interface())
+			s.setParam(f.Column, value)
+		}
+		// 乐观锁：只更新版本号与结构体一致的记录
+		if version = sc.Version; version != nil {
+			s.setVersion(version)
+			s.whereVersion(version, vVar.Field(version.Index).Interface())
+		}
+	}
+	// 按列更新时，如果模型有更新时间字段且没有手动设置，则自动设置为当前时间，版本号加一。
+	if len(data) != 1 {
+		s.touchUpdatedAt(now)
+		if f := s.versionField(); f != nil && s.updateParam.Len() > 0 {
+			s.setVersion(f)
 		}
 	}
 
@@ -516,63 +633,91 @@ func (s *FrameSession) Update(data ...any) (int64, int64, error) {
 	query := fmt.Sprintf("update %s set %s", s.tableName, s.updateParam.String())
 	var sb strings.Builder
 	sb.WriteString(query)
-	sb.WriteString(s.whereParam.String())
-	s.db.logger.Info(sb.String())
-
-	// 根据事务状态选择不同的数据库连接进行预编译。
-	var stmt *sql.Stmt
-	var err error
-	if s.beginTx {
-		stmt, err = s.tx.Prepare(sb.String())
-	} else {
-		stmt, err = s.db.db.Prepare(sb.String())
-	}
-	if err != nil {
-		return -1, -1, err
-	}
+	sb.WriteString(s.conditionSQL())
 
 	// 执行SQL语句并获取结果。
-	s.values = append(s.values, s.whereValues...)
-	r, err := stmt.Exec(s.values...)
+	s.values = append(s.values, s.conditionValues()...)
+	r, err := s.exec(sb.String(), s.values...)
 	if err != nil {
 		return -1, -1, err
 	}
+	// 不支持 LastInsertId 的驱动（例如 PostgreSQL）ID 为 0
 	id, err := r.LastInsertId()
 	if err != nil {
-		return -1, -1, err
+		id = 0
 	}
 	affected, err := r.RowsAffected()
 	if err != nil {
 		return -1, -1, err
 	}
+	if version != nil && s.dryRun == nil {
+		if affected == 0 {
+			return -1, -1, ErrStaleObject
+		}
+		incrementVersion(version, vVar)
+	}
 	return id, affected, nil
 }
 
+// touchUpdatedAt 在会话模型有更新时间字段且SET子句中没有设置该列时，追加 updated_at = 当前时间。
+func (s *FrameSession) touchUpdatedAt(now time.Time) {
+	if s.model == nil || s.updateParam.Len() == 0 {
+		return
+	}
+	sc, err := parseSchema(reflect.TypeOf(s.model))
+	if err != nil || sc.UpdatedAt == nil {
+		return
+	}
+	for _, name := range s.updateFields {
+		if name == sc.UpdatedAt.Column {
+			return
+		}
+	}
+	s.setParam(sc.UpdatedAt.Column, timeValue(sc.UpdatedAt, now))
+}
+
 // Delete 从数据库中删除符合条件的记录。
 // 参数说明：
 //   - 无显式参数，方法基于当前 FrameSession 的状态（如表名、条件等）执行删除操作。
+//
+// 删除钩子在创建会话的模型上调用。
+// 模型有软删除字段时执行软删除（将删除时间设置为当前时间），使用 Unscoped 时执行物理删除。
 func (s *FrameSession) Delete() (int64, error) {
+	var affected int64
+	err := s.onShards(nil, func() error {
+		return s.withHookTx(hasHook(s.model, hookAfterDelete), func() error {
+			if err := s.callHook(s.model, hookBeforeDelete); err != nil {
+				return err
+			}
+			// 软删除在多个分片上执行时每个分片重新构建SET子句
+			s.resetValues()
+			n, err := s.delete()
+			if err != nil {
+				return err
+			}
+			affected += n
+			return s.callHook(s.model, hookAfterDelete)
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
+
+// delete 构建删除SQL语句并执行，模型有软删除字段时改为执行软删除。
+func (s *FrameSession) delete() (int64, error) {
+	if f := s.softDeleteField(); f != nil {
+		return s.softDelete(f)
+	}
 	// 构建删除SQL语句
 	query := fmt.Sprintf("delete from %s ", s.tableName)
 	var sb strings.Builder
 	sb.WriteString(query)
-	sb.WriteString(s.whereParam.String())
-	s.db.logger.Info(sb.String())
-
-	// 根据事务状态选择不同的数据库连接进行预编译
-	var stmt *sql.Stmt
-	var err error
-	if s.beginTx {
-		stmt, err = s.tx.Prepare(sb.String())
-	} else {
-		stmt, err = s.db.db.Prepare(sb.String())
-	}
-	if err != nil {
-		return 0, err
-	}
+	sb.WriteString(s.conditionSQL())
 
 	// 执行删除操作
-	r, err := stmt.Exec(s.whereValues...)
+	r, err := s.exec(sb.String(), s.conditionValues()...)
 	if err != nil {
 		return 0, err
 	}
@@ -586,11 +731,33 @@ func (s *FrameSession) Delete() (int64, error) {
 // 查询指定字段的数据，并将结果映射到传入的数据结构中。
 // 如果传入的数据参数不是指针类型，则返回错误。
 func (s *FrameSession) Select(data any, fields ...string) ([]any, error) {
+	// 分片表在没有分片键且允许 FanOut 时合并各分片的结果
+	result := make([]any, 0)
+	err := s.onShards(nil, func() error {
+		items, err := s.selectAll(data, fields...)
+		result = append(result, items...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s.limit > 0 && len(result) > s.limit {
+		result = result[:s.limit]
+	}
+	return result, nil
+}
+
+// selectAll 在会话的表中执行查询，返回映射后的结果集。
+func (s *FrameSession) selectAll(data any, fields ...string) ([]any, error) {
 	// 检查传入的data是否为指针类型
 	t := reflect.TypeOf(data)
 	if t.Kind() != reflect.Pointer {
 		return nil, errors.New("data must be pointer")
 	}
+	// 会话没有模型时使用 data 作为模型，用于识别软删除字段
+	if s.model == nil {
+		s.model = data
+	}
 
 	// 根据传入的fields参数构建查询字段字符串
 	fieldStr := "*"
@@ -599,24 +766,32 @@ func (s *FrameSession) Select(data any, fields ...string) ([]any, error) {
 	}
 
 	// 构建查询语句
-	query := fmt.Sprintf("select %s from %s ", fieldStr, s.tableName)
+	query := fmt.Sprintf("select %s from %s ", fieldStr, s.tableSQL())
 	var sb strings.Builder
 	sb.WriteString(query)
-	sb.WriteString(s.whereParam.String())
-	s.db.logger.Info(sb.String())
+	sb.WriteString(s.conditionSQL())
 
-	// 准备查询语句
-	stmt, err := s.db.db.Prepare(sb.String())
+	// 解析数据结构对应的表结构
+	sc, err := parseSchema(t)
 	if err != nil {
 		return nil, err
 	}
 
-	// 执行查询
-	rows, err := stmt.Query(s.whereValues...)
-	if err != nil {
-		return nil, err
+	// 开启查询缓存时优先返回缓存的结果
+	store, key, cached := s.cacheKey("select:"+sc.Type.String(), sb.String(), s.queryValues())
+	if cached {
+		if v, ok := store.Get(key); ok {
+			return cloneItems(v.([]any)), nil
+		}
 	}
 
+	// 执行查询，试运行时返回空结果
+	rows, err := s.query(true, sb.String(), s.queryValues()...)
+	if err != nil || rows == nil {
+		return make([]any, 0), err
+	}
+	defer rows.Close()
+
 	// 获取查询结果的列名
 	columns, err := rows.Columns()
 	if err != nil {
@@ -625,57 +800,34 @@ func (s *FrameSession) Select(data any, fields ...string) ([]any, error) {
 
 	// 初始化结果集
 	result := make([]any, 0)
-	for {
-		if rows.Next() {
-			// 为每次查询结果创建一个新的data实例
-			data := reflect.New(t.Elem()).Interface()
-			values := make([]any, len(columns))
-			fieldScan := make([]any, len(columns))
-			for i := range fieldScan {
-				fieldScan[i] = &values[i]
-			}
-
-			// 将查询结果扫描到fieldScan中
-			err := rows.Scan(fieldScan...)
-			if err != nil {
-				return nil, err
-			}
-
-			// 获取data实例的类型和值
-			tVar := t.Elem()
-			vVar := reflect.ValueOf(data).Elem()
-
-			// 将查询结果映射到data实例中
-			for i := 0; i < tVar.NumField(); i++ {
-				name := tVar.Field(i).Name
-				tag := tVar.Field(i).Tag
-				sqlTag := tag.Get("gorm")
-				if sqlTag == "" {
-					sqlTag = strings.ToLower(Name(name))
-				} else {
-					if strings.Contains(sqlTag, ",") {
-						sqlTag = sqlTag[:strings.Index(sqlTag, ",")]
-					}
-				}
-
-				for j, colName := range columns {
-					if sqlTag == colName {
-						target := values[j]
-						targetValue := reflect.ValueOf(target)
-						fieldType := tVar.Field(i).Type
-						result := reflect.ValueOf(targetValue.Interface()).Convert(fieldType)
-						vVar.Field(i).Set(result)
-					}
-				}
-			}
-
-			// 将填充好的data实例添加到结果集中
-			result = append(result, data)
-		} else {
-			break
+	items := make([]reflect.Value, 0)
+	for rows.Next() {
+		// 为每次查询结果创建一个新的data实例，并将查询结果映射到data实例中
+		data := reflect.New(sc.Type)
+		if err := scanRow(rows, columns, sc, data.Elem()); err != nil {
+			return nil, err
 		}
+		// 将填充好的data实例添加到结果集中
+		result = append(result, data.Interface())
+		items = append(items, data)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
+	// 预加载关联
+	if err := s.preload(sc, items); err != nil {
+		return nil, err
+	}
+	// 关联加载完成后调用模型的 AfterFind 钩子
+	for _, item := range result {
+		if err := s.callHook(item, hookAfterFind); err != nil {
+			return nil, err
+		}
+	}
+	if cached {
+		store.Set(key, cloneItems(result), s.cacheTTL)
+	}
 	// 返回结果集
 	return result, nil
 }
@@ -684,11 +836,29 @@ func (s *FrameSession) Select(data any, fields ...string) ([]any, error) {
 // 参数 data 是一个指向数据结构的指针，函数将查询结果填充到这个数据结构中。
 // 参数 fields 是一个可变参数，用于指定要选择的字段，如果未提供则选择所有字段。
 func (s *FrameSession) SelectOne(data any, fields ...string) error {
+	// 分片表在没有分片键且允许 FanOut 时依次查询各分片，返回第一个查询到的记录
+	found := false
+	return s.onShards(nil, func() error {
+		if found {
+			return nil
+		}
+		var err error
+		found, err = s.selectOne(data, fields...)
+		return err
+	})
+}
+
+// selectOne 查询一条记录并映射到 data 中，found 表示是否查询到记录。
+func (s *FrameSession) selectOne(data any, fields ...string) (bool, error) {
 	// 获取 data 参数的类型
 	t := reflect.TypeOf(data)
 	// 检查 data 是否是一个指针类型
 	if t.Kind() != reflect.Pointer {
-		return errors.New("data must be pointer")
+		return false, errors.New("data must be pointer")
+	}
+	// 会话没有模型时使用 data 作为模型，用于识别软删除字段
+	if s.model == nil {
+		s.model = data
 	}
 	// 初始化字段字符串，如果未指定字段则默认为 "*"
 	fieldStr := "*"
@@ -696,72 +866,66 @@ func (s *FrameSession) SelectOne(data any, fields ...string) error {
 		fieldStr = strings.Join(fields, ",")
 	}
 	// 构建查询语句
-	query := fmt.Sprintf("select %s from %s ", fieldStr, s.tableName)
+	query := fmt.Sprintf("select %s from %s ", fieldStr, s.tableSQL())
 	var sb strings.Builder
 	sb.WriteString(query)
-	sb.WriteString(s.whereParam.String())
-	// 记录查询日志
-	s.db.logger.Info(sb.String())
+	sb.WriteString(s.conditionSQL())
+
+	// 开启查询缓存时优先使用缓存的结果
+	store, key, cached := s.cacheKey("one:"+t.String(), sb.String(), s.queryValues())
+	if cached {
+		if v, ok := store.Get(key); ok {
+			reflect.ValueOf(data).Elem().Set(reflect.ValueOf(v).Elem())
+			return true, nil
+		}
+	}
 
-	// 准备查询语句
-	stmt, err := s.db.db.Prepare(sb.String())
-	if err != nil {
-		return err
+	// 执行查询，试运行时不修改 data
+	rows, err := s.query(true, sb.String(), s.queryValues()...)
+	if err != nil || rows == nil {
+		return false, err
+	}
+	defer rows.Close()
+	// 将第一行查询结果映射到数据结构中
+	found, err := scanOne(rows, data)
+	if err != nil || !found {
+		return false, err
 	}
-	// 执行查询
-	rows, err := stmt.Query(s.whereValues...)
+	// 预加载关联前关闭结果集，释放事务中的连接
+	rows.Close()
+	sc, _ := parseSchema(t)
+	if err := s.preload(sc, []reflect.Value{reflect.ValueOf(data)}); err != nil {
+		return false, err
+	}
+	if err := s.callHook(data, hookAfterFind); err != nil {
+		return false, err
+	}
+	// 只缓存查询到的记录
+	if cached {
+		store.Set(key, clonePointer(data), s.cacheTTL)
+	}
+	return true, nil
+}
+
+// scanOne 将查询结果的第一行映射到 data 指向的结构体中，没有数据时 data 保持不变，found 为 false。
+func scanOne(rows *sql.Rows, data any) (found bool, err error) {
+	sc, err := parseSchema(reflect.TypeOf(data))
 	if err != nil {
-		return err
+		return false, err
 	}
 	// 获取查询结果的列名
 	columns, err := rows.Columns()
 	if err != nil {
-		return err
-	}
-	// 初始化用于存储查询结果的变量
-	values := make([]any, len(columns))
-	fieldScan := make([]any, len(columns))
-	for i := range fieldScan {
-		fieldScan[i] = &values[i]
+		return false, err
 	}
 	// 处理查询结果
 	if rows.Next() {
-		err := rows.Scan(fieldScan...)
-		if err != nil {
-			return err
-		}
-		// 获取 data 参数的类型和值
-		tVar := t.Elem()
-		vVar := reflect.ValueOf(data).Elem()
-		// 遍历类型的字段，将查询结果映射到数据结构中
-		for i := 0; i < tVar.NumField(); i++ {
-			name := tVar.Field(i).Name
-			tag := tVar.Field(i).Tag
-			// 获取字段的 SQL 标签
-			sqlTag := tag.Get("gorm")
-			if sqlTag == "" {
-				sqlTag = strings.ToLower(Name(name))
-			} else {
-				if strings.Contains(sqlTag, ",") {
-					sqlTag = sqlTag[:strings.Index(sqlTag, ",")]
-				}
-			}
-
-			// 将查询结果映射到数据结构的字段中
-			for j, colName := range columns {
-				if sqlTag == colName {
-					target := values[j]
-					targetValue := reflect.ValueOf(target)
-					fieldType := tVar.Field(i).Type
-					// 将查询结果转换为字段的类型
-					result := reflect.ValueOf(targetValue.Interface()).Convert(fieldType)
-					vVar.Field(i).Set(result)
-				}
-			}
-
+		if err := scanRow(rows, columns, sc, reflect.ValueOf(data).Elem()); err != nil {
+			return false, err
 		}
+		return true, nil
 	}
-	return nil
+	return false, rows.Err()
 }
 
 // Count 统计 FrameSession 中的帧数。
@@ -773,7 +937,25 @@ func (s *FrameSession) Count() (int64, error) {
 
 // Aggregate 执行聚合函数查询
 // 该方法根据提供的函数名称和字段，在数据库中执行聚合操作（如COUNT, SUM等）。
+// 分片表在所有分片上执行时合并各分片的结果，只支持 count、sum、max、min。
 func (s *FrameSession) Aggregate(funcName string, field string) (int64, error) {
+	results := make([]int64, 0, 1)
+	err := s.onShards(nil, func() error {
+		result, err := s.aggregate(funcName, field)
+		results = append(results, result)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(results) == 1 {
+		return results[0], nil
+	}
+	return mergeAggregate(funcName, results)
+}
+
+// aggregate 在会话的表中执行聚合函数查询。
+func (s *FrameSession) aggregate(funcName string, field string) (int64, error) {
 	// 构建聚合函数的字段字符串，例如"COUNT(id)"
 	var fieldSb strings.Builder
 	fieldSb.WriteString(funcName)
@@ -782,58 +964,53 @@ func (s *FrameSession) Aggregate(funcName string, field string) (int64, error) {
 	fieldSb.WriteString(")")
 
 	// 构建完整的SQL查询语句
-	query := fmt.Sprintf("select %s from %s ", fieldSb.String(), s.tableName)
+	query := fmt.Sprintf("select %s from %s ", fieldSb.String(), s.tableSQL())
 
-	// 将查询语句和WHERE条件参数合并生成最终的SQL语句
+	// 将查询语句和WHERE条件参数合并生成最终的SQL语句，统计查询不需要排序和分页
 	var sb strings.Builder
 	sb.WriteString(query)
-	sb.WriteString(s.whereParam.String())
-
-	// 记录SQL语句的日志
-	s.db.logger.Info(sb.String())
+	sb.WriteString(s.filterSQL())
 
-	// 准备SQL语句
-	stmt, err := s.db.db.Prepare(sb.String())
-	if err != nil {
-		return 0, err
+	// 开启查询缓存时优先返回缓存的结果
+	store, key, cached := s.cacheKey("aggregate", sb.String(), s.queryValues())
+	if cached {
+		if v, ok := store.Get(key); ok {
+			return v.(int64), nil
+		}
 	}
 
-	// 执行SQL查询
-	row := stmt.QueryRow(s.whereValues...)
-	if row.Err() != nil {
+	// 执行SQL查询，试运行时结果为 0
+	rows, err := s.query(true, sb.String(), s.queryValues()...)
+	if err != nil || rows == nil {
 		return 0, err
 	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		return 0, sql.ErrNoRows
+	}
 
-	// 存储查询结果
-	var result int64
-	// 将查询结果扫描到变量中
-	err = row.Scan(&result)
-	if err != nil {
+	// 将查询结果扫描到变量中，没有匹配的记录时 sum 等聚合函数返回 NULL
+	var result sql.NullInt64
+	if err := rows.Scan(&result); err != nil {
 		return 0, err
 	}
 
+	if cached {
+		store.Set(key, result.Int64, s.cacheTTL)
+	}
 	// 返回聚合操作的结果
-	return result, nil
+	return result.Int64, nil
 }
 
 // Exec 执行SQL语句并返回受影响的行数或最后插入的ID。
-// 该方法根据是否开始事务来决定使用事务的Prepare方法还是数据库连接的Prepare方法准备SQL语句。
+// 该方法根据是否开始事务来决定使用事务还是数据库连接准备SQL语句。
 // 如果是插入操作，返回最后插入的ID；否则返回受影响的行数。
 func (s *FrameSession) Exec(query string, values ...any) (int64, error) {
-	// 根据是否在事务中，选择不同的SQL准备方式。
-	var stmt *sql.Stmt
-	var err error
-	if s.beginTx {
-		stmt, err = s.tx.Prepare(query)
-	} else {
-		stmt, err = s.db.db.Prepare(query)
-	}
-	// 如果准备SQL语句时发生错误，返回错误。
-	if err != nil {
-		return 0, err
-	}
 	// 执行SQL语句。
-	r, err := stmt.Exec(values)
+	r, err := s.exec(query, values...)
 	// 如果执行SQL语句时发生错误，返回错误。
 	if err != nil {
 		return 0, err
@@ -847,6 +1024,16 @@ func (s *FrameSession) Exec(query string, values ...any) (int64, error) {
 	return r.RowsAffected()
 }
 
+// ExecContext 使用指定的上下文执行SQL语句并返回受影响的行数。
+// 与 Exec 不同，它不会根据SQL语句的内容调用 LastInsertId（PostgreSQL 等驱动不支持），适用于执行迁移等任意语句。
+func (s *FrameSession) ExecContext(ctx context.Context, query string, values ...any) (int64, error) {
+	r, err := s.execContext(ctx, query, values...)
+	if err != nil {
+		return 0, err
+	}
+	return r.RowsAffected()
+}
+
 // QueryRow 执行SQL查询，并将结果映射到提供的数据结构中。
 func (s *FrameSession) QueryRow(sql string, data any, queryValues ...any) error {
 	// 检查data是否为指针类型，因为需要直接修改其指向的值。
@@ -854,80 +1041,43 @@ func (s *FrameSession) QueryRow(sql string, data any, queryValues ...any) error
 	if t.Kind() != reflect.Pointer {
 		return errors.New("data must be pointer")
 	}
-	// 准备SQL语句。
-	stmt, err := s.db.db.Prepare(sql)
-	if err != nil {
-		return err
-	}
 	// 执行查询。
-	rows, err := stmt.Query(queryValues...)
-	if err != nil {
+	rows, err := s.query(false, sql, queryValues...)
+	if err != nil || rows == nil {
 		return err
 	}
-	// 获取查询结果的列名。
-	columns, err := rows.Columns()
-	if err != nil {
+	defer rows.Close()
+	// 将查询结果的第一行数据映射到data中。
+	found, err := scanOne(rows, data)
+	if err != nil || !found {
 		return err
 	}
-	// 初始化用于存储查询结果的变量。
-	values := make([]any, len(columns))
-	fieldScan := make([]any, len(columns))
-	for i := range fieldScan {
-		fieldScan[i] = &values[i]
-	}
-	// 处理查询结果的第一行数据。
-	if rows.Next() {
-		err := rows.Scan(fieldScan...)
-		if err != nil {
-			return err
-		}
-		// 获取data的类型和值。
-		tVar := t.Elem()
-		vVar := reflect.ValueOf(data).Elem()
-		// 遍历data的字段，将查询结果映射到相应字段。
-		for i := 0; i < tVar.NumField(); i++ {
-			name := tVar.Field(i).Name
-			tag := tVar.Field(i).Tag
-			// 获取字段的SQL标签。
-			sqlTag := tag.Get("gorm")
-			if sqlTag == "" {
-				sqlTag = strings.ToLower(Name(name))
-			} else {
-				if strings.Contains(sqlTag, ",") {
-					sqlTag = sqlTag[:strings.Index(sqlTag, ",")]
-				}
-			}
-			// 将查询结果映射到data的字段。
-			for j, colName := range columns {
-				if sqlTag == colName {
-					target := values[j]
-					targetValue := reflect.ValueOf(target)
-					fieldType := tVar.Field(i).Type
-					// 将查询结果转换为字段的类型。
-					result := reflect.ValueOf(targetValue.Interface()).Convert(fieldType)
-					vVar.Field(i).Set(result)
-				}
-			}
-		}
-	}
-	return nil
+	return s.callHook(data, hookAfterFind)
 }
 
 // Begin 开始一个新的事务。
 // 返回错误如果数据库操作失败。
+// 推荐使用 FrameDb.Transaction，它会在出错或 panic 时自动回滚。
 func (s *FrameSession) Begin() error {
+	return s.BeginTx(context.Background(), nil)
+}
+
+// BeginTx 使用指定的上下文和事务选项（隔离级别、只读）开始一个新的事务。
+// 返回错误如果数据库操作失败。
+func (s *FrameSession) BeginTx(ctx context.Context, opts *sql.TxOptions) error {
 	// 获取sql.DB中的事务
-	tx, err := s.db.db.Begin()
+	tx, err := s.db.db.BeginTx(ctx, opts)
 	if err != nil {
 		return err
 	}
 	// 设置事务为true
 	s.tx = tx
 	s.beginTx = true
+	s.txState = &txState{}
 	return nil
 }
 
-// Commit 提交当前事务。
+// Commit 提交当前事务，提交成功后执行通过 AfterCommit 注册的回调。
 // 返回错误如果数据库操作失败。
 func (s *FrameSession) Commit() error {
 	// 提交事务
@@ -936,10 +1086,11 @@ func (s *FrameSession) Commit() error {
 		return err
 	}
 	s.beginTx = false
+	s.txState.runAfterCommit()
 	return nil
 }
 
-// Rollback 回滚当前事务。
+// Rollback 回滚当前事务，通过 AfterCommit 注册的回调会被丢弃。
 // 返回错误如果数据库操作失败。
 func (s *FrameSession) Rollback() error {
 	// 回滚事务
@@ -948,36 +1099,101 @@ func (s *FrameSession) Rollback() error {
 		return err
 	}
 	s.beginTx = false
+	s.txState.afterCommit = nil
 	return nil
 }
 
-// Where 为查询添加一个等值条件。
-// 参数 field 是列名，value 是对应的值。
+// Where 为查询添加一个条件，多次调用时条件之间默认使用 and 连接。
+// 支持三种调用方式：
+//  1. 传递列名和值，生成等值条件，例如：Where("id", 1)
+//  2. 传递一个条件，例如：Where(orm.Gt("age", 18))、Where(orm.OrGroup(orm.Eq("a", 1), orm.In("b", ids)))
+//  3. 传递原生SQL片段和参数，例如：Where("age > ? and age < ?", 18, 30)
+//
 // 返回修改后的 FrameSession 实例。
-func (s *FrameSession) Where(field string, value any) *FrameSession {
-	//id=1 and name=xx
-	if s.whereParam.String() == "" {
-		s.whereParam.WriteString(" where ")
-	}
-	s.whereParam.WriteString(field)
-	s.whereParam.WriteString(" = ")
-	s.whereParam.WriteString(" ? ")
-	s.whereValues = append(s.whereValues, value)
+func (s *FrameSession) Where(query any, args ...any) *FrameSession {
+	s.addCondition(toCondition(query, args))
 	return s
 }
 
+// addCondition 将条件追加到WHERE子句中。
+// 第一个条件前添加 where 关键字，之后的条件之前添加由 And/Or 指定的连接符，默认为 and。
+func (s *FrameSession) addCondition(cond Condition) {
+	query, values := cond.Build()
+	if c, ok := cond.(*compareCond); ok && c.op == "=" {
+		s.whereEq = append(s.whereEq, c)
+	}
+	if s.whereConn == " or " {
+		s.whereOr = true
+	}
+	conn := s.whereConn
+	if conn == "" {
+		conn = " and "
+	}
+	s.whereConn = ""
+	chainCondition(&s.whereParam, " where ", conn, query, needParens(cond, query), &s.whereWrap)
+	s.whereValues = append(s.whereValues, values...)
+}
+
+// chainCondition 使用连接符 conn 将条件 query 追加到 sb 中，keyword 是第一个条件之前的关键字。
+// 需要括号的条件（paren 为 true）与其它条件连接时使用括号包裹，只有一个条件时不添加括号，
+// wrap 记录第一个条件是否需要在追加下一个条件时补充括号。
+func chainCondition(sb *strings.Builder, keyword, conn, query string, paren bool, wrap *bool) {
+	if sb.Len() == 0 {
+		sb.WriteString(keyword)
+		sb.WriteString(query)
+		*wrap = paren
+		return
+	}
+	if *wrap {
+		first := strings.TrimPrefix(sb.String(), keyword)
+		sb.Reset()
+		sb.WriteString(keyword + "(" + first + ")")
+		*wrap = false
+	}
+	sb.WriteString(conn)
+	if paren {
+		query = "(" + query + ")"
+	}
+	sb.WriteString(query)
+}
+
+// conditionSQL 返回WHERE、GROUP BY、HAVING、ORDER BY、LIMIT等条件子句拼接后的SQL片段。
+func (s *FrameSession) conditionSQL() string {
+	var sb strings.Builder
+	sb.WriteString(s.filterSQL())
+	sb.WriteString(s.orderParam.String())
+	if s.limit > 0 {
+		fmt.Fprintf(&sb, " limit %d", s.limit)
+	}
+	if s.offset > 0 {
+		fmt.Fprintf(&sb, " offset %d", s.offset)
+	}
+	return sb.String()
+}
+
+// filterSQL 返回WHERE、GROUP BY、HAVING子句拼接后的SQL片段，不包含排序和分页，供统计查询使用。
+func (s *FrameSession) filterSQL() string {
+	var sb strings.Builder
+	sb.WriteString(s.whereSQL())
+	sb.WriteString(s.groupParam.String())
+	sb.WriteString(s.havingParam.String())
+	return sb.String()
+}
+
+// conditionValues 返回条件子句中按占位符顺序排列的参数。
+func (s *FrameSession) conditionValues() []any {
+	values := make([]any, 0, len(s.whereValues)+len(s.havingValues))
+	values = append(values, s.whereValues...)
+	values = append(values, s.havingValues...)
+	return values
+}
+
 // Like 为查询添加一个模糊条件（右侧包含）。
 // 参数 field 是列名，value 是对应的值。
 // 返回修改后的 FrameSession 实例。
 func (s *FrameSession) Like(field string, value any) *FrameSession {
 	//name like %s%
-	if s.whereParam.String() == "" {
-		s.whereParam.WriteString(" where ")
-	}
-	s.whereParam.WriteString(field)
-	s.whereParam.WriteString(" like ")
-	s.whereParam.WriteString(" ? ")
-	s.whereValues = append(s.whereValues, "%"+value.(string)+"%")
+	s.addCondition(Like(field, "%"+value.(string)+"%"))
 	return s
 }
 
@@ -985,14 +1201,8 @@ func (s *FrameSession) Like(field string, value any) *FrameSession {
 // 参数 field 是列名，value 是对应的值。
 // 返回修改后的 FrameSession 实例。
 func (s *FrameSession) LikeRight(field string, value any) *FrameSession {
-	//name like %s%
-	if s.whereParam.String() == "" {
-		s.whereParam.WriteString(" where ")
-	}
-	s.whereParam.WriteString(field)
-	s.whereParam.WriteString(" like ")
-	s.whereParam.WriteString(" ? ")
-	s.whereValues = append(s.whereValues, value.(string)+"%")
+	//name like s%
+	s.addCondition(Like(field, value.(string)+"%"))
 	return s
 }
 
@@ -1000,14 +1210,8 @@ func (s *FrameSession) LikeRight(field string, value any) *FrameSession {
 // 参数 field 是列名，value 是对应的值。
 // 返回修改后的 FrameSession 实例。
 func (s *FrameSession) LikeLeft(field string, value any) *FrameSession {
-	//name like %s%
-	if s.whereParam.String() == "" {
-		s.whereParam.WriteString(" where ")
-	}
-	s.whereParam.WriteString(field)
-	s.whereParam.WriteString(" like ")
-	s.whereParam.WriteString(" ? ")
-	s.whereValues = append(s.whereValues, "%"+value.(string))
+	//name like %s
+	s.addCondition(Like(field, "%"+value.(string)))
 	return s
 }
 
@@ -1016,8 +1220,37 @@ func (s *FrameSession) LikeLeft(field string, value any) *FrameSession {
 // 返回修改后的 FrameSession 实例。
 func (s *FrameSession) Group(field ...string) *FrameSession {
 	//group by aa,bb
-	s.whereParam.WriteString(" group by ")
-	s.whereParam.WriteString(strings.Join(field, ","))
+	if s.groupParam.Len() == 0 {
+		s.groupParam.WriteString(" group by ")
+	} else {
+		s.groupParam.WriteString(",")
+	}
+	s.groupParam.WriteString(strings.Join(field, ","))
+	return s
+}
+
+// Having 为分组查询添加一个过滤条件，多次调用时条件之间使用 and 连接。
+// 参数的使用方式与 Where 相同，例如：Group("age").Having(orm.Gt("count(*)", 1))
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) Having(query any, args ...any) *FrameSession {
+	c := toCondition(query, args)
+	cond, values := c.Build()
+	chainCondition(&s.havingParam, " having ", " and ", cond, needParens(c, cond), &s.havingWrap)
+	s.havingValues = append(s.havingValues, values...)
+	return s
+}
+
+// Limit 设置查询返回的最大行数。
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) Limit(limit int) *FrameSession {
+	s.limit = limit
+	return s
+}
+
+// Offset 设置查询跳过的行数，通常与 Limit 一起使用。
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) Offset(offset int) *FrameSession {
+	s.offset = offset
 	return s
 }
 
@@ -1026,9 +1259,7 @@ func (s *FrameSession) Group(field ...string) *FrameSession {
 // 返回修改后的 FrameSession 实例。
 func (s *FrameSession) OrderDesc(field ...string) *FrameSession {
 	//order by aa,bb desc
-	s.whereParam.WriteString(" order by ")
-	s.whereParam.WriteString(strings.Join(field, ","))
-	s.whereParam.WriteString(" desc ")
+	s.order(strings.Join(field, ",") + " desc ")
 	return s
 }
 
@@ -1037,9 +1268,7 @@ func (s *FrameSession) OrderDesc(field ...string) *FrameSession {
 // 返回修改后的 FrameSession 实例。
 func (s *FrameSession) OrderAsc(field ...string) *FrameSession {
 	//order by aa,bb asc
-	s.whereParam.WriteString(" order by ")
-	s.whereParam.WriteString(strings.Join(field, ","))
-	s.whereParam.WriteString(" asc ")
+	s.order(strings.Join(field, ",") + " asc ")
 	return s
 }
 
@@ -1051,25 +1280,31 @@ func (s *FrameSession) Order(field ...string) *FrameSession {
 	if len(field)%2 != 0 {
 		panic("field num not true")
 	}
-	s.whereParam.WriteString(" order by ")
-	for index, v := range field {
-		s.whereParam.WriteString(v + " ")
-		if index%2 != 0 && index < len(field)-1 {
-			s.whereParam.WriteString(",")
-		}
+	for index := 0; index < len(field); index += 2 {
+		s.order(field[index] + " " + field[index+1] + " ")
 	}
 	return s
 }
 
-// And 在对应的条件后面添加 and
+// order 向ORDER BY子句追加一个排序项，多次调用时使用逗号分隔。
+func (s *FrameSession) order(item string) {
+	if s.orderParam.Len() == 0 {
+		s.orderParam.WriteString(" order by ")
+	} else {
+		s.orderParam.WriteString(",")
+	}
+	s.orderParam.WriteString(item)
+}
+
+// And 指定下一个条件与前一个条件使用 and 连接（默认行为）
 func (s *FrameSession) And() *FrameSession {
-	s.whereParam.WriteString(" and ")
+	s.whereConn = " and "
 	return s
 }
 
-// Or 在对应的条件后面添加 or
+// Or 指定下一个条件与前一个条件使用 or 连接
 func (s *FrameSession) Or() *FrameSession {
-	s.whereParam.WriteString(" or ")
+	s.whereConn = " or "
 	return s
 }
 
@@ -1126,7 +1361,7 @@ func Name(name string) string {
 				continue
 			}
 			// 向 sb 中添加从 lastIndex 到当前索引的子字符串
-			sb.WriteString(name[:index])
+			sb.WriteString(name[lastIndex:index])
 			// 添加下划线
 			sb.WriteString("_")
 			// 更新 lastIndex 为当前索引
@@ -1,10 +1,688 @@
 package orm
 
 import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestName(t *testing.T) {
 	fmt.Println(Name("User"))
 }
+
+type convertUser struct {
+	Id        int64
+	UserName  string
+	Age       int8
+	Score     *float64
+	Nick      sql.NullString
+	Active    bool
+	CreatedAt time.Time
+	Profile   map[string]any `gorm:"profile,json"`
+}
+
+func TestAssignRow(t *testing.T) {
+	sc, err := parseSchema(reflect.TypeOf(&convertUser{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns := []string{"id", "user_name", "age", "score", "nick", "active", "created_at", "profile"}
+	values := []any{int64(1), []byte("yyds"), []byte("18"), nil, nil, int64(1), []byte("2024-01-02 03:04:05"), []byte(`{"city":"sh"}`)}
+	u := &convertUser{}
+	if err := assignRow(columns, values, sc, reflect.ValueOf(u).Elem()); err != nil {
+		t.Fatal(err)
+	}
+	if u.Id != 1 || u.UserName != "yyds" || u.Age != 18 || u.Score != nil || u.Nick.Valid || !u.Active {
+		t.Fatalf("unexpected result %+v", u)
+	}
+	if !u.CreatedAt.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) || u.Profile["city"] != "sh" {
+		t.Fatalf("unexpected result %+v", u)
+	}
+
+	// 整数溢出时返回描述性的错误而不是 panic
+	err = assignRow([]string{"age"}, []any{int64(300)}, sc, reflect.ValueOf(u).Elem())
+	var convErr *ConvertError
+	if !errors.As(err, &convErr) || convErr.Column != "age" {
+		t.Fatalf("expected ConvertError, got %v", err)
+	}
+	// 小数不会被截断为整数
+	for _, v := range []any{1.9, -0.5, math.NaN(), 1e19} {
+		if err := assignRow([]string{"id"}, []any{v}, sc, reflect.ValueOf(u).Elem()); !errors.As(err, &convErr) {
+			t.Fatalf("%v: expected ConvertError, got %v", v, err)
+		}
+	}
+	if err := assignRow([]string{"id"}, []any{float64(42)}, sc, reflect.ValueOf(u).Elem()); err != nil || u.Id != 42 {
+		t.Fatalf("integral float: %d %v", u.Id, err)
+	}
+}
+
+func TestWhereConditions(t *testing.T) {
+	s := &FrameSession{}
+	s.Where("status", 1).
+		Where(Gt("age", 18)).
+		Where(In("id", []int64{1, 2, 3})).
+		Or().
+		Where(AndGroup(IsNull("deleted_at"), Not(Between("score", 10, 20)))).
+		Where(In("tag", []string{})).
+		OrderDesc("id")
+	want := " where status = ? and age > ? and id in (?,?,?) or (deleted_at is null and not (score between ? and ?)) and 1 = 0 order by id desc "
+	if got := s.conditionSQL(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	wantArgs := []any{1, 18, int64(1), int64(2), int64(3), 10, 20}
+	if !reflect.DeepEqual(s.whereValues, wantArgs) {
+		t.Fatalf("got %v, want %v", s.whereValues, wantArgs)
+	}
+}
+
+func TestConditionPrecedence(t *testing.T) {
+	for _, tt := range []struct {
+		s    *FrameSession
+		want string
+	}{
+		// 只有一个条件时不添加括号
+		{(&FrameSession{}).Where("a = ? or b = ?", 1, 2), " where a = ? or b = ?"},
+		// 原生SQL片段与其它条件连接时作为一个整体
+		{(&FrameSession{}).Where("a = ? or b = ?", 1, 2).Where("c", 3), " where (a = ? or b = ?) and c = ?"},
+		{(&FrameSession{}).Where("c", 3).Where("a = ? or b = ?", 1, 2), " where c = ? and (a = ? or b = ?)"},
+		{(&FrameSession{}).Where("a = ?", 1).Or().Where(Expr("b = ? and c = ?", 2, 3)), " where (a = ?) or (b = ? and c = ?)"},
+		{(&FrameSession{}).Where(Not(Expr("a = ? or b = ?", 1, 2))).Where("c", 3), " where (not (a = ? or b = ?)) and c = ?"},
+		{(&FrameSession{}).Where(OrGroup(Eq("a", 1), Eq("b", 2))).Where("c", 3), " where (a = ? or b = ?) and c = ?"},
+	} {
+		if got := tt.s.whereSQL(); got != tt.want {
+			t.Fatalf("got %q, want %q", got, tt.want)
+		}
+	}
+
+	// 软删除条件追加在整体之后
+	s := &FrameSession{db: &FrameDb{}, model: &softUser{}}
+	s.Where("a = ? or b = ?", 1, 2).Where("c", 3)
+	if got, want := s.whereSQL(), " where ((a = ? or b = ?) and c = ?) and deleted_at is null"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	s = &FrameSession{}
+	s.Group("a").Having("count(*) > ? or sum(b) > ?", 1, 2).Having(Gt("max(c)", 3))
+	if got, want := s.havingParam.String(), " having (count(*) > ? or sum(b) > ?) and max(c) > ?"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestLimitHavingAndCursor(t *testing.T) {
+	s := &FrameSession{}
+	s.Where("status", 1).Group("age").Having(Gt("count(*)", 1)).OrderAsc("age").Limit(10).Offset(20)
+	want := " where status = ? group by age having count(*) > ? order by age asc  limit 10 offset 20"
+	if got := s.conditionSQL(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if !reflect.DeepEqual(s.conditionValues(), []any{1, 1}) {
+		t.Fatalf("unexpected values %v", s.conditionValues())
+	}
+
+	str, err := EncodeCursor(&Cursor{Key: "id", Value: int64(9007199254740993), Desc: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := DecodeCursor(str)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Key != "id" || c.Value != int64(9007199254740993) || !c.Desc {
+		t.Fatalf("unexpected cursor %+v", c)
+	}
+	if _, err := DecodeCursor("not a cursor"); err == nil {
+		t.Fatal("expected error for invalid cursor")
+	}
+}
+
+func TestJoinSubQueryAndRebind(t *testing.T) {
+	sub := (&FrameSession{tableName: "order"}).Where("status", 2).SubQuery("user_id")
+	s := &FrameSession{tableName: "user"}
+	s.Alias("u").
+		LeftJoin("profile p", Expr("p.user_id = u.id and p.kind = ?", "main")).
+		Where(In("u.id", sub)).
+		Where("u.age > ?", 18)
+	query, args := s.SubQuery("u.id", "p.nick").Build()
+	want := "(select u.id,p.nick from user u left join profile p on p.user_id = u.id and p.kind = ? where u.id in (select user_id from order where status = ?) and (u.age > ?))"
+	if query != want {
+		t.Fatalf("got %q, want %q", query, want)
+	}
+	if !reflect.DeepEqual(args, []any{"main", 2, 18}) {
+		t.Fatalf("unexpected args %v", args)
+	}
+	got := rebind(postgresDialect{}, "select * from t where a = ? and b = '?' and c in (?,?)")
+	if got != "select * from t where a = $1 and b = '?' and c in ($2,$3)" {
+		t.Fatalf("unexpected rebind result %q", got)
+	}
+}
+
+type migrateUser struct {
+	Id        int64  `gorm:"id,auto_increment"`
+	UserName  string `gorm:"user_name,size:64,unique"`
+	Age       *int   `gorm:"age,index"`
+	CreatedAt time.Time
+}
+
+func TestCreateTableSQL(t *testing.T) {
+	sc, err := parseSchema(reflect.TypeOf(&migrateUser{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := createTableSQL(mysqlDialect{}, "user", sc)
+	want := "CREATE TABLE `user` (`id` bigint AUTO_INCREMENT NOT NULL, `user_name` varchar(64), `age` bigint, `created_at` datetime(3), PRIMARY KEY (`id`))"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	got = createTableSQL(postgresDialect{}, "user", sc)
+	want = `CREATE TABLE "user" ("id" bigserial NOT NULL, "user_name" varchar(64), "age" bigint, "created_at" timestamptz, PRIMARY KEY ("id"))`
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	indexes := createIndexSQL(mysqlDialect{}, "user", sc.Fields)
+	if len(indexes) != 2 || indexes[0] != "CREATE UNIQUE INDEX `idx_user_user_name` ON `user` (`user_name`)" {
+		t.Fatalf("unexpected indexes %v", indexes)
+	}
+	if Name("OrderGoodsId") != "Order_Goods_Id" {
+		t.Fatalf("unexpected name %s", Name("OrderGoodsId"))
+	}
+}
+
+type hookUser struct {
+	Id        int64 `gorm:"id,auto_increment"`
+	UserName  string
+	CreatedAt time.Time
+	UpdatedAt int64 `gorm:"updated_at,autoupdatetime"`
+}
+
+func (u *hookUser) BeforeInsert(s *FrameSession) error {
+	if u.UserName == "" {
+		return errors.New("user name is required")
+	}
+	return nil
+}
+
+func TestHooksAndTimestamps(t *testing.T) {
+	s := &FrameSession{db: &FrameDb{}}
+	if _, _, err := s.Insert(&hookUser{}); err == nil || err.Error() != "user name is required" {
+		t.Fatalf("expected hook error, got %v", err)
+	}
+
+	sc, err := parseSchema(reflect.TypeOf(&hookUser{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	u := &hookUser{UserName: "frame"}
+	setCreateTime(sc, reflect.ValueOf(u).Elem(), now)
+	if !u.CreatedAt.Equal(now) || u.UpdatedAt != now.Unix() {
+		t.Fatalf("timestamps not set: %+v", u)
+	}
+	created := now.Add(-time.Hour)
+	u.CreatedAt = created
+	setCreateTime(sc, reflect.ValueOf(u).Elem(), now.Add(time.Hour))
+	if !u.CreatedAt.Equal(created) {
+		t.Fatalf("created_at should be kept: %v", u.CreatedAt)
+	}
+
+	s = &FrameSession{db: &FrameDb{}, model: &hookUser{}}
+	s.UpdateParam("user_name", "frame")
+	s.touchUpdatedAt(now)
+	if got := s.updateParam.String(); got != "user_name = ? ,updated_at = ? " {
+		t.Fatalf("unexpected set clause %q", got)
+	}
+}
+
+type softUser struct {
+	Id        int64 `gorm:"id,auto_increment"`
+	UserName  string
+	DeletedAt *time.Time
+}
+
+func TestSoftDeleteScopes(t *testing.T) {
+	s := &FrameSession{db: &FrameDb{}, model: &softUser{}}
+	s.Where("id", 1).Or().Where("user_name", "frame")
+	if got, want := s.filterSQL(), " where (id = ? or user_name = ?) and deleted_at is null"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	s = &FrameSession{db: &FrameDb{}, model: &softUser{}, tableName: "soft_user"}
+	s.Join("order o", "o.user_id = soft_user.id").OnlyTrashed()
+	if got, want := s.filterSQL(), " where soft_user.deleted_at is not null"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	s = &FrameSession{db: &FrameDb{}, model: &softUser{}}
+	if got := s.WithTrashed().Where("id", 1).filterSQL(); got != " where id = ?" {
+		t.Fatalf("unexpected with trashed sql %q", got)
+	}
+	if s.Unscoped().softDeleteField() != nil {
+		t.Fatal("unscoped session should not soft delete")
+	}
+	sc, _ := parseSchema(reflect.TypeOf(&softUser{}))
+	if fields := insertFields(sc, reflect.ValueOf(&softUser{UserName: "frame"}).Elem()); len(fields) != 1 {
+		t.Fatalf("deleted_at should not be inserted: %d fields", len(fields))
+	}
+}
+
+func TestOnConflict(t *testing.T) {
+	s := &FrameSession{db: &FrameDb{dialect: mysqlDialect{}}, fieldName: []string{"user_name", "age"}}
+	s.OnConflict("user_name").DoUpdate()
+	suffix, returning := s.insertSuffix(&migrateUser{})
+	if want := " on duplicate key update age = values(age),id = last_insert_id(id)"; suffix != want || returning {
+		t.Fatalf("got %q, want %q", suffix, want)
+	}
+	s.db.dialect = postgresDialect{}
+	suffix, returning = s.insertSuffix(&migrateUser{})
+	if want := " on conflict (user_name) do update set age = excluded.age returning id"; suffix != want || !returning {
+		t.Fatalf("got %q, want %q", suffix, want)
+	}
+	s.DoNothing()
+	suffix, _ = s.insertSuffix(&migrateUser{})
+	if want := " on conflict (user_name) do nothing returning id"; suffix != want {
+		t.Fatalf("got %q, want %q", suffix, want)
+	}
+}
+
+func TestBatchChunks(t *testing.T) {
+	s := &FrameSession{db: &FrameDb{dialect: mysqlDialect{}}}
+	if got := s.chunkSize(3, 0); got != 21845 {
+		t.Fatalf("unexpected mysql chunk size %d", got)
+	}
+	s.db.dialect = sqliteDialect{}
+	if got := s.chunkSize(10, 0); got != 99 {
+		t.Fatalf("unexpected sqlite chunk size %d", got)
+	}
+	var progress []int
+	s.BatchSize(2).OnProgress(func(done, total int) { progress = append(progress, done) })
+	var chunks [][2]int
+	err := s.eachChunk(5, s.chunkSize(3, 0), func(lo, hi int) error {
+		chunks = append(chunks, [2]int{lo, hi})
+		return nil
+	})
+	if err != nil || len(chunks) != 3 || chunks[2] != [2]int{4, 5} || fmt.Sprint(progress) != "[2 4 5]" {
+		t.Fatalf("unexpected chunks %v progress %v err %v", chunks, progress, err)
+	}
+}
+
+type assocUser struct {
+	Id      int64
+	Profile *assocProfile
+	Orders  []assocOrder
+	Roles   []*assocRole `gorm:",many2many:user_role,joinforeignkey:user_id"`
+}
+
+type assocProfile struct {
+	Id          int64
+	AssocUserId int64
+}
+
+type assocOrder struct {
+	Id          int64
+	AssocUserId int64
+	User        *assocUser `gorm:",foreignkey:assoc_user_id"`
+}
+
+type assocRole struct {
+	Id int64
+}
+
+func TestAssociations(t *testing.T) {
+	sc, err := parseSchema(reflect.TypeOf(&assocUser{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sc.Fields) != 1 || len(sc.Relations) != 3 {
+		t.Fatalf("unexpected fields %d relations %d", len(sc.Fields), len(sc.Relations))
+	}
+	profile, _ := sc.Relation("Profile")
+	orders, _ := sc.Relation("Orders")
+	roles, _ := sc.Relation("Roles")
+	if profile.Kind != hasOne || profile.ForeignKey != "assoc_user_id" || orders.Kind != hasMany {
+		t.Fatalf("unexpected relations %+v %+v", profile, orders)
+	}
+	if roles.Kind != manyToMany || roles.JoinForeignKey != "user_id" || roles.JoinReferences != "assoc_role_id" {
+		t.Fatalf("unexpected many2many %+v", roles)
+	}
+	osc, _ := parseSchema(reflect.TypeOf(&assocOrder{}))
+	if user, _ := osc.Relation("User"); user.Kind != belongsTo {
+		t.Fatalf("unexpected belongs to %+v", user)
+	}
+
+	users := []reflect.Value{reflect.ValueOf(&assocUser{Id: 1}), reflect.ValueOf(&assocUser{Id: 2})}
+	related := []reflect.Value{
+		reflect.ValueOf(&assocOrder{Id: 10, AssocUserId: 1}),
+		reflect.ValueOf(&assocOrder{Id: 11, AssocUserId: 1}),
+		reflect.ValueOf(&assocOrder{Id: 12, AssocUserId: 2}),
+	}
+	fk, _ := osc.FieldByColumn("assoc_user_id")
+	byKey := groupByField(related, fk)
+	for _, u := range users {
+		setRelation(u.Elem(), orders, byKey[keyString(u.Elem().Field(0).Interface())])
+	}
+	if got := users[0].Interface().(*assocUser).Orders; len(got) != 2 || got[1].Id != 11 {
+		t.Fatalf("unexpected orders %+v", got)
+	}
+	if keys := fieldKeys(related, fk); len(keys) != 2 {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+}
+
+// assocPoint 不是表模型，没有设置关联选项时是普通的列。
+type assocPoint struct {
+	X, Y int
+}
+
+func (p assocPoint) Value() (driver.Value, error) {
+	return fmt.Sprintf("%d,%d", p.X, p.Y), nil
+}
+
+// assocTags 是实现了 sql.Scanner 的切片类型。
+type assocTags []struct{ Id int64 }
+
+func (t *assocTags) Scan(src any) error { return nil }
+
+type assocAddress struct {
+	City string
+}
+
+type assocShop struct {
+	Id       int64
+	Location assocPoint
+	Tags     assocTags
+	Address  assocAddress `gorm:"address,json"`
+	Backup   *assocAddress
+	Owner    *assocUser
+	Branches []assocAddress `gorm:",foreignkey:shop_id"`
+}
+
+func TestRelationDetection(t *testing.T) {
+	sc, err := parseSchema(reflect.TypeOf(&assocShop{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, column := range []string{"id", "location", "tags", "address", "backup"} {
+		if _, ok := sc.FieldByColumn(column); !ok {
+			t.Fatalf("struct column %s should be kept", column)
+		}
+	}
+	if len(sc.Fields) != 5 || len(sc.Relations) != 2 {
+		t.Fatalf("unexpected fields %d relations %d", len(sc.Fields), len(sc.Relations))
+	}
+	if owner, ok := sc.Relation("Owner"); !ok || owner.Kind != hasOne {
+		t.Fatalf("model field should be a relation: %+v", owner)
+	}
+	if branches, ok := sc.Relation("Branches"); !ok || branches.Kind != hasMany || branches.ForeignKey != "shop_id" {
+		t.Fatalf("field with a relation option should be a relation: %+v", branches)
+	}
+}
+
+func TestReplicaRouting(t *testing.T) {
+	primary, r1, r2 := &sql.DB{}, &sql.DB{}, &sql.DB{}
+	db := &FrameDb{db: primary}
+	if db.reader() != primary {
+		t.Fatal("reader should fall back to primary without replicas")
+	}
+	db.AddReplicaDB(r1)
+	db.AddReplicaDB(r2, 3)
+	if a, b := db.reader(), db.reader(); a == b || a == primary || b == primary {
+		t.Fatal("round robin should alternate replicas")
+	}
+	db.Replicas()[0].unhealthy.Store(true)
+	for i := 0; i < 3; i++ {
+		if db.reader() != r2 {
+			t.Fatal("unhealthy replica should be ejected")
+		}
+	}
+	db.SetBalancer(Weighted())
+	db.Replicas()[1].unhealthy.Store(true)
+	if db.reader() != primary {
+		t.Fatal("reader should fall back to primary when all replicas are unhealthy")
+	}
+}
+
+func TestLoadDbConfig(t *testing.T) {
+	if _, err := LoadDbConfig(nil); err == nil {
+		t.Fatal("expected error for missing config")
+	}
+	if _, err := LoadDbConfig(map[string]any{"max-open-conns": 1}); err == nil {
+		t.Fatal("expected error for missing dsn")
+	}
+	c, err := LoadDbConfig(map[string]any{
+		"dsn":               "root:root@tcp(localhost:3306)/blog",
+		"max-idle-conns":    int64(-1),
+		"max-open-conns":    int64(20),
+		"conn-max-lifetime": int64(3600),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.MaxIdleConns != -1 || c.MaxOpenConns != 20 || c.ConnMaxLifetime != time.Hour || c.ConnMaxIdleTime != time.Minute {
+		t.Fatalf("unexpected config %+v", c)
+	}
+}
+
+func TestDryRunAndQueryLog(t *testing.T) {
+	db := &FrameDb{dialect: mysqlDialect{}}
+	s := db.New(&migrateUser{}).DryRun()
+	if _, err := s.Where("id", 1).Delete(); err != nil {
+		t.Fatal(err)
+	}
+	s.New(&migrateUser{}).Where("age", 18).Select(&migrateUser{})
+	statements := s.Statements()
+	if len(statements) != 2 {
+		t.Fatalf("unexpected statements %v", statements)
+	}
+	if got, want := statements[0].String(), "delete from migrate_user  where id = 1"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	if got, want := interpolate("select * from user where name = ? and note = '?' and age > ?", []any{"o'neil", 18}, false),
+		"select * from user where name = 'o''neil' and note = '?' and age > 18"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	// 值为 nil 的 driver.Valuer 指针记录为 NULL
+	var events []*QueryEvent
+	db.SetQueryLogger(QueryLoggerFunc(func(e *QueryEvent) { events = append(events, e) }))
+	var name *sql.NullString
+	db.logQuery(time.Now(), "select ? , ?", []any{name, &sql.NullInt64{Int64: 7, Valid: true}}, -1, nil)
+	if len(events) != 1 || events[0].Interpolated() != "select NULL , 7" {
+		t.Fatalf("unexpected events %v", events)
+	}
+	events = nil
+	db.SetRedact(true)
+	db.SetLogLevel(LogWarn)
+	db.logQuery(time.Now(), "select ?", []any{1}, -1, nil)
+	db.logQuery(time.Now(), "select ?", []any{1}, -1, errors.New("bad"))
+	if len(events) != 1 || events[0].Interpolated() != "select ***" || events[0].Level() != LogError {
+		t.Fatalf("unexpected events %v", events)
+	}
+	db.SetSlowThreshold(time.Nanosecond)
+	db.logQuery(time.Now().Add(-time.Millisecond), "select 1", nil, -1, nil)
+	if len(events) != 2 || !events[1].Slow || events[1].Caller == "" {
+		t.Fatalf("slow query not logged: %v", events)
+	}
+}
+
+// TestQueryLogConcurrentUpdate 在查询记录日志的同时修改日志配置，使用 -race 运行时检查数据竞争。
+func TestQueryLogConcurrentUpdate(t *testing.T) {
+	db := &FrameDb{}
+	var logged atomic.Int64
+	db.SetQueryLogger(QueryLoggerFunc(func(e *QueryEvent) { logged.Add(1) }))
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			db.SetSlowThreshold(time.Duration(i))
+			db.SetRedact(i%2 == 0)
+			db.SetLogLevel(LogInfo)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			db.logQuery(time.Now(), "select ?", []any{i}, -1, nil)
+		}
+	}()
+	wg.Wait()
+	if logged.Load() != 1000 {
+		t.Fatalf("logged %d queries", logged.Load())
+	}
+}
+
+type lockOrder struct {
+	Id      int64
+	Status  int
+	Version int64 `gorm:"version,version"`
+}
+
+func TestOptimisticLock(t *testing.T) {
+	db := &FrameDb{dialect: mysqlDialect{}}
+	order := &lockOrder{Id: 1, Status: 2, Version: 3}
+	s := db.New(order).DryRun()
+	if _, _, err := s.Where("id", order.Id).Or().Where("status", 1).Update(order); err != nil {
+		t.Fatal(err)
+	}
+	s.New(order).Where("id", 1).Update("status", 3)
+	statements := s.Statements()
+	if got, want := statements[0].String(), "update lock_order set id = 1 ,status = 2 ,version = version + 1  where (id = 1 or status = 1) and version = 3"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got, want := statements[1].String(), "update lock_order set status = 3 ,version = version + 1  where id = 1"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	incrementVersion(&field{Index: 2}, reflect.ValueOf(order).Elem())
+	if order.Version != 4 {
+		t.Fatalf("unexpected version %d", order.Version)
+	}
+	type badLock struct {
+		Version string `gorm:"version,version"`
+	}
+	if _, err := parseSchema(reflect.TypeOf(badLock{})); err == nil {
+		t.Fatal("expected error for non-integer version field")
+	}
+}
+
+func TestScopesAndCache(t *testing.T) {
+	db := &FrameDb{dialect: mysqlDialect{}}
+	active := func(s *FrameSession) *FrameSession { return s.Where("status", 1) }
+	tenant := func(id int) func(*FrameSession) *FrameSession {
+		return func(s *FrameSession) *FrameSession { return s.Where("tenant_id", id) }
+	}
+	s := db.New(&lockOrder{}).Scopes(active, tenant(7))
+	if got, want := s.whereParam.String(), " where status = ? and tenant_id = ?"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	s.Cache(time.Minute)
+	_, key, ok := s.cacheKey("aggregate", "select count(*) from lock_order", nil)
+	if !ok {
+		t.Fatal("cache should be enabled")
+	}
+	db.InvalidateCache("lock_order")
+	if _, next, _ := s.cacheKey("aggregate", "select count(*) from lock_order", nil); next == key {
+		t.Fatal("invalidation should change the cache key")
+	}
+
+	c := NewLRUCache(2)
+	c.Set("a", 1, 0)
+	c.Set("b", 2, 0)
+	c.Get("a")
+	c.Set("c", 3, time.Nanosecond)
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("least recently used entry should be evicted")
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatal("recently used entry should be kept")
+	}
+	time.Sleep(time.Millisecond)
+	if _, ok := c.Get("c"); ok {
+		t.Fatal("expired entry should not be returned")
+	}
+
+	item := &lockOrder{Id: 1}
+	cloned := cloneItems([]any{item})[0].(*lockOrder)
+	cloned.Id = 2
+	if item.Id != 1 {
+		t.Fatal("cloned items should not share memory")
+	}
+
+	// 循环引用以及共享的指针在副本中保持相同的结构
+	a := &assocUser{Id: 1}
+	order := &assocOrder{Id: 10, AssocUserId: 1, User: a}
+	a.Orders = []assocOrder{*order}
+	copied := cloneItems([]any{a, order})
+	ca, corder := copied[0].(*assocUser), copied[1].(*assocOrder)
+	if corder.User != ca || ca == a || ca.Orders[0].User != ca {
+		t.Fatalf("unexpected clone %+v %+v", ca, corder)
+	}
+}
+
+type shardOrder struct {
+	Id     int64
+	UserId int64
+	Amount int
+}
+
+func TestSharding(t *testing.T) {
+	if n, _ := ModShard(4).Shard(int64(10)); n != 2 {
+		t.Fatalf("unexpected mod shard %d", n)
+	}
+	if a, _ := ModShard(4).Shard("alice"); a < 0 || a >= 4 {
+		t.Fatalf("unexpected hash shard %d", a)
+	}
+	r := RangeShard(100, 200)
+	for key, want := range map[int64]int{5: 0, 100: 1, 199: 1, 200: 2} {
+		if got, _ := r.Shard(key); got != want {
+			t.Fatalf("range shard of %d: got %d, want %d", key, got, want)
+		}
+	}
+
+	db := &FrameDb{dialect: mysqlDialect{}}
+	other := &FrameDb{dialect: mysqlDialect{}}
+	db.Shard(&shardOrder{}, ShardRule{Key: "user_id", Sharder: ModShard(2), DBs: []*FrameDb{db, other}})
+
+	s := db.New(&shardOrder{}).DryRun()
+	if _, err := s.Select(&shardOrder{}); !errors.Is(err, ErrShardKeyRequired) {
+		t.Fatalf("expected ErrShardKeyRequired, got %v", err)
+	}
+	s.New(&shardOrder{}).Where("user_id", 3).Select(&shardOrder{})
+	s.New(&shardOrder{}).Insert(&shardOrder{UserId: 4, Amount: 1})
+	s.New(&shardOrder{}).FanOut().Where("amount", 1).Delete()
+	var got []string
+	for _, st := range s.Statements() {
+		got = append(got, st.String())
+	}
+	want := []string{
+		"select * from shard_order_1  where user_id = 3",
+		"insert into shard_order_0 (user_id,amount) values (4,1)",
+		"delete from shard_order_0  where amount = 1",
+		"delete from shard_order_1  where amount = 1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	// 各分片的结果无法按排序合并，排序以及游标不能在所有分片上执行
+	if _, err := db.New(&shardOrder{}).FanOut().DryRun().OrderDesc("amount").Select(&shardOrder{}); !errors.Is(err, ErrFanOutOrder) {
+		t.Fatalf("expected ErrFanOutOrder, got %v", err)
+	}
+
+	or := db.New(&shardOrder{}).Where("user_id", 3).Or().Where("user_id", 4)
+	if _, err := or.shardTargets(nil); !errors.Is(err, ErrShardKeyRequired) {
+		t.Fatal("or conditions should not determine the shard")
+	}
+	targets, groups, err := db.New(&shardOrder{}).groupShards([]any{&shardOrder{UserId: 1}, &shardOrder{UserId: 2}, &shardOrder{UserId: 3}})
+	if err != nil || len(targets) != 2 || targets[0].db != other || len(groups[0]) != 2 {
+		t.Fatalf("unexpected groups %v %v %v", targets, groups, err)
+	}
+	if n, err := mergeAggregate("max", []int64{3, 7, 5}); err != nil || n != 7 {
+		t.Fatalf("unexpected merged max %d %v", n, err)
+	}
+}
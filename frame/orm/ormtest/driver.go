@@ -0,0 +1,126 @@
+//go:build cgo
+
+package ormtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"frame/orm"
+	"net/url"
+	"sync"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// DriverName 是测试驱动注册的名称。测试驱动包装了 SQLite 驱动并记录执行过的 SQL，
+// 使用 SQLite 方言，数据源名称（DSN）为 SQLite 的连接字符串。
+const DriverName = "ormtest"
+
+func init() {
+	sql.Register(DriverName, recordingDriver{})
+	orm.RegisterDialect(DriverName, orm.LookupDialect("sqlite3"))
+}
+
+// dsn 返回名称为 name 的 SQLite 内存数据库的连接字符串，名称相同的连接共享同一个内存数据库。
+func dsn(name string) string {
+	return "file:" + url.PathEscape(name) + "?mode=memory&cache=shared&_busy_timeout=5000"
+}
+
+// recorder 保存了一个数据库执行过的 SQL 以及参数。
+type recorder struct {
+	mu         sync.Mutex
+	statements []orm.Statement
+}
+
+// add 记录一条 SQL，参数为驱动转换后的值。
+func (r *recorder) add(query string, args []driver.NamedValue) {
+	recorded := make([]any, len(args))
+	for i, a := range args {
+		recorded[i] = a.Value
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statements = append(r.statements, orm.Statement{SQL: query, Args: recorded})
+}
+
+// recorders 保存了 DSN 到 recorder 的映射。
+var (
+	recordersMu sync.Mutex
+	recorders   = make(map[string]*recorder)
+)
+
+// recorderFor 返回 DSN 对应的 recorder，不存在时创建。
+func recorderFor(name string) *recorder {
+	recordersMu.Lock()
+	defer recordersMu.Unlock()
+	r, ok := recorders[name]
+	if !ok {
+		r = &recorder{}
+		recorders[name] = r
+	}
+	return r
+}
+
+// dropRecorder 删除 DSN 对应的 recorder。
+func dropRecorder(name string) {
+	recordersMu.Lock()
+	defer recordersMu.Unlock()
+	delete(recorders, name)
+}
+
+// recordingDriver 是记录 SQL 的 SQLite 驱动。
+type recordingDriver struct{}
+
+func (recordingDriver) Open(name string) (driver.Conn, error) {
+	c, err := (&sqlite3.SQLiteDriver{}).Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{SQLiteConn: c.(*sqlite3.SQLiteConn), rec: recorderFor(name)}, nil
+}
+
+// conn 是记录 SQL 的连接，事务等其它操作直接使用 SQLite 的连接。
+type conn struct {
+	*sqlite3.SQLiteConn
+	rec *recorder
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.rec.add(query, args)
+	return c.SQLiteConn.ExecContext(ctx, query, args)
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.rec.add(query, args)
+	return c.SQLiteConn.QueryContext(ctx, query, args)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	s, err := c.SQLiteConn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{SQLiteStmt: s.(*sqlite3.SQLiteStmt), query: query, rec: c.rec}, nil
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// stmt 是记录 SQL 的预编译语句。
+type stmt struct {
+	*sqlite3.SQLiteStmt
+	query string
+	rec   *recorder
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	s.rec.add(s.query, args)
+	return s.SQLiteStmt.ExecContext(ctx, args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	s.rec.add(s.query, args)
+	return s.SQLiteStmt.QueryContext(ctx, args)
+}
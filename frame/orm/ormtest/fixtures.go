@@ -0,0 +1,196 @@
+//go:build cgo
+
+package ormtest
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadFixtures 从 YAML 或 JSON 文件中加载固定数据，文件格式为表名到行列表的映射，例如：
+//
+//	user:
+//	  - id: 1
+//	    user_name: alice
+//	    age: 18
+//	  - id: 2
+//	    user_name: bob
+//
+// 表不存在时自动创建，列为所有行中出现过的列，按列名排序；行中没有 id 时分配自增主键。
+// 嵌套的对象和数组以 JSON 字符串保存，对应结构体中使用 json 选项的字段。
+func (db *DB) LoadFixtures(paths ...string) error {
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fixtures := make(map[string][]map[string]any)
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			err = yaml.Unmarshal(data, &fixtures)
+		case ".json":
+			decoder := json.NewDecoder(bytes.NewReader(data))
+			decoder.UseNumber()
+			err = decoder.Decode(&fixtures)
+		default:
+			return fmt.Errorf("ormtest: unsupported fixture file %s", path)
+		}
+		if err != nil {
+			return fmt.Errorf("ormtest: load fixture %s: %w", path, err)
+		}
+		if err := db.Insert(fixtures); err != nil {
+			return fmt.Errorf("ormtest: load fixture %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// Insert 将表名到行列表的映射写入内存数据库，规则与 LoadFixtures 相同，写入不会被记录到 Statements 中。
+// 新建的列的类型由第一个非空值决定，已存在的表只添加缺少的列。
+func (db *DB) Insert(fixtures map[string][]map[string]any) error {
+	// 按表名排序，保证自增主键的分配顺序稳定
+	names := make([]string, 0, len(fixtures))
+	for name := range fixtures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rows := make([]map[string]driver.Value, len(fixtures[name]))
+		types := make(map[string]string)
+		for i, r := range fixtures[name] {
+			rows[i] = make(map[string]driver.Value, len(r))
+			for c, v := range r {
+				value, err := fixtureValue(v)
+				if err != nil {
+					return fmt.Errorf("table %s column %s: %w", name, c, err)
+				}
+				c = strings.ToLower(c)
+				rows[i][c] = value
+				if types[c] == "" {
+					types[c] = columnType(value)
+				}
+			}
+		}
+		table := strings.ToLower(name)
+		if err := db.ensureTable(table, types); err != nil {
+			return fmt.Errorf("table %s: %w", table, err)
+		}
+		for _, r := range rows {
+			if err := db.insertRow(table, r); err != nil {
+				return fmt.Errorf("table %s: %w", table, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ensureTable 创建表或者为已存在的表添加缺少的列，新建的表使用自增主键 id。
+func (db *DB) ensureTable(table string, types map[string]string) error {
+	existing := make(map[string]bool)
+	rows, err := db.raw.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[strings.ToLower(column)] = true
+	}
+	rows.Close()
+	columns := make([]string, 0, len(types))
+	for c := range types {
+		if c != "id" && !existing[c] {
+			columns = append(columns, c)
+		}
+	}
+	sort.Strings(columns)
+	if len(existing) == 0 {
+		defs := []string{quote("id") + " INTEGER PRIMARY KEY AUTOINCREMENT"}
+		for _, c := range columns {
+			defs = append(defs, strings.TrimSpace(quote(c)+" "+types[c]))
+		}
+		_, err := db.raw.Exec(fmt.Sprintf("CREATE TABLE %s (%s)", quote(table), strings.Join(defs, ", ")))
+		return err
+	}
+	for _, c := range columns {
+		if _, err := db.raw.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", quote(table), strings.TrimSpace(quote(c)+" "+types[c]))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertRow 插入一行数据。
+func (db *DB) insertRow(table string, r map[string]driver.Value) error {
+	columns := make([]string, 0, len(r))
+	for c := range r {
+		columns = append(columns, c)
+	}
+	sort.Strings(columns)
+	quoted := make([]string, len(columns))
+	args := make([]any, len(columns))
+	for i, c := range columns {
+		quoted[i] = quote(c)
+		args[i] = r[c]
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quote(table), strings.Join(quoted, ", "),
+		strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", "))
+	if len(columns) == 0 {
+		query = fmt.Sprintf("INSERT INTO %s DEFAULT VALUES", quote(table))
+	}
+	_, err := db.raw.Exec(query, args...)
+	return err
+}
+
+// columnType 返回固定数据中的值对应的 SQLite 列类型，空值的列类型为空，由之后的非空值决定。
+func columnType(v driver.Value) string {
+	switch v.(type) {
+	case int64, bool:
+		return "INTEGER"
+	case float64:
+		return "REAL"
+	case string:
+		return "TEXT"
+	case time.Time:
+		return "DATETIME"
+	case []byte:
+		return "BLOB"
+	}
+	return ""
+}
+
+// fixtureValue 将 YAML、JSON 解析出的值转换为驱动使用的值。
+func fixtureValue(v any) (driver.Value, error) {
+	switch v := v.(type) {
+	case nil, string, bool, float64, int64, time.Time, []byte:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case uint64:
+		return int64(v), nil
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+		return v.Float64()
+	case map[string]any, []any:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
@@ -0,0 +1,229 @@
+//go:build cgo
+
+// Package ormtest 提供了测试 orm 以及使用 orm 的应用所需的工具，测试不需要连接真实的数据库：
+//
+//   - Open 返回一个使用内存数据库的 FrameDb，可以通过 AutoMigrate 或者固定数据（fixtures）建表；
+//   - LoadFixtures 从 YAML、JSON 文件中加载固定数据；
+//   - DryRun、AssertSQL、AssertStatements 用于断言会话生成的 SQL 以及参数。
+//
+// 例如：
+//
+//	func TestUserService(t *testing.T) {
+//		db := ormtest.Open(t, "testdata/users.yaml")
+//		user := &User{}
+//		if err := db.New(user).Where("id", 1).SelectOne(user); err != nil {
+//			t.Fatal(err)
+//		}
+//		ormtest.AssertSQL(t, db.Statements(), "select * from user where id = 1")
+//	}
+//
+// 内存数据库是 SQLite 的共享内存数据库，使用 SQLite 方言，支持 JOIN、GROUP BY、子查询、ON CONFLICT 以及
+// 事务和保存点，回滚只影响事务自己的连接。同一时间只有一个连接可以写入，事务进行中其它连接的写入
+// 返回 "database table is locked" 错误。各数据库之间的方言差异可以使用 DryRun 断言生成的 SQL。
+//
+// SQLite 驱动（github.com/mattn/go-sqlite3）依赖 cgo，ormtest 只在启用 cgo 时编译，
+// 使用 ormtest 的测试文件同样需要添加 //go:build cgo。
+package ormtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"frame/orm"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// DB 是使用内存数据库的 FrameDb，记录了执行过的 SQL。
+type DB struct {
+	*orm.FrameDb
+	name string
+	rec  *recorder
+	// raw 是不记录 SQL 的连接，用于加载固定数据和读取表中的数据
+	raw *sql.DB
+	// keep 持有一个连接直到 Close，SQLite 在最后一个连接关闭时删除共享内存数据库
+	keep *sql.Conn
+}
+
+// seq 用于生成内存数据库的名称，保证每次 Open 得到的数据库互相独立。
+var seq atomic.Int64
+
+// Open 打开一个新的内存数据库并加载 fixtures 中的固定数据，测试结束时自动关闭。
+// SQL 日志通过 t.Log 输出，只在测试失败或者使用 -v 运行时显示。
+func Open(t testing.TB, fixtures ...string) *DB {
+	t.Helper()
+	db := OpenDB(fmt.Sprintf("%s#%d", t.Name(), seq.Add(1)))
+	db.SetQueryLogger(orm.QueryLoggerFunc(func(e *orm.QueryEvent) {
+		if e.Err != nil {
+			t.Logf("%s | error: %v", e.Interpolated(), e.Err)
+			return
+		}
+		t.Log(e.Interpolated())
+	}))
+	t.Cleanup(func() {
+		db.Close()
+	})
+	if err := db.LoadFixtures(fixtures...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// OpenDB 打开名称为 name 的内存数据库，名称相同的 DB 共享数据，适用于在 TestMain 中准备数据。
+func OpenDB(name string) *DB {
+	source := dsn(name)
+	raw, err := sql.Open("sqlite3", source)
+	if err != nil {
+		panic(err)
+	}
+	keep, err := raw.Conn(context.Background())
+	if err != nil {
+		panic(err)
+	}
+	return &DB{
+		FrameDb: orm.Open(DriverName, source),
+		name:    source,
+		rec:     recorderFor(source),
+		raw:     raw,
+		keep:    keep,
+	}
+}
+
+// Close 关闭数据库连接，最后一个 DB 关闭后内存数据库中的数据被删除。
+func (db *DB) Close() error {
+	err := db.FrameDb.Close()
+	db.keep.Close()
+	db.raw.Close()
+	dropRecorder(db.name)
+	return err
+}
+
+// Statements 返回数据库执行过的 SQL 以及参数，参数为驱动转换后的值（例如 int 转换为 int64）。
+func (db *DB) Statements() []orm.Statement {
+	db.rec.mu.Lock()
+	defer db.rec.mu.Unlock()
+	return append([]orm.Statement(nil), db.rec.statements...)
+}
+
+// Reset 清空记录的 SQL，通常在准备数据之后、执行被测代码之前调用。
+func (db *DB) Reset() {
+	db.rec.mu.Lock()
+	defer db.rec.mu.Unlock()
+	db.rec.statements = nil
+}
+
+// Rows 返回表中的所有行（按插入顺序），用于断言写操作的结果，列名为小写，表不存在时返回 nil。
+// 读取使用独立的连接，只能看到已经提交的数据。
+func (db *DB) Rows(tableName string) []map[string]any {
+	rows, err := db.raw.Query("SELECT * FROM " + quote(tableName) + " ORDER BY rowid")
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil
+	}
+	result := []map[string]any{}
+	for rows.Next() {
+		values := make([]any, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil
+		}
+		r := make(map[string]any, len(columns))
+		for i, c := range columns {
+			r[strings.ToLower(c)] = values[i]
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// quote 使用双引号包裹 SQLite 的标识符。
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// DryRun 在试运行模式下执行 fn，返回会话生成的 SQL，SQL 不会被执行。
+func DryRun(s *orm.FrameSession, fn func(s *orm.FrameSession)) []orm.Statement {
+	s.DryRun()
+	fn(s)
+	return s.Statements()
+}
+
+// AssertSQL 断言 got 中依次是 want 中的 SQL，SQL 中的参数已经代入占位符，例如 "select * from user where id = 1"。
+// 比较时忽略多余的空白字符。
+func AssertSQL(t testing.TB, got []orm.Statement, want ...string) {
+	t.Helper()
+	actual := make([]string, len(got))
+	for i, st := range got {
+		actual[i] = normalize(st.String())
+	}
+	expected := make([]string, len(want))
+	for i, w := range want {
+		expected[i] = normalize(w)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("unexpected sql\n got: %s\nwant: %s", strings.Join(actual, "\n      "), strings.Join(expected, "\n      "))
+	}
+}
+
+// AssertStatements 断言 got 中依次是 want 中的 SQL 以及参数，SQL 使用 ? 占位符。
+// 参数按照 database/sql 的规则转换后比较（例如 int 与 int64 相等），SQL 比较时忽略多余的空白字符。
+func AssertStatements(t testing.TB, got []orm.Statement, want ...orm.Statement) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d statements, want %d\n got: %v\nwant: %v", len(got), len(want), got, want)
+	}
+	for i := range want {
+		if normalize(got[i].SQL) != normalize(want[i].SQL) {
+			t.Fatalf("statement %d: got sql %q, want %q", i, got[i].SQL, want[i].SQL)
+		}
+		gotArgs, err := driverValues(got[i].Args)
+		if err != nil {
+			t.Fatalf("statement %d: %v", i, err)
+		}
+		wantArgs, err := driverValues(want[i].Args)
+		if err != nil {
+			t.Fatalf("statement %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(gotArgs, wantArgs) {
+			t.Fatalf("statement %d: got args %v, want %v", i, got[i].Args, want[i].Args)
+		}
+	}
+}
+
+// normalize 将连续的空白字符替换为一个空格，并去掉括号、逗号两侧的空白。
+func normalize(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	for _, s := range []string{"(", ")", ","} {
+		query = strings.ReplaceAll(query, " "+s, s)
+		query = strings.ReplaceAll(query, s+" ", s)
+	}
+	return query
+}
+
+// driverValues 将参数转换为驱动使用的值。
+func driverValues(args []any) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		v, err := driver.DefaultParameterConverter.ConvertValue(a)
+		if err != nil {
+			if valuer, ok := a.(driver.Valuer); ok {
+				v, err = valuer.Value()
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		values[i] = v
+	}
+	return values, nil
+}
@@ -0,0 +1,162 @@
+//go:build cgo
+
+package ormtest
+
+import (
+	"context"
+	"errors"
+	"frame/orm"
+	"testing"
+	"time"
+)
+
+type testUser struct {
+	Id        int64
+	UserName  string
+	Age       int
+	Version   int64 `gorm:"version,version"`
+	DeletedAt *time.Time
+}
+
+type testOrder struct {
+	Id     int64
+	UserId int64
+	Amount float64
+	Items  []string `gorm:"items,json"`
+}
+
+func TestFixturesAndQueries(t *testing.T) {
+	db := Open(t, "testdata/users.yaml", "testdata/orders.json")
+
+	user := &testUser{}
+	if err := db.New(user).Where("user_name", "bob").SelectOne(user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Id != 2 || user.Age != 30 {
+		t.Fatalf("unexpected user %+v", user)
+	}
+	AssertSQL(t, db.Statements(), "select * from test_user where (user_name = 'bob') and deleted_at is null")
+
+	orders, err := db.New(&testOrder{}).Where(orm.Gt("amount", 10)).OrderDesc("amount").Limit(1).Select(&testOrder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].(*testOrder).UserId != 2 {
+		t.Fatalf("unexpected orders %v", orders)
+	}
+	first := &testOrder{}
+	if err := db.New(first).Where("user_id", 1).SelectOne(first); err != nil || len(first.Items) != 1 || first.Amount != 12.5 {
+		t.Fatalf("unexpected order %+v %v", first, err)
+	}
+	if n, err := db.New(&testOrder{}).Aggregate("sum", "user_id"); err != nil || n != 3 {
+		t.Fatalf("unexpected sum %d %v", n, err)
+	}
+
+	buyers, err := db.New(&testUser{}).Unscoped().Join("test_order o", "o.user_id = test_user.id").Where(orm.Gt("o.amount", 20)).Select(&testUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buyers) != 1 || buyers[0].(*testUser).UserName != "bob" {
+		t.Fatalf("unexpected join result %v", buyers)
+	}
+}
+
+func TestWritesAndTransactions(t *testing.T) {
+	db := Open(t)
+	if err := db.AutoMigrate(&testUser{}); err != nil {
+		t.Fatal(err)
+	}
+	db.Reset()
+
+	user := &testUser{UserName: "carol", Age: 20}
+	if _, _, err := db.New(user).Insert(user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Id != 1 {
+		t.Fatalf("unexpected id %d", user.Id)
+	}
+	AssertStatements(t, db.Statements(), orm.Statement{
+		SQL:  "insert into test_user (user_name,age,version) values (?,?,?)",
+		Args: []any{"carol", 20, 0},
+	})
+
+	user.Age = 21
+	if _, _, err := db.New(user).Where("id", user.Id).Update(user); err != nil {
+		t.Fatal(err)
+	}
+	stale := &testUser{Id: 1, UserName: "carol", Age: 22}
+	if _, _, err := db.New(stale).Where("id", 1).Update(stale); !errors.Is(err, orm.ErrStaleObject) {
+		t.Fatalf("expected ErrStaleObject, got %v", err)
+	}
+	if rows := db.Rows("test_user"); rows[0]["age"] != int64(21) || rows[0]["version"] != int64(1) {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+
+	err := db.New(&testUser{}).Transaction(context.Background(), func(tx *orm.FrameSession) error {
+		if _, err := tx.New(&testUser{}).Where("id", 1).Delete(); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	if err == nil {
+		t.Fatal("expected rollback error")
+	}
+	if n, err := db.New(&testUser{}).Count(); err != nil || n != 1 {
+		t.Fatalf("rollback should restore the row: %d %v", n, err)
+	}
+	if _, err := db.New(&testUser{}).Where("id", 1).Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := db.New(&testUser{}).Count(); n != 0 {
+		t.Fatal("soft deleted row should be excluded")
+	}
+	if n, _ := db.New(&testUser{}).Unscoped().Count(); n != 1 {
+		t.Fatal("soft deleted row should be kept")
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	db := Open(t)
+	statements := DryRun(db.New(&testUser{}), func(s *orm.FrameSession) {
+		s.Join("test_order o", "o.user_id = test_user.id").Where("o.amount", 10).Select(&testUser{})
+	})
+	AssertStatements(t, statements, orm.Statement{
+		SQL:  "select * from test_user inner join test_order o on o.user_id = test_user.id where (o.amount = ?) and test_user.deleted_at is null",
+		Args: []any{int64(10)},
+	})
+	if len(db.Statements()) != 0 {
+		t.Fatal("dry run should not execute sql")
+	}
+}
+
+func TestRollbackIsScopedToTransaction(t *testing.T) {
+	db := Open(t, "testdata/users.yaml", "testdata/orders.json")
+	err := db.New(&testUser{}).Transaction(context.Background(), func(tx *orm.FrameSession) error {
+		user := &testUser{UserName: "dave", Age: 40}
+		if _, _, err := tx.New(user).Insert(user); err != nil {
+			return err
+		}
+		// 其它连接可以读取事务没有写入的表，写入时返回错误而不是在回滚时丢失
+		if n, err := db.New(&testOrder{}).Count(); err != nil || n != 2 {
+			t.Errorf("read outside transaction: %d %v", n, err)
+		}
+		order := &testOrder{UserId: 1, Amount: 5}
+		if _, _, err := db.New(order).Insert(order); err == nil {
+			t.Error("concurrent write should fail while the transaction holds the write lock")
+		}
+		return errors.New("rollback")
+	})
+	if err == nil {
+		t.Fatal("expected rollback error")
+	}
+	if rows := db.Rows("test_user"); len(rows) != 2 {
+		t.Fatalf("rollback should discard the insert: %v", rows)
+	}
+	order := &testOrder{UserId: 1, Amount: 5}
+	if _, _, err := db.New(order).Insert(order); err != nil {
+		t.Fatal(err)
+	}
+	if rows := db.Rows("test_order"); len(rows) != 3 {
+		t.Fatalf("unexpected orders %v", rows)
+	}
+}
@@ -0,0 +1,220 @@
+package orm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// PageResult 是分页查询的结果。
+type PageResult struct {
+	// Items 是当前页的数据。
+	Items []any `json:"items"`
+	// Total 是满足条件的总记录数。
+	Total int64 `json:"total"`
+	// Page 是当前页码，从 1 开始。
+	Page int `json:"page"`
+	// Size 是每页的记录数。
+	Size int `json:"size"`
+}
+
+// Pages 返回总页数。
+func (p *PageResult) Pages() int64 {
+	if p.Size <= 0 {
+		return 0
+	}
+	return (p.Total + int64(p.Size) - 1) / int64(p.Size)
+}
+
+// Page 执行分页查询，返回第 page 页（从 1 开始）的 size 条数据以及满足条件的总记录数。
+// 参数 data 和 fields 的含义与 Select 相同。
+func (s *FrameSession) Page(data any, page, size int, fields ...string) (*PageResult, error) {
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		return nil, errors.New("page size must be greater than 0")
+	}
+	// 先统计总数，统计查询不受排序和分页的影响
+	total, err := s.total()
+	if err != nil {
+		return nil, err
+	}
+	result := &PageResult{Items: make([]any, 0), Total: total, Page: page, Size: size}
+	// 总数不足时不需要再查询当前页
+	if int64((page-1)*size) >= total {
+		return result, nil
+	}
+	items, err := s.Limit(size).Offset((page-1)*size).Select(data, fields...)
+	if err != nil {
+		return nil, err
+	}
+	result.Items = items
+	return result, nil
+}
+
+// total 统计满足条件的总记录数，存在分组条件时统计分组的数量。
+// 分片表与 Count 相同在对应的分片上统计，在所有分片上执行时累加各分片的分组数量，
+// 同一个分组的记录分布在多个分片时按分片分别计数，与 FanOut 的 Select 返回的分组一致。
+func (s *FrameSession) total() (int64, error) {
+	if s.groupParam.Len() == 0 {
+		return s.Count()
+	}
+	var total int64
+	err := s.onShards(nil, func() error {
+		n, err := s.groupTotal()
+		total += n
+		return err
+	})
+	return total, err
+}
+
+// groupTotal 统计当前表中分组的数量。
+func (s *FrameSession) groupTotal() (int64, error) {
+	query := fmt.Sprintf("select count(*) from (select 1 from %s %s) t", s.tableSQL(), s.filterSQL())
+	rows, err := s.query(true, query, s.queryValues()...)
+	if err != nil || rows == nil {
+		return 0, err
+	}
+	defer rows.Close()
+	var total int64
+	if rows.Next() {
+		if err := rows.Scan(&total); err != nil {
+			return 0, err
+		}
+	}
+	return total, rows.Err()
+}
+
+// Cursor 是键集分页的游标，记录了上一页最后一条记录的排序键。
+// 游标会被编码为不透明的字符串返回给调用方，可直接用于 API 响应。
+type Cursor struct {
+	// Key 是排序键对应的列名。
+	Key string `json:"k"`
+	// Value 是上一页最后一条记录的排序键的值。
+	Value any `json:"v"`
+	// Desc 表示是否按照排序键降序排列。
+	Desc bool `json:"d,omitempty"`
+}
+
+// EncodeCursor 将游标编码为 URL 安全的字符串。
+func EncodeCursor(c *Cursor) (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeCursor 将字符串解码为游标。
+func DecodeCursor(str string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(b)))
+	decoder.UseNumber()
+	c := &Cursor{}
+	if err := decoder.Decode(c); err != nil || c.Key == "" {
+		return nil, errors.New("invalid cursor")
+	}
+	// 数字优先还原为整数，避免大整数主键丢失精度
+	if n, ok := c.Value.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			c.Value = i
+		} else if f, err := n.Float64(); err == nil {
+			c.Value = f
+		}
+	}
+	return c, nil
+}
+
+// CursorResult 是键集分页查询的结果。
+type CursorResult struct {
+	// Items 是当前页的数据。
+	Items []any `json:"items"`
+	// NextCursor 是获取下一页时传给 After 的游标，没有更多数据时为空。
+	NextCursor string `json:"next_cursor"`
+	// HasMore 表示是否还有下一页。
+	HasMore bool `json:"has_more"`
+}
+
+// After 设置键集分页的游标，游标为空字符串时从第一页开始查询。
+// 游标由上一次 SelectCursor 返回的 NextCursor 提供。
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) After(cursor string) *FrameSession {
+	s.cursor = cursor
+	return s
+}
+
+// SelectCursor 执行键集分页查询，按照列 key 排序返回最多 size 条数据。
+// key 必须是唯一且有序的列（通常为自增主键），desc 表示是否降序排列。
+// 与 LIMIT/OFFSET 不同，键集分页的查询代价不随页码增加而增加。
+func (s *FrameSession) SelectCursor(data any, key string, desc bool, size int, fields ...string) (*CursorResult, error) {
+	if size < 1 {
+		return nil, errors.New("page size must be greater than 0")
+	}
+	sc, err := parseSchema(reflect.TypeOf(data))
+	if err != nil {
+		return nil, err
+	}
+	f, ok := sc.FieldByColumn(key)
+	if !ok {
+		return nil, fmt.Errorf("cursor key %s not found in %s", key, sc.Type.Name())
+	}
+
+	if s.cursor != "" {
+		c, err := DecodeCursor(s.cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.Key != key || c.Desc != desc {
+			return nil, errors.New("cursor does not match the query")
+		}
+		// 已有条件使用括号包裹，避免 or 条件与游标条件的优先级问题
+		s.wrapWhere()
+		if desc {
+			s.addCondition(Lt(key, c.Value))
+		} else {
+			s.addCondition(Gt(key, c.Value))
+		}
+	}
+	if desc {
+		s.OrderDesc(key)
+	} else {
+		s.OrderAsc(key)
+	}
+
+	// 多查询一条数据用于判断是否还有下一页
+	items, err := s.Limit(size+1).Select(data, fields...)
+	if err != nil {
+		return nil, err
+	}
+	result := &CursorResult{Items: items}
+	if len(items) > size {
+		result.Items = items[:size]
+		result.HasMore = true
+		last := reflect.ValueOf(result.Items[size-1]).Elem().Field(f.Index).Interface()
+		result.NextCursor, err = EncodeCursor(&Cursor{Key: key, Value: last, Desc: desc})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// wrapWhere 将已有的WHERE条件使用括号包裹为一个整体。
+func (s *FrameSession) wrapWhere() {
+	if s.whereParam.Len() == 0 {
+		return
+	}
+	conds := strings.TrimPrefix(s.whereParam.String(), " where ")
+	s.whereParam.Reset()
+	s.whereParam.WriteString(" where (")
+	s.whereParam.WriteString(conds)
+	s.whereParam.WriteString(")")
+	s.whereWrap = false
+}
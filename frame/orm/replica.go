@@ -0,0 +1,224 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Replica 是一个只读副本（从库）。
+type Replica struct {
+	// DB 是副本的数据库连接。
+	DB *sql.DB
+	// Weight 是副本的权重，供加权负载均衡使用，小于 1 时按 1 计算。
+	Weight int
+	// unhealthy 表示副本的健康检查失败，失败的副本不会被选中。
+	unhealthy atomic.Bool
+}
+
+// Healthy 返回副本最近一次健康检查是否成功。
+func (r *Replica) Healthy() bool {
+	return !r.unhealthy.Load()
+}
+
+// Balancer 是副本的负载均衡策略，从健康的副本中选择一个执行查询。
+// replicas 不为空，实现需要保证并发安全。
+type Balancer interface {
+	Pick(replicas []*Replica) *Replica
+}
+
+// RoundRobin 返回轮询的负载均衡策略。
+func RoundRobin() Balancer {
+	return &roundRobin{}
+}
+
+type roundRobin struct {
+	next atomic.Uint64
+}
+
+func (b *roundRobin) Pick(replicas []*Replica) *Replica {
+	return replicas[(b.next.Add(1)-1)%uint64(len(replicas))]
+}
+
+// Random 返回随机的负载均衡策略。
+func Random() Balancer {
+	return randomBalancer{}
+}
+
+type randomBalancer struct{}
+
+func (randomBalancer) Pick(replicas []*Replica) *Replica {
+	return replicas[rand.Intn(len(replicas))]
+}
+
+// Weighted 返回按权重随机的负载均衡策略，副本被选中的概率与 Weight 成正比。
+func Weighted() Balancer {
+	return weightedBalancer{}
+}
+
+type weightedBalancer struct{}
+
+func (weightedBalancer) Pick(replicas []*Replica) *Replica {
+	total := 0
+	for _, r := range replicas {
+		total += replicaWeight(r)
+	}
+	n := rand.Intn(total)
+	for _, r := range replicas {
+		if n -= replicaWeight(r); n < 0 {
+			return r
+		}
+	}
+	return replicas[len(replicas)-1]
+}
+
+// replicaWeight 返回副本的有效权重。
+func replicaWeight(r *Replica) int {
+	if r.Weight < 1 {
+		return 1
+	}
+	return r.Weight
+}
+
+// replicaSet 保存了 FrameDb 的所有副本以及负载均衡策略。
+type replicaSet struct {
+	mu       sync.RWMutex
+	replicas []*Replica
+	balancer Balancer
+	// stop 用于停止健康检查。
+	stop context.CancelFunc
+}
+
+// AddReplica 使用与主库相同的驱动打开一个只读副本，weight 是副本的权重（默认为 1）。
+// 添加副本后 Select、SelectOne、Count、Aggregate 等查询会按负载均衡策略路由到健康的副本，
+// 写操作、事务中的查询以及调用了 UsePrimary 的会话仍然使用主库。
+func (db *FrameDb) AddReplica(source string, weight ...int) error {
+	rdb, err := sql.Open(db.driverName, source)
+	if err != nil {
+		return err
+	}
+	if err := rdb.Ping(); err != nil {
+		rdb.Close()
+		return err
+	}
+	db.AddReplicaDB(rdb, weight...)
+	return nil
+}
+
+// AddReplicaDB 添加一个已经打开的只读副本，weight 是副本的权重（默认为 1）。
+func (db *FrameDb) AddReplicaDB(rdb *sql.DB, weight ...int) {
+	r := &Replica{DB: rdb, Weight: 1}
+	if len(weight) > 0 {
+		r.Weight = weight[0]
+	}
+	db.replicas.mu.Lock()
+	defer db.replicas.mu.Unlock()
+	db.replicas.replicas = append(db.replicas.replicas, r)
+}
+
+// Replicas 返回所有副本。
+func (db *FrameDb) Replicas() []*Replica {
+	db.replicas.mu.RLock()
+	defer db.replicas.mu.RUnlock()
+	return append([]*Replica(nil), db.replicas.replicas...)
+}
+
+// SetBalancer 设置副本的负载均衡策略，默认为轮询。
+func (db *FrameDb) SetBalancer(b Balancer) {
+	db.replicas.mu.Lock()
+	defer db.replicas.mu.Unlock()
+	db.replicas.balancer = b
+}
+
+// StartHealthCheck 启动副本的健康检查，每隔 interval 对所有副本执行一次 Ping，
+// 失败的副本会被剔除，恢复后重新加入负载均衡。重复调用会替换之前的健康检查，Close 时停止。
+func (db *FrameDb) StartHealthCheck(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	db.replicas.mu.Lock()
+	if db.replicas.stop != nil {
+		db.replicas.stop()
+	}
+	db.replicas.stop = cancel
+	db.replicas.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				db.checkReplicas(ctx, interval)
+			}
+		}
+	}()
+}
+
+// checkReplicas 对所有副本执行一次 Ping 并更新健康状态。
+func (db *FrameDb) checkReplicas(ctx context.Context, timeout time.Duration) {
+	for _, r := range db.Replicas() {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := r.DB.PingContext(pingCtx)
+		cancel()
+		if healthy := err == nil; healthy != r.Healthy() {
+			r.unhealthy.Store(!healthy)
+			if healthy {
+				db.logger.Info("orm: replica recovered")
+			} else {
+				db.logger.Error("orm: replica ejected: " + err.Error())
+			}
+		}
+	}
+}
+
+// reader 返回执行只读查询使用的连接，没有健康的副本时返回主库。
+func (db *FrameDb) reader() *sql.DB {
+	db.replicas.mu.RLock()
+	defer db.replicas.mu.RUnlock()
+	healthy := make([]*Replica, 0, len(db.replicas.replicas))
+	for _, r := range db.replicas.replicas {
+		if r.Healthy() {
+			healthy = append(healthy, r)
+		}
+	}
+	if len(healthy) == 0 {
+		return db.db
+	}
+	b := db.replicas.balancer
+	if b == nil {
+		b = defaultBalancer
+	}
+	return b.Pick(healthy).DB
+}
+
+// defaultBalancer 是未设置负载均衡策略时使用的轮询策略。
+var defaultBalancer = RoundRobin()
+
+// closeReplicas 停止健康检查并关闭所有副本。
+func (db *FrameDb) closeReplicas() error {
+	db.replicas.mu.Lock()
+	defer db.replicas.mu.Unlock()
+	if db.replicas.stop != nil {
+		db.replicas.stop()
+		db.replicas.stop = nil
+	}
+	var firstErr error
+	for _, r := range db.replicas.replicas {
+		if err := r.DB.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	db.replicas.replicas = nil
+	return firstErr
+}
+
+// UsePrimary 强制当前会话的查询使用主库，适用于写入后需要立即读取的场景。
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) UsePrimary() *FrameSession {
+	s.usePrimary = true
+	return s
+}
@@ -0,0 +1,172 @@
+package orm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// field 描述了结构体字段与数据库列之间的映射关系。
+// 它由结构体字段的 gorm 标签解析而来，标签格式为 `gorm:"列名,选项1,选项2:值"`。
+type field struct {
+	// Name 是结构体中的字段名。
+	Name string
+	// Column 是字段对应的数据库列名。
+	Column string
+	// Index 是字段在结构体中的下标，用于通过反射快速定位字段。
+	Index int
+	// Type 是字段的反射类型。
+	Type reflect.Type
+	// AutoIncrement 表示该字段是否为自增字段。
+	AutoIncrement bool
+	// PrimaryKey 表示该字段是否为主键，使用 primary_key 选项标记，未标记时 id 列视为主键。
+	PrimaryKey bool
+	// JSON 表示该字段以 JSON 格式存储在数据库中。
+	JSON bool
+	// Options 保存了标签中除列名以外的所有选项。
+	Options map[string]string
+}
+
+// schema 描述了一个结构体类型对应的数据库表结构。
+type schema struct {
+	// Type 是结构体的反射类型。
+	Type reflect.Type
+	// Fields 是结构体中参与数据库映射的字段列表，保持结构体中的声明顺序。
+	Fields []*field
+	// columns 是列名到字段的映射，用于查询结果的快速匹配。
+	columns map[string]*field
+	// CreatedAt 和 UpdatedAt 是自动维护创建时间和更新时间的字段，
+	// 通过字段名 CreatedAt、UpdatedAt 或者 autocreatetime、autoupdatetime 选项识别，不存在时为 nil。
+	CreatedAt *field
+	UpdatedAt *field
+	// DeletedAt 是软删除字段，通过字段名 DeletedAt 或者 softdelete 选项识别，不存在时为 nil。
+	DeletedAt *field
+	// Version 是乐观锁的版本号字段，通过 version 选项识别，不存在时为 nil。
+	Version *field
+	// Relations 是结构体中的关联字段，它们不对应数据库列。
+	Relations []*relation
+	// relations 是字段名到关联的映射。
+	relations map[string]*relation
+}
+
+// schemaCache 缓存已经解析过的结构体表结构，避免每次操作都重复反射解析标签。
+var schemaCache sync.Map
+
+// parseSchema 解析结构体类型的表结构，并将结果缓存起来。
+// 参数 t 可以是结构体类型或指向结构体的指针类型。
+func parseSchema(t reflect.Type) (*schema, error) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, errors.New("data must be pointer to struct")
+	}
+	if v, ok := schemaCache.Load(t); ok {
+		return v.(*schema), nil
+	}
+
+	sc := &schema{
+		Type:      t,
+		columns:   make(map[string]*field),
+		relations: make(map[string]*relation),
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		// 跳过未导出的字段，反射无法对其赋值
+		if !sf.IsExported() {
+			continue
+		}
+		tag := sf.Tag.Get("gorm")
+		// 使用 "-" 标记的字段不参与数据库映射
+		if tag == "-" {
+			continue
+		}
+		f := &field{
+			Name:    sf.Name,
+			Index:   i,
+			Type:    sf.Type,
+			Options: make(map[string]string),
+		}
+		parts := strings.Split(tag, ",")
+		f.Column = strings.TrimSpace(parts[0])
+		if f.Column == "" {
+			f.Column = strings.ToLower(Name(sf.Name))
+		}
+		for _, opt := range parts[1:] {
+			opt = strings.TrimSpace(opt)
+			if opt == "" {
+				continue
+			}
+			key, value, _ := strings.Cut(opt, ":")
+			f.Options[strings.ToLower(key)] = value
+		}
+		// 兼容 `gorm:"auto_increment"` 这种只写了选项的写法
+		if f.Column == "auto_increment" {
+			f.Column = strings.ToLower(Name(sf.Name))
+			f.Options["auto_increment"] = ""
+		}
+		_, f.AutoIncrement = f.Options["auto_increment"]
+		_, f.JSON = f.Options["json"]
+		_, f.PrimaryKey = f.Options["primary_key"]
+
+		// 关联字段（表模型或者设置了关联选项的结构体及其切片）不参与列的映射
+		if elem, many, ok := relationElem(sf.Type, f.Options); ok && !f.JSON && !sf.Anonymous {
+			rel := &relation{Name: sf.Name, Index: i, Type: sf.Type, Elem: elem, many: many, Options: f.Options}
+			sc.Relations = append(sc.Relations, rel)
+			sc.relations[sf.Name] = rel
+			continue
+		}
+
+		sc.Fields = append(sc.Fields, f)
+		sc.columns[f.Column] = f
+
+		if _, ok := f.Options["autocreatetime"]; ok || (sf.Name == "CreatedAt" && sc.CreatedAt == nil) {
+			sc.CreatedAt = f
+		}
+		if _, ok := f.Options["autoupdatetime"]; ok || (sf.Name == "UpdatedAt" && sc.UpdatedAt == nil) {
+			sc.UpdatedAt = f
+		}
+		if _, ok := f.Options["softdelete"]; ok || (sf.Name == "DeletedAt" && sc.DeletedAt == nil) {
+			sc.DeletedAt = f
+		}
+		if _, ok := f.Options["version"]; ok {
+			if !isIntField(f) {
+				return nil, fmt.Errorf("version field %s must be integer", sf.Name)
+			}
+			sc.Version = f
+		}
+	}
+
+	// 没有显式标记主键时，id 列作为主键
+	if sc.PrimaryKey() == nil {
+		if f, ok := sc.columns["id"]; ok {
+			f.PrimaryKey = true
+		}
+	}
+
+	// 关联的外键需要根据当前结构体的列推导，在所有字段解析完成后处理
+	for _, rel := range sc.Relations {
+		rel.resolve(sc)
+	}
+
+	v, _ := schemaCache.LoadOrStore(t, sc)
+	return v.(*schema), nil
+}
+
+// FieldByColumn 根据列名查找对应的字段。
+func (sc *schema) FieldByColumn(column string) (*field, bool) {
+	f, ok := sc.columns[column]
+	return f, ok
+}
+
+// PrimaryKey 返回表的主键字段，没有主键时返回 nil。
+func (sc *schema) PrimaryKey() *field {
+	for _, f := range sc.Fields {
+		if f.PrimaryKey {
+			return f
+		}
+	}
+	return nil
+}
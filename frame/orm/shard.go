@@ -0,0 +1,332 @@
+package orm
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// ErrShardKeyRequired 表示对分片表的操作无法确定分片：条件中没有分片键的等值条件，也没有调用 ShardKey。
+// 需要在所有分片上执行时调用 FanOut。
+var ErrShardKeyRequired = errors.New("orm: shard key required")
+
+// ErrFanOutOrder 表示 FanOut 的操作使用了排序或者游标分页。
+// 各分片的结果按分片的顺序合并，无法保证全局的排序，需要指定分片键后再查询。
+var ErrFanOutOrder = errors.New("orm: order is not supported across shards")
+
+// Sharder 是分片策略，根据分片键的值计算分片的序号。
+type Sharder interface {
+	// Shards 返回分片的数量。
+	Shards() int
+	// Shard 返回分片键的值所在的分片，范围为 [0, Shards())。
+	Shard(key any) (int, error)
+}
+
+// ModShard 返回按取模分片的策略：整数直接对 n 取模，其它类型对 FNV-1a 哈希值取模。
+func ModShard(n int) Sharder {
+	if n < 1 {
+		panic("shard count must be positive")
+	}
+	return modSharder(n)
+}
+
+type modSharder int
+
+func (m modSharder) Shards() int { return int(m) }
+
+func (m modSharder) Shard(key any) (int, error) {
+	v := reflect.Indirect(reflect.ValueOf(key))
+	switch {
+	case !v.IsValid():
+		return 0, errors.New("shard key is nil")
+	case v.CanInt():
+		n := v.Int() % int64(m)
+		if n < 0 {
+			n = -n
+		}
+		return int(n), nil
+	case v.CanUint():
+		return int(v.Uint() % uint64(m)), nil
+	}
+	h := fnv.New32a()
+	if b, ok := v.Interface().([]byte); ok {
+		h.Write(b)
+	} else {
+		fmt.Fprint(h, v.Interface())
+	}
+	return int(h.Sum32() % uint32(m)), nil
+}
+
+// RangeShard 返回按范围分片的策略，bounds 是升序排列的分界值：
+// 小于 bounds[0] 的键在第 0 个分片，[bounds[i-1], bounds[i]) 在第 i 个分片，
+// 不小于最后一个分界值的键在最后一个分片，共 len(bounds)+1 个分片。分片键必须是整数。
+func RangeShard(bounds ...int64) Sharder {
+	if !sort.SliceIsSorted(bounds, func(i, j int) bool { return bounds[i] < bounds[j] }) {
+		panic("shard bounds must be sorted")
+	}
+	return rangeSharder(bounds)
+}
+
+type rangeSharder []int64
+
+func (r rangeSharder) Shards() int { return len(r) + 1 }
+
+func (r rangeSharder) Shard(key any) (int, error) {
+	v := reflect.Indirect(reflect.ValueOf(key))
+	var n int64
+	switch {
+	case v.IsValid() && v.CanInt():
+		n = v.Int()
+	case v.IsValid() && v.CanUint():
+		n = int64(v.Uint())
+	default:
+		return 0, fmt.Errorf("range shard key must be integer, got %T", key)
+	}
+	return sort.Search(len(r), func(i int) bool { return r[i] > n }), nil
+}
+
+// ShardRule 是一张表的分片规则。
+type ShardRule struct {
+	// Key 是分片键的列名。
+	Key string
+	// Sharder 是分片策略。
+	Sharder Sharder
+	// Table 返回第 shard 个分片的表名，为 nil 时为 表名_序号，例如 order_0。
+	Table func(table string, shard int) string
+	// DBs 是分片所在的数据库，第 i 个分片使用 DBs[i%len(DBs)]，为空时所有分片都在当前 FrameDb 中。
+	DBs []*FrameDb
+}
+
+// tableName 返回第 shard 个分片的表名。
+func (r *ShardRule) tableName(table string, shard int) string {
+	if r.Table != nil {
+		return r.Table(table, shard)
+	}
+	return fmt.Sprintf("%s_%d", table, shard)
+}
+
+// db 返回第 shard 个分片所在的数据库。
+func (r *ShardRule) db(def *FrameDb, shard int) *FrameDb {
+	if len(r.DBs) == 0 {
+		return def
+	}
+	return r.DBs[shard%len(r.DBs)]
+}
+
+// shardRules 保存了 FrameDb 中表名到分片规则的映射。
+type shardRules struct {
+	mu    sync.RWMutex
+	rules map[string]*ShardRule
+}
+
+// Shard 为 data 对应的表设置分片规则。之后通过 New 创建的会话在 Insert、InsertBatch、Select、SelectOne、
+// Count、Aggregate、Update、Delete 时会把表名改写为分片的表名，并在分片所在的数据库中执行：
+//
+//	db.Shard(&Order{}, orm.ShardRule{Key: "user_id", Sharder: orm.ModShard(16)})
+//	db.New(&Order{}).Where("user_id", uid).Select(&Order{}) // 查询 order_<uid%16>
+//
+// 分片键从 Insert、Update 传入的结构体，或者 Where 中分片键的等值条件中获取，也可以通过 ShardKey 指定；
+// 无法确定分片时返回 ErrShardKeyRequired，除非调用了 FanOut。
+// 参数 data 必须是指针类型，规则无效时抛出 panic。
+func (db *FrameDb) Shard(data any, rule ShardRule) {
+	if rule.Key == "" || rule.Sharder == nil {
+		panic("shard rule requires key and sharder")
+	}
+	table := db.New(data).tableName
+	db.shards.mu.Lock()
+	defer db.shards.mu.Unlock()
+	if db.shards.rules == nil {
+		db.shards.rules = make(map[string]*ShardRule)
+	}
+	db.shards.rules[table] = &rule
+}
+
+// shardRule 返回表的分片规则，没有时返回 nil。
+func (db *FrameDb) shardRule(table string) *ShardRule {
+	db.shards.mu.RLock()
+	defer db.shards.mu.RUnlock()
+	return db.shards.rules[table]
+}
+
+// ShardKey 指定分片键的值，用于条件中没有分片键的等值条件的场景。
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) ShardKey(value any) *FrameSession {
+	s.shardKey = value
+	s.hasShardKey = true
+	return s
+}
+
+// FanOut 允许在没有分片键时在所有分片上执行：Select 合并各分片的结果，Count、Aggregate 合并聚合值，
+// Update、Delete 累加受影响的行数，SelectOne 返回第一个查询到的记录。
+// 合并后的结果按分片的顺序排列；不支持 offset，使用排序或者游标分页时返回 ErrFanOutOrder。
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) FanOut() *FrameSession {
+	s.fanOut = true
+	return s
+}
+
+// shardTarget 是一个分片的表名和数据库。
+type shardTarget struct {
+	table string
+	db    *FrameDb
+}
+
+// shardTargets 返回操作需要执行的分片，表没有分片规则时返回 nil。
+// data 是 Insert、Update 传入的结构体，用于读取分片键的值。
+func (s *FrameSession) shardTargets(data any) ([]shardTarget, error) {
+	rule := s.db.shardRule(s.tableName)
+	if rule == nil {
+		return nil, nil
+	}
+	key, ok := s.shardKeyValue(rule, data)
+	if ok {
+		shard, err := rule.Sharder.Shard(key)
+		if err != nil {
+			return nil, err
+		}
+		return []shardTarget{{table: rule.tableName(s.tableName, shard), db: rule.db(s.db, shard)}}, nil
+	}
+	if !s.fanOut {
+		return nil, fmt.Errorf("%w: %s.%s", ErrShardKeyRequired, s.tableName, rule.Key)
+	}
+	if s.offset > 0 {
+		return nil, errors.New("offset is not supported across shards")
+	}
+	if s.orderParam.Len() > 0 || s.cursor != "" {
+		return nil, fmt.Errorf("%w: %s", ErrFanOutOrder, s.tableName)
+	}
+	targets := make([]shardTarget, rule.Sharder.Shards())
+	for i := range targets {
+		targets[i] = shardTarget{table: rule.tableName(s.tableName, i), db: rule.db(s.db, i)}
+	}
+	return targets, nil
+}
+
+// shardKeyValue 依次从 ShardKey、结构体 data 以及 Where 中的等值条件读取分片键的值。
+// 条件中使用了 or 时等值条件不能确定分片。
+func (s *FrameSession) shardKeyValue(rule *ShardRule, data any) (any, bool) {
+	if s.hasShardKey {
+		return s.shardKey, true
+	}
+	if data != nil {
+		if sc, err := parseSchema(reflect.TypeOf(data)); err == nil {
+			if f, ok := sc.columns[rule.Key]; ok {
+				return reflect.Indirect(reflect.ValueOf(data)).Field(f.Index).Interface(), true
+			}
+		}
+	}
+	if s.whereOr {
+		return nil, false
+	}
+	for _, c := range s.whereEq {
+		if c.field == rule.Key {
+			return c.value, true
+		}
+	}
+	return nil, false
+}
+
+// onShards 在操作对应的每个分片上执行 fn，执行期间会话的表名和数据库切换为分片的表名和数据库。
+// 表没有分片规则时直接执行 fn。分片所在的数据库与会话不同时不能在事务中执行。
+func (s *FrameSession) onShards(data any, fn func() error) error {
+	targets, err := s.shardTargets(data)
+	if err != nil {
+		return err
+	}
+	if targets == nil {
+		return fn()
+	}
+	table, db := s.tableName, s.db
+	defer func() {
+		s.tableName, s.db = table, db
+	}()
+	for _, t := range targets {
+		if s.beginTx && t.db != db {
+			return fmt.Errorf("shard %s is in another database and cannot join the transaction", t.table)
+		}
+		s.tableName, s.db = t.table, t.db
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupShards 将批量插入、更新的数据按分片分组，保持数据在每组中的顺序，表没有分片规则时返回 nil。
+func (s *FrameSession) groupShards(data []any) ([]shardTarget, [][]any, error) {
+	if s.db.shardRule(s.tableName) == nil {
+		return nil, nil, nil
+	}
+	var targets []shardTarget
+	var groups [][]any
+	index := make(map[shardTarget]int)
+	for _, v := range data {
+		t, err := s.shardTargets(v)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(t) != 1 {
+			return nil, nil, fmt.Errorf("%w: %s", ErrShardKeyRequired, s.tableName)
+		}
+		i, ok := index[t[0]]
+		if !ok {
+			i = len(targets)
+			index[t[0]] = i
+			targets = append(targets, t[0])
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], v)
+	}
+	return targets, groups, nil
+}
+
+// onShardGroups 将批量操作的数据按分片分组，在每个分片上对该分片的数据执行 fn，
+// 执行期间会话的表名和数据库切换为分片的表名和数据库。表没有分片规则时对所有数据执行 fn。
+func (s *FrameSession) onShardGroups(data []any, fn func(group []any) error) error {
+	targets, groups, err := s.groupShards(data)
+	if err != nil {
+		return err
+	}
+	if targets == nil {
+		return fn(data)
+	}
+	table, db := s.tableName, s.db
+	defer func() {
+		s.tableName, s.db = table, db
+	}()
+	for i, t := range targets {
+		if s.beginTx && t.db != db {
+			return fmt.Errorf("shard %s is in another database and cannot join the transaction", t.table)
+		}
+		s.tableName, s.db = t.table, t.db
+		if err := fn(groups[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeAggregate 合并各分片的聚合结果，只支持 count、sum、max、min。
+func mergeAggregate(funcName string, results []int64) (int64, error) {
+	var merged int64
+	for i, r := range results {
+		switch funcName {
+		case "count", "COUNT", "sum", "SUM":
+			merged += r
+		case "max", "MAX":
+			if i == 0 || r > merged {
+				merged = r
+			}
+		case "min", "MIN":
+			if i == 0 || r < merged {
+				merged = r
+			}
+		default:
+			return 0, fmt.Errorf("aggregate %s is not supported across shards", funcName)
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,120 @@
+//go:build cgo
+
+package orm_test
+
+import (
+	"errors"
+	"frame/orm"
+	"frame/orm/ormtest"
+	"testing"
+)
+
+type shardItem struct {
+	Id     int64
+	UserId int64
+	Amount int
+}
+
+// openShards 创建按 user_id 分为两个分片的 shard_item 表，金额在两个分片之间交错分布。
+func openShards(t *testing.T) *ormtest.DB {
+	db := ormtest.Open(t)
+	db.Shard(&shardItem{}, orm.ShardRule{Key: "user_id", Sharder: orm.ModShard(2)})
+	if err := db.Insert(map[string][]map[string]any{
+		"shard_item_0": {
+			{"id": 1, "user_id": 2, "amount": 10},
+			{"id": 3, "user_id": 4, "amount": 30},
+		},
+		"shard_item_1": {
+			{"id": 2, "user_id": 1, "amount": 20},
+			{"id": 4, "user_id": 3, "amount": 40},
+			{"id": 5, "user_id": 3, "amount": 50},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestFanOutOrder(t *testing.T) {
+	db := openShards(t)
+
+	items, err := db.New(&shardItem{}).FanOut().Select(&shardItem{})
+	if err != nil || len(items) != 5 {
+		t.Fatalf("fan out select: %d items, %v", len(items), err)
+	}
+	// 各分片的结果按分片的顺序合并，排序只在单个分片内有效，在所有分片上执行时返回错误
+	for name, fn := range map[string]func(s *orm.FrameSession) error{
+		"select": func(s *orm.FrameSession) error {
+			_, err := s.OrderAsc("amount").Select(&shardItem{})
+			return err
+		},
+		"select with limit": func(s *orm.FrameSession) error {
+			_, err := s.OrderDesc("amount").Limit(2).Select(&shardItem{})
+			return err
+		},
+		"select one": func(s *orm.FrameSession) error {
+			return s.OrderAsc("amount").SelectOne(&shardItem{})
+		},
+		"cursor": func(s *orm.FrameSession) error {
+			_, err := s.SelectCursor(&shardItem{}, "id", false, 10)
+			return err
+		},
+	} {
+		if err := fn(db.New(&shardItem{}).FanOut()); !errors.Is(err, orm.ErrFanOutOrder) {
+			t.Fatalf("%s: expected ErrFanOutOrder, got %v", name, err)
+		}
+	}
+
+	// 指定分片键时只查询一个分片，排序正常生效
+	items, err = db.New(&shardItem{}).Where("user_id", 3).OrderDesc("amount").Select(&shardItem{})
+	if err != nil || len(items) != 2 || items[0].(*shardItem).Amount != 50 {
+		t.Fatalf("ordered select on one shard: %v %v", items, err)
+	}
+}
+
+func TestShardedUpdateBatch(t *testing.T) {
+	db := openShards(t)
+	items := []any{
+		&shardItem{Id: 1, UserId: 2, Amount: 11},
+		&shardItem{Id: 2, UserId: 1, Amount: 21},
+		&shardItem{Id: 3, UserId: 4, Amount: 31},
+	}
+	n, err := db.New(&shardItem{}).UpdateBatch(items, "amount")
+	if err != nil || n != 3 {
+		t.Fatalf("update batch: %d %v", n, err)
+	}
+	// 每个分片执行一条更新语句，只包含该分片的记录
+	ormtest.AssertSQL(t, db.Statements(),
+		"update shard_item_0 set amount = case id when 1 then 11 when 3 then 31 end where id in (1,3)",
+		"update shard_item_1 set amount = case id when 2 then 21 end where id in (2)",
+	)
+	shard0, shard1 := db.Rows("shard_item_0"), db.Rows("shard_item_1")
+	if shard0[0]["amount"] != int64(11) || shard0[1]["amount"] != int64(31) || shard1[0]["amount"] != int64(21) || shard1[1]["amount"] != int64(40) {
+		t.Fatalf("unexpected rows %v %v", shard0, shard1)
+	}
+
+	// 结构体中没有分片键时无法确定分片
+	type noKey struct {
+		Id     int64
+		Amount int
+	}
+	if _, err := db.New(&shardItem{}).UpdateBatch([]any{&noKey{Id: 1, Amount: 1}}, "amount"); !errors.Is(err, orm.ErrShardKeyRequired) {
+		t.Fatalf("expected ErrShardKeyRequired, got %v", err)
+	}
+}
+
+func TestShardedGroupPage(t *testing.T) {
+	db := openShards(t)
+	// 分组总数在各分片上统计后累加
+	page, err := db.New(&shardItem{}).FanOut().Group("user_id").Page(&shardItem{}, 1, 10, "user_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 4 || len(page.Items) != 4 {
+		t.Fatalf("got total %d, %d items, want 4", page.Total, len(page.Items))
+	}
+	page, err = db.New(&shardItem{}).Where("user_id", 3).Group("user_id").Page(&shardItem{}, 1, 10, "user_id")
+	if err != nil || page.Total != 1 {
+		t.Fatalf("group page on one shard: %+v %v", page, err)
+	}
+}
@@ -0,0 +1,137 @@
+package orm
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// 软删除的查询范围
+const (
+	// trashedExclude 排除已软删除的记录，是默认的查询范围。
+	trashedExclude = iota
+	// trashedWith 包含已软删除的记录，删除时仍然执行软删除。
+	trashedWith
+	// trashedOnly 只查询已软删除的记录。
+	trashedOnly
+	// trashedUnscoped 完全忽略软删除，查询包含已软删除的记录，删除时执行物理删除。
+	trashedUnscoped
+)
+
+// Unscoped 忽略软删除：查询包含已软删除的记录，Delete 执行物理删除。
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) Unscoped() *FrameSession {
+	s.trashed = trashedUnscoped
+	return s
+}
+
+// WithTrashed 查询时包含已软删除的记录。
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) WithTrashed() *FrameSession {
+	s.trashed = trashedWith
+	return s
+}
+
+// OnlyTrashed 只查询已软删除的记录。
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) OnlyTrashed() *FrameSession {
+	s.trashed = trashedOnly
+	return s
+}
+
+// Restore 恢复符合条件的已软删除记录，返回恢复的行数。
+// 模型没有软删除字段时返回错误。
+func (s *FrameSession) Restore() (int64, error) {
+	f := s.softDeleteField()
+	if f == nil {
+		return 0, errors.New("model has no soft delete field")
+	}
+	s.trashed = trashedOnly
+	var affected int64
+	err := s.onShards(nil, func() error {
+		s.resetValues()
+		if isIntField(f) {
+			s.setParam(f.Column, 0)
+		} else {
+			s.setParam(f.Column, nil)
+		}
+		_, n, err := s.update()
+		affected += n
+		return err
+	})
+	return affected, err
+}
+
+// softDelete 将符合条件的记录的删除时间设置为当前时间，已软删除的记录不会被再次更新。
+func (s *FrameSession) softDelete(f *field) (int64, error) {
+	if s.trashed != trashedOnly {
+		s.trashed = trashedExclude
+	}
+	s.setParam(f.Column, timeValue(f, time.Now()))
+	_, affected, err := s.update()
+	return affected, err
+}
+
+// softDeleteField 返回会话模型的软删除字段，模型没有软删除字段或者使用了 Unscoped 时返回 nil。
+// 软删除字段通过字段名 DeletedAt 或者 softdelete 选项识别，可以是时间类型（NULL 表示未删除）
+// 或者整数类型（0 表示未删除，删除时写入 Unix 秒数）。
+func (s *FrameSession) softDeleteField() *field {
+	// 使用子查询作为数据来源时，模型的字段不一定对应子查询的列
+	if s.model == nil || s.trashed == trashedUnscoped || strings.HasPrefix(s.tableName, "(") {
+		return nil
+	}
+	sc, err := parseSchema(reflect.TypeOf(s.model))
+	if err != nil {
+		return nil
+	}
+	return sc.DeletedAt
+}
+
+// trashedCondition 返回根据软删除范围自动追加的条件，不需要追加时返回空字符串。
+func (s *FrameSession) trashedCondition() string {
+	f := s.softDeleteField()
+	if f == nil || s.trashed == trashedWith {
+		return ""
+	}
+	column := f.Column
+	// 多表查询时使用表名或别名限定列名，避免与连接的表冲突
+	if s.alias != "" {
+		column = s.alias + "." + column
+	} else if s.joinParam.Len() > 0 {
+		column = s.tableName + "." + column
+	}
+	deleted := s.trashed == trashedOnly
+	switch {
+	case isIntField(f) && deleted:
+		return column + " <> 0"
+	case isIntField(f):
+		return column + " = 0"
+	case deleted:
+		return column + " is not null"
+	default:
+		return column + " is null"
+	}
+}
+
+// whereSQL 返回WHERE子句，包含根据软删除范围自动追加的条件。
+func (s *FrameSession) whereSQL() string {
+	cond := s.trashedCondition()
+	if cond == "" {
+		return s.whereParam.String()
+	}
+	if s.whereParam.Len() == 0 {
+		return " where " + cond
+	}
+	return " where (" + strings.TrimPrefix(s.whereParam.String(), " where ") + ") and " + cond
+}
+
+// isIntField 判断字段是否为整数类型。
+func isIntField(f *field) bool {
+	switch f.Type.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,379 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	newLog "frame/log"
+)
+
+// LogLevel 是 SQL 日志的级别，只记录不高于该级别的日志。
+type LogLevel int
+
+const (
+	// LogSilent 不记录任何 SQL 日志。
+	LogSilent LogLevel = iota
+	// LogError 只记录执行失败的 SQL。
+	LogError
+	// LogWarn 记录执行失败的 SQL 和慢查询。
+	LogWarn
+	// LogInfo 记录所有 SQL，是默认的级别。
+	LogInfo
+)
+
+// QueryEvent 描述了一次 SQL 执行，由 QueryLogger 记录。
+type QueryEvent struct {
+	// SQL 是使用 ? 占位符的 SQL 语句。
+	SQL string
+	// Args 是 SQL 的参数。
+	Args []any
+	// Rows 是受影响的行数，查询语句以及无法获取时为 -1。
+	Rows int64
+	// Duration 是执行耗时。
+	Duration time.Duration
+	// Err 是执行返回的错误。
+	Err error
+	// Caller 是调用 ORM 的代码位置，格式为 文件名:行号。
+	Caller string
+	// Slow 表示执行耗时超过了慢查询阈值。
+	Slow bool
+	// Redact 表示记录日志时需要隐藏参数的值。
+	Redact bool
+}
+
+// Interpolated 返回将参数代入占位符后的 SQL，仅用于日志展示，不能用于执行。
+// Redact 为 true 时参数显示为 ***。
+func (e *QueryEvent) Interpolated() string {
+	if e.Redact {
+		return interpolate(e.SQL, nil, true)
+	}
+	return interpolate(e.SQL, e.Args, false)
+}
+
+// Level 返回事件对应的日志级别。
+func (e *QueryEvent) Level() LogLevel {
+	switch {
+	case e.Err != nil:
+		return LogError
+	case e.Slow:
+		return LogWarn
+	default:
+		return LogInfo
+	}
+}
+
+// QueryLogger 记录 SQL 的执行情况，可以通过 FrameDb.SetQueryLogger 替换为自定义的实现，
+// 例如输出到链路追踪或者统计慢查询。
+type QueryLogger interface {
+	LogQuery(e *QueryEvent)
+}
+
+// QueryLoggerFunc 将函数转换为 QueryLogger。
+type QueryLoggerFunc func(e *QueryEvent)
+
+// LogQuery 调用 f(e)。
+func (f QueryLoggerFunc) LogQuery(e *QueryEvent) {
+	f(e)
+}
+
+// frameLogger 是默认的 QueryLogger，使用框架的日志记录器输出。
+type frameLogger struct {
+	logger *newLog.Logger
+}
+
+func (l frameLogger) LogQuery(e *QueryEvent) {
+	rows := "-"
+	if e.Rows >= 0 {
+		rows = strconv.FormatInt(e.Rows, 10)
+	}
+	msg := fmt.Sprintf("[%.3fms] [rows:%s] %s | %s", float64(e.Duration.Microseconds())/1000, rows, e.Interpolated(), e.Caller)
+	switch e.Level() {
+	case LogError:
+		l.logger.Error(msg + " | error: " + e.Err.Error())
+	case LogWarn:
+		l.logger.Warn("SLOW SQL " + msg)
+	default:
+		l.logger.Info(msg)
+	}
+}
+
+// queryLog 是 FrameDb 的 SQL 日志配置。配置在替换后不再修改，记录日志时不需要加锁，
+// 运行时可以与查询同时修改。
+type queryLog struct {
+	// logger 为 nil 时使用框架的日志记录器。
+	logger QueryLogger
+	// level 是记录日志的级别，默认为 LogInfo。
+	level LogLevel
+	// slow 是慢查询阈值，0 表示不检测慢查询。
+	slow time.Duration
+	// redact 表示日志中隐藏参数的值。
+	redact bool
+}
+
+// defaultQueryLog 是创建 FrameDb 时使用的 SQL 日志配置。
+var defaultQueryLog = queryLog{level: LogInfo}
+
+// updateQueryLog 复制当前的 SQL 日志配置，使用 fn 修改后替换。
+func (db *FrameDb) updateQueryLog(fn func(c *queryLog)) {
+	db.queryLogMu.Lock()
+	defer db.queryLogMu.Unlock()
+	c := defaultQueryLog
+	if old := db.queryLog.Load(); old != nil {
+		c = *old
+	}
+	fn(&c)
+	db.queryLog.Store(&c)
+}
+
+// SetQueryLogger 设置记录 SQL 的 QueryLogger，设置为 nil 时恢复为框架的日志记录器。
+func (db *FrameDb) SetQueryLogger(l QueryLogger) {
+	db.updateQueryLog(func(c *queryLog) {
+		c.logger = l
+	})
+}
+
+// SetLogLevel 设置 SQL 日志的级别，默认为 LogInfo。
+func (db *FrameDb) SetLogLevel(level LogLevel) {
+	db.updateQueryLog(func(c *queryLog) {
+		c.level = level
+	})
+}
+
+// SetSlowThreshold 设置慢查询阈值，执行耗时超过阈值的 SQL 以 LogWarn 级别记录，0 表示不检测。
+func (db *FrameDb) SetSlowThreshold(d time.Duration) {
+	db.updateQueryLog(func(c *queryLog) {
+		c.slow = d
+	})
+}
+
+// SetRedact 设置日志中是否隐藏 SQL 参数的值，用于避免密码等敏感信息写入日志。
+func (db *FrameDb) SetRedact(redact bool) {
+	db.updateQueryLog(func(c *queryLog) {
+		c.redact = redact
+	})
+}
+
+// logQuery 记录一次 SQL 执行。
+func (db *FrameDb) logQuery(start time.Time, query string, args []any, rows int64, err error) {
+	c := db.queryLog.Load()
+	if c == nil {
+		c = &defaultQueryLog
+	}
+	level := c.level
+	if level == LogSilent {
+		return
+	}
+	e := &QueryEvent{
+		SQL:      query,
+		Args:     args,
+		Rows:     rows,
+		Duration: time.Since(start),
+		Err:      err,
+		Redact:   c.redact,
+	}
+	e.Slow = c.slow > 0 && e.Duration >= c.slow
+	if e.Level() > level {
+		return
+	}
+	e.Caller = caller()
+	l := c.logger
+	if l == nil {
+		if db.logger == nil {
+			return
+		}
+		l = frameLogger{logger: db.logger}
+	}
+	l.LogQuery(e)
+}
+
+// Statement 是一条 SQL 语句以及它的参数。
+type Statement struct {
+	SQL  string
+	Args []any
+}
+
+// String 返回将参数代入占位符后的 SQL。
+func (st Statement) String() string {
+	return interpolate(st.SQL, st.Args, false)
+}
+
+// dryRun 保存了试运行模式下生成的 SQL，由同一个会话创建的子会话共享。
+type dryRun struct {
+	statements []Statement
+}
+
+// DryRun 开启试运行模式：会话生成的 SQL 不会被执行，而是记录下来，可以通过 Statements 获取，
+// 适用于在测试中检查生成的 SQL。试运行时写操作返回的 ID 和受影响的行数为 0，查询返回空结果。
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) DryRun() *FrameSession {
+	if s.dryRun == nil {
+		s.dryRun = &dryRun{}
+	}
+	return s
+}
+
+// Statements 返回试运行模式下生成的所有 SQL。
+func (s *FrameSession) Statements() []Statement {
+	if s.dryRun == nil {
+		return nil
+	}
+	return s.dryRun.statements
+}
+
+// dryResult 是试运行模式下写操作的结果。
+type dryResult struct{}
+
+func (dryResult) LastInsertId() (int64, error) { return 0, nil }
+
+func (dryResult) RowsAffected() (int64, error) { return 0, nil }
+
+// executor 是 *sql.DB 和 *sql.Tx 共同的方法。
+type executor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// conn 返回执行 SQL 使用的连接：事务中使用事务，只读查询在没有调用 UsePrimary 时使用副本，否则使用主库。
+func (s *FrameSession) conn(read bool) executor {
+	if s.beginTx {
+		return s.tx
+	}
+	if read && !s.usePrimary {
+		return s.db.reader()
+	}
+	return s.db.db
+}
+
+// exec 执行写操作并记录日志。SQL 中的 ? 占位符会按照方言改写为数据库实际使用的占位符。
+func (s *FrameSession) exec(query string, args ...any) (sql.Result, error) {
+	return s.execContext(context.Background(), query, args...)
+}
+
+// execContext 与 exec 相同，执行时使用 ctx。
+func (s *FrameSession) execContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if s.dryRun != nil {
+		s.dryRun.statements = append(s.dryRun.statements, Statement{SQL: query, Args: args})
+		return dryResult{}, nil
+	}
+	start := time.Now()
+	r, err := s.conn(false).ExecContext(ctx, rebind(s.db.dialect, query), args...)
+	rows := int64(-1)
+	if err == nil {
+		if n, e := r.RowsAffected(); e == nil {
+			rows = n
+		}
+	}
+	s.db.logQuery(start, query, args, rows, err)
+	if err == nil {
+		s.invalidateCache()
+	}
+	return r, err
+}
+
+// query 执行查询并记录日志，read 为 true 时可以路由到副本。
+// 试运行模式下不执行查询，返回的 rows 为 nil。
+func (s *FrameSession) query(read bool, query string, args ...any) (*sql.Rows, error) {
+	if s.dryRun != nil {
+		s.dryRun.statements = append(s.dryRun.statements, Statement{SQL: query, Args: args})
+		return nil, nil
+	}
+	start := time.Now()
+	rows, err := s.conn(read).Query(rebind(s.db.dialect, query), args...)
+	s.db.logQuery(start, query, args, -1, err)
+	return rows, err
+}
+
+// caller 返回 orm 包外第一个调用者的位置。
+func caller() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "frame/orm.") || strings.HasSuffix(frame.File, "_test.go") {
+			return filepath.Base(filepath.Dir(frame.File)) + "/" + filepath.Base(frame.File) + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// interpolate 将参数代入 SQL 的 ? 占位符，引号中的 ? 保持不变，redact 为 true 时参数显示为 ***。
+func interpolate(query string, args []any, redact bool) string {
+	var sb strings.Builder
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			switch {
+			case redact:
+				sb.WriteString("***")
+			case n < len(args):
+				sb.WriteString(literal(args[n]))
+			default:
+				sb.WriteByte(c)
+			}
+			n++
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+// literal 返回参数在日志中的字面量表示。
+func literal(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case []byte:
+		return "0x" + hex.EncodeToString(v)
+	case time.Time:
+		return "'" + v.Format("2006-01-02 15:04:05.999") + "'"
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	}
+	// 值为 nil 的指针（包括实现了 driver.Valuer 的 *sql.NullString 等）在调用方法之前处理
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return "NULL"
+	}
+	switch v := v.(type) {
+	case driver.Valuer:
+		value, err := v.Value()
+		if err != nil {
+			return "?"
+		}
+		return literal(value)
+	case fmt.Stringer:
+		return "'" + strings.ReplaceAll(v.String(), "'", "''") + "'"
+	}
+	if rv.Kind() == reflect.Pointer {
+		return literal(rv.Elem().Interface())
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,130 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// txState 保存了同一个事务中所有会话共享的状态。
+type txState struct {
+	// savepoints 是已创建的保存点数量，用于生成嵌套事务的保存点名称。
+	savepoints int
+	// afterCommit 是事务提交成功后需要执行的回调。
+	afterCommit []func()
+}
+
+// runAfterCommit 依次执行提交后回调并清空回调列表。
+func (t *txState) runAfterCommit() {
+	hooks := t.afterCommit
+	t.afterCommit = nil
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+// Transaction 在事务中执行 fn：fn 返回 nil 时提交事务，返回错误或发生 panic 时回滚事务。
+// 参数 opts 可选，用于指定事务的隔离级别和是否只读，例如：
+//
+//	err := db.Transaction(ctx, func(tx *orm.FrameSession) error {
+//		if _, _, err := tx.New(&Order{}).Insert(order); err != nil {
+//			return err
+//		}
+//		_, _, err := tx.New(&Goods{}).Where("id", order.GoodsId).UpdateParam("stock", orm.Expr("stock - ?", 1)).Update()
+//		return err
+//	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+func (db *FrameDb) Transaction(ctx context.Context, fn func(tx *FrameSession) error, opts ...*sql.TxOptions) error {
+	s := &FrameSession{db: db}
+	return s.Transaction(ctx, fn, opts...)
+}
+
+// Transaction 在事务中执行 fn。
+// 会话已经处于事务中时，使用保存点实现嵌套事务：fn 出错时只回滚到保存点，外层事务不受影响；
+// 否则开启一个新的事务，行为与 FrameDb.Transaction 相同。嵌套事务中 opts 会被忽略。
+func (s *FrameSession) Transaction(ctx context.Context, fn func(tx *FrameSession) error, opts ...*sql.TxOptions) (err error) {
+	if s.beginTx {
+		return s.savepoint(ctx, fn)
+	}
+
+	var opt *sql.TxOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	tx := &FrameSession{db: s.db}
+	if err := tx.BeginTx(ctx, opt); err != nil {
+		return err
+	}
+	defer func() {
+		// 发生 panic 时回滚事务后继续向上抛出
+		if r := recover(); r != nil {
+			_ = tx.Rollback()
+			panic(r)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Join(err, fmt.Errorf("rollback: %w", rbErr))
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// savepoint 在当前事务中创建保存点并执行 fn，fn 出错或 panic 时回滚到保存点。
+func (s *FrameSession) savepoint(ctx context.Context, fn func(tx *FrameSession) error) error {
+	s.txState.savepoints++
+	name := fmt.Sprintf("sp_%d", s.txState.savepoints)
+	// 记录回调数量，回滚到保存点时丢弃嵌套事务中注册的回调
+	hooks := len(s.txState.afterCommit)
+	if _, err := s.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+	rollback := func() error {
+		s.txState.afterCommit = s.txState.afterCommit[:hooks]
+		_, err := s.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			_ = rollback()
+			panic(r)
+		}
+	}()
+	if err := fn(s.New(nil)); err != nil {
+		if rbErr := rollback(); rbErr != nil {
+			return errors.Join(err, fmt.Errorf("rollback to savepoint %s: %w", name, rbErr))
+		}
+		return err
+	}
+	_, err := s.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
+
+// New 创建一个与当前会话共享数据库连接和事务的新会话，用于在同一个事务中操作其它表，
+// 当前会话调用了 UsePrimary 时新会话同样使用主库。
+// 参数 data 用于推导表名，为 nil 时需要调用 Table 指定表名。
+func (s *FrameSession) New(data any) *FrameSession {
+	var m *FrameSession
+	if data == nil {
+		m = &FrameSession{db: s.db}
+	} else {
+		m = s.db.New(data)
+	}
+	m.tx = s.tx
+	m.beginTx = s.beginTx
+	m.txState = s.txState
+	m.usePrimary = s.usePrimary
+	m.dryRun = s.dryRun
+	return m
+}
+
+// AfterCommit 注册一个在事务提交成功后执行的回调，适用于缓存失效、发送消息等副作用。
+// 事务回滚时回调不会执行；会话不在事务中时回调立即执行。
+func (s *FrameSession) AfterCommit(fn func()) {
+	if !s.beginTx {
+		fn()
+		return
+	}
+	s.txState.afterCommit = append(s.txState.afterCommit, fn)
+}
@@ -0,0 +1,138 @@
+//go:build cgo
+
+package orm_test
+
+import (
+	"context"
+	"errors"
+	"frame/orm"
+	"frame/orm/ormtest"
+	"strings"
+	"testing"
+)
+
+type txAccount struct {
+	Id      int64
+	Name    string
+	Balance int64
+}
+
+func openAccounts(t *testing.T) *ormtest.DB {
+	db := ormtest.Open(t)
+	if err := db.Insert(map[string][]map[string]any{
+		"tx_account": {{"name": "alice", "balance": 100}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func insertAccount(tx *orm.FrameSession, name string) error {
+	a := &txAccount{Name: name}
+	_, _, err := tx.New(a).Insert(a)
+	return err
+}
+
+func accountNames(db *ormtest.DB) []string {
+	var names []string
+	for _, r := range db.Rows("tx_account") {
+		names = append(names, r["name"].(string))
+	}
+	return names
+}
+
+func TestTransactionCommitAndRollback(t *testing.T) {
+	db := openAccounts(t)
+	ctx := context.Background()
+
+	var committed bool
+	err := db.Transaction(ctx, func(tx *orm.FrameSession) error {
+		tx.AfterCommit(func() { committed = true })
+		return insertAccount(tx, "bob")
+	})
+	if err != nil || !committed {
+		t.Fatalf("commit: err %v, after commit called %v", err, committed)
+	}
+
+	cause := errors.New("insufficient balance")
+	var afterRollback bool
+	err = db.Transaction(ctx, func(tx *orm.FrameSession) error {
+		tx.AfterCommit(func() { afterRollback = true })
+		if err := insertAccount(tx, "carol"); err != nil {
+			return err
+		}
+		return cause
+	})
+	if !errors.Is(err, cause) {
+		t.Fatalf("expected %v, got %v", cause, err)
+	}
+	if afterRollback {
+		t.Fatal("after commit callback should be dropped on rollback")
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("panic should be re-raised, got %v", r)
+			}
+		}()
+		_ = db.Transaction(ctx, func(tx *orm.FrameSession) error {
+			if err := insertAccount(tx, "dave"); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	}()
+
+	if names := accountNames(db); len(names) != 2 || names[0] != "alice" || names[1] != "bob" {
+		t.Fatalf("unexpected accounts %v", names)
+	}
+}
+
+func TestNestedTransactionSavepoint(t *testing.T) {
+	db := openAccounts(t)
+	ctx := context.Background()
+	var hooks []string
+	err := db.Transaction(ctx, func(tx *orm.FrameSession) error {
+		if err := insertAccount(tx, "bob"); err != nil {
+			return err
+		}
+		tx.AfterCommit(func() { hooks = append(hooks, "outer") })
+		// 内层事务失败只回滚到保存点，外层事务继续并提交
+		inner := tx.Transaction(ctx, func(tx *orm.FrameSession) error {
+			tx.AfterCommit(func() { hooks = append(hooks, "inner") })
+			if err := insertAccount(tx, "carol"); err != nil {
+				return err
+			}
+			return errors.New("inner failed")
+		})
+		if inner == nil {
+			t.Error("expected inner error")
+		}
+		return tx.Transaction(ctx, func(tx *orm.FrameSession) error {
+			return insertAccount(tx, "erin")
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := accountNames(db); len(names) != 3 || names[1] != "bob" || names[2] != "erin" {
+		t.Fatalf("unexpected accounts %v", names)
+	}
+	if len(hooks) != 1 || hooks[0] != "outer" {
+		t.Fatalf("unexpected after commit callbacks %v", hooks)
+	}
+	ormtest.AssertSQL(t, statementsLike(db.Statements(), "savepoint"),
+		"SAVEPOINT sp_1", "ROLLBACK TO SAVEPOINT sp_1", "SAVEPOINT sp_2", "RELEASE SAVEPOINT sp_2")
+}
+
+// statementsLike 返回包含 keyword（不区分大小写）的 SQL。
+func statementsLike(statements []orm.Statement, keyword string) []orm.Statement {
+	var result []orm.Statement
+	for _, st := range statements {
+		if strings.Contains(strings.ToLower(st.SQL), keyword) {
+			result = append(result, st)
+		}
+	}
+	return result
+}
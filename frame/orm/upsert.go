@@ -0,0 +1,158 @@
+package orm
+
+import (
+	"reflect"
+)
+
+// Conflict 描述了插入时遇到唯一键冲突的处理方式，由方言生成对应的 SQL 子句。
+type Conflict struct {
+	// Columns 是判断冲突的列（唯一索引或主键），MySQL 会忽略它。
+	Columns []string
+	// Updates 是冲突时使用插入的新值更新的列。
+	Updates []string
+	// DoNothing 表示冲突时保留已存在的记录，不做任何修改。
+	DoNothing bool
+	// Key 是表的主键列，用于返回插入或更新的记录的 ID，没有主键时为空。
+	Key string
+	// Inserted 是插入语句中的全部列。
+	Inserted []string
+}
+
+// OnConflict 设置插入时判断冲突的列，需要配合 DoUpdate 或 DoNothing 使用，例如：
+//
+//	db.New(&User{}).OnConflict("user_name").DoUpdate("age").Insert(user)
+//
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) OnConflict(columns ...string) *FrameSession {
+	if s.conflict == nil {
+		s.conflict = &Conflict{}
+	}
+	s.conflict.Columns = columns
+	return s
+}
+
+// DoUpdate 设置冲突时使用插入的新值更新 fields 中的列，
+// 未指定 fields 时更新除冲突列和主键以外所有插入的列。
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) DoUpdate(fields ...string) *FrameSession {
+	if s.conflict == nil {
+		s.conflict = &Conflict{}
+	}
+	s.conflict.Updates = fields
+	s.conflict.DoNothing = false
+	return s
+}
+
+// DoNothing 设置冲突时保留已存在的记录，此时插入返回的 ID 和受影响的行数都为 0。
+// 返回修改后的 FrameSession 实例。
+func (s *FrameSession) DoNothing() *FrameSession {
+	if s.conflict == nil {
+		s.conflict = &Conflict{}
+	}
+	s.conflict.Updates = nil
+	s.conflict.DoNothing = true
+	return s
+}
+
+// insertSuffix 返回追加在 INSERT 语句之后的冲突处理子句和 RETURNING 子句，
+// returning 表示是否通过 RETURNING 读取整数主键。
+func (s *FrameSession) insertSuffix(data any) (suffix string, returning bool) {
+	key, intKey := "", false
+	if sc, err := parseSchema(reflect.TypeOf(data)); err == nil {
+		if pk := sc.PrimaryKey(); pk != nil {
+			key, intKey = pk.Column, isIntField(pk)
+		}
+	}
+	if s.conflict != nil {
+		c := *s.conflict
+		c.Key = key
+		c.Inserted = s.fieldName
+		if !c.DoNothing && len(c.Updates) == 0 {
+			c.Updates = updateColumns(s.fieldName, c.Columns, key)
+		}
+		suffix = s.db.dialect.OnConflict(&c)
+	}
+	if intKey {
+		if r := s.db.dialect.Returning(key); r != "" {
+			suffix += r
+			returning = true
+		}
+	}
+	return suffix, returning
+}
+
+// updateColumns 返回插入的列中除冲突列和主键以外的列。
+func updateColumns(inserted []string, conflict []string, key string) []string {
+	columns := make([]string, 0, len(inserted))
+	for _, column := range inserted {
+		if column == key || contains(conflict, column) {
+			continue
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// contains 判断字符串切片中是否包含 v。
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// execInsert 执行插入语句，返回最后插入（或冲突时更新）的记录的 ID 以及受影响的行数。
+// 方言使用 RETURNING 返回主键时通过查询读取 ID，受影响的行数为返回的行数，批量插入时返回第一条记录的 ID；
+// 数据库不支持 LastInsertId 且无法使用 RETURNING 时 ID 为 0。
+// 为了与其它数据库保持一致，MySQL 单行插入冲突更新时受影响的行数为 1 而不是 2，
+// 冲突后不做任何修改时 ID 和受影响的行数都为 0。
+func (s *FrameSession) execInsert(query string, returning bool, batch bool) (int64, int64, error) {
+	if returning {
+		rows, err := s.query(false, query, s.values...)
+		if err != nil {
+			return -1, -1, err
+		}
+		// 试运行时没有结果
+		if rows == nil {
+			return 0, 0, nil
+		}
+		s.invalidateCache()
+		defer rows.Close()
+		var id, affected int64
+		for rows.Next() {
+			var v int64
+			if err := rows.Scan(&v); err != nil {
+				return -1, -1, err
+			}
+			// 与 MySQL 的 LastInsertId 一致，批量插入时返回第一条记录的 ID
+			if affected == 0 {
+				id = v
+			}
+			affected++
+		}
+		return id, affected, rows.Err()
+	}
+
+	r, err := s.exec(query, s.values...)
+	if err != nil {
+		return -1, -1, err
+	}
+	affected, err := r.RowsAffected()
+	if err != nil {
+		return -1, -1, err
+	}
+	if affected == 0 && s.conflict != nil {
+		return 0, 0, nil
+	}
+	if !batch && affected > 1 {
+		affected = 1
+	}
+	id, err := r.LastInsertId()
+	if err != nil {
+		// 驱动不支持 LastInsertId（例如没有主键的 PostgreSQL 表）
+		id = 0
+	}
+	return id, affected, nil
+}
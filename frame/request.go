@@ -0,0 +1,99 @@
+package frame
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	newlogger "frame/log"
+	"net"
+	"strings"
+)
+
+// RequestIDHeader 是传递请求 ID 的请求头和响应头。
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength 是请求头中 X-Request-ID 的最大长度，超过时重新生成请求 ID。
+const maxRequestIDLength = 128
+
+// 请求日志中的字段名
+const (
+	LogFieldRequestID = "request_id"
+	LogFieldRoute     = "route"
+	LogFieldClientIP  = "client_ip"
+	LogFieldUser      = "user"
+)
+
+// newRequestID 生成 16 字节的随机请求 ID，以十六进制表示。
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// validRequestID 判断请求头中的请求 ID 是否可以直接使用：不能为空、不超过 maxRequestIDLength，
+// 并且只包含字母、数字以及 . _ -，避免客户端通过请求 ID 向日志和响应头中注入内容。
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '.' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID 返回当前请求的 ID：请求头中有合法的 X-Request-ID 时使用该值，否则为框架生成的随机 ID。
+// 请求 ID 同时写入响应头，并作为 request_id 字段出现在 ctx.Logger 记录的每一条日志中。
+func (c *Context) RequestID() string {
+	return c.requestID
+}
+
+// ClientIP 返回客户端的 IP 地址，依次读取 X-Forwarded-For 中的第一个地址、X-Real-IP 以及连接的远端地址。
+// 请求头可以被客户端伪造，只在服务部署在可信的反向代理之后时才能用于鉴权。
+func (c *Context) ClientIP() string {
+	if forwarded := c.R.Header.Get("X-Forwarded-For"); forwarded != "" {
+		ip, _, _ := strings.Cut(forwarded, ",")
+		if ip = strings.TrimSpace(ip); ip != "" {
+			return ip
+		}
+	}
+	if ip := strings.TrimSpace(c.R.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+	ip, _, err := net.SplitHostPort(strings.TrimSpace(c.R.RemoteAddr))
+	if err != nil {
+		return c.R.RemoteAddr
+	}
+	return ip
+}
+
+// SetUser 记录通过认证的用户：保存到 Keys 的 user 中，并在 ctx.Logger 的日志字段中添加 user。
+// BasicAuth 和 token.JwtHandler 的 AuthInterceptor 认证成功后会自动调用。
+func (c *Context) SetUser(user string) {
+	c.Set("user", user)
+	if c.Logger != nil {
+		c.Logger = c.Logger.WithFields(newlogger.Fields{LogFieldUser: user})
+	}
+}
+
+// beginRequest 为请求分配请求 ID，并创建带有请求 ID、路由以及客户端 IP 字段的 ctx.Logger。
+// route 是匹配到的路由模式（包含路由组），例如 /user/:id，没有匹配到路由时为空。
+func (e *Engine) beginRequest(ctx *Context, route string) {
+	ctx.requestID = ctx.R.Header.Get(RequestIDHeader)
+	if !validRequestID(ctx.requestID) {
+		ctx.requestID = newRequestID()
+	}
+	ctx.W.Header().Set(RequestIDHeader, ctx.requestID)
+	fields := newlogger.Fields{
+		LogFieldRequestID: ctx.requestID,
+		LogFieldClientIP:  ctx.ClientIP(),
+	}
+	if route != "" {
+		fields[LogFieldRoute] = route
+	}
+	ctx.Logger = e.Logger.WithFields(fields)
+}
@@ -40,6 +40,8 @@ type JwtHandler struct {
 	CookieHTTPOnly bool
 	Header         string
 	AuthHandler    func(ctx *frame.Context, err error)
+	// 声明中用户标识的键，认证成功后通过 ctx.SetUser 记录到上下文和请求日志中，为空时依次尝试 user、username、sub
+	IdentityKey string
 }
 
 // JwtResponse 结构体用于封装生成的JWT token和刷新token
@@ -316,10 +318,10 @@ func (j *JwtHandler) AuthInterceptor(next frame.HandlerFunc) frame.HandlerFunc {
 		// 获取token中的声明
 		claims := t.Claims.(jwt.MapClaims)
 
-		// 将声明设置到上下文中
-		//ctx.Set("jwt_claims", claims)
-		//ctx.Set("jwt_claims", claims)
-		fmt.Println(claims)
+		// 将声明中的用户标识设置到上下文和请求日志中
+		if user, ok := j.identity(claims); ok {
+			ctx.SetUser(user)
+		}
 
 		// 调用下一个中间件或处理函数
 		next(ctx)
@@ -327,6 +329,20 @@ func (j *JwtHandler) AuthInterceptor(next frame.HandlerFunc) frame.HandlerFunc {
 
 }
 
+// identity 返回声明中的用户标识。
+func (j *JwtHandler) identity(claims jwt.MapClaims) (string, bool) {
+	keys := []string{"user", "username", "sub"}
+	if j.IdentityKey != "" {
+		keys = []string{j.IdentityKey}
+	}
+	for _, key := range keys {
+		if value, ok := claims[key]; ok && value != nil {
+			return fmt.Sprint(value), true
+		}
+	}
+	return "", false
+}
+
 // AuthErrorHandler 处理认证错误，如果没有设置自定义的错误处理函数，则返回401状态码
 func (j *JwtHandler) AuthErrorHandler(ctx *frame.Context, err error) {
 	if j.AuthHandler == nil {
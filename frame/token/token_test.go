@@ -0,0 +1,30 @@
+package token
+
+import (
+	"github.com/golang-jwt/jwt/v4"
+	"testing"
+)
+
+func TestIdentity(t *testing.T) {
+	for _, tt := range []struct {
+		key    string
+		claims jwt.MapClaims
+		want   string
+		ok     bool
+	}{
+		{"", jwt.MapClaims{"user": "alice", "sub": "1"}, "alice", true},
+		{"", jwt.MapClaims{"username": "bob", "sub": "1"}, "bob", true},
+		{"", jwt.MapClaims{"sub": float64(42)}, "42", true},
+		{"", jwt.MapClaims{"user": nil, "sub": "carol"}, "carol", true},
+		{"", jwt.MapClaims{"uid": "dave"}, "", false},
+		// 指定 IdentityKey 时只读取该键
+		{"uid", jwt.MapClaims{"uid": "dave", "user": "alice"}, "dave", true},
+		{"uid", jwt.MapClaims{"user": "alice"}, "", false},
+	} {
+		j := &JwtHandler{IdentityKey: tt.key}
+		got, ok := j.identity(tt.claims)
+		if got != tt.want || ok != tt.ok {
+			t.Fatalf("identity(%v) with key %q = %q, %v, want %q, %v", tt.claims, tt.key, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"frame/orm"
+	"frame/orm/migrate"
+	_ "github.com/go-sql-driver/mysql"
+	"os"
+)
+
+// 迁移命令行工具，例如：
+//
+//	go run ./cmd/migrate -dsn "root:root@tcp(localhost:3306)/framego?parseTime=true" up
+//	go run ./cmd/migrate -dir migrations create add_user_email
+func main() {
+	dsn := flag.String("dsn", "root:root@tcp(localhost:3306)/framego?charset=utf8&parseTime=true", "database source name")
+	dir := flag.String("dir", "migrations", "migration files directory")
+	flag.Parse()
+
+	// 只有 up、down、status 需要连接数据库，create 不连接
+	var db *orm.FrameDb
+	open := func() (*migrate.Migrator, error) {
+		var err error
+		if db, err = orm.OpenWithConfig("mysql", &orm.DbConfig{DSN: *dsn}); err != nil {
+			return nil, err
+		}
+		return migrate.New(db), nil
+	}
+	err := migrate.Command(open, *dir, flag.Args(), os.Stdout)
+	if db != nil {
+		db.Close()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}